@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,17 +14,53 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 
+	"github.com/Tommych123/L0-WB/internal/auth"
 	"github.com/Tommych123/L0-WB/internal/config"
+	"github.com/Tommych123/L0-WB/internal/encryption"
+	"github.com/Tommych123/L0-WB/internal/health"
 	httphandler "github.com/Tommych123/L0-WB/internal/http"
+	"github.com/Tommych123/L0-WB/internal/i18n"
 	"github.com/Tommych123/L0-WB/internal/kafka"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/metrics"
+	"github.com/Tommych123/L0-WB/internal/redact"
 	"github.com/Tommych123/L0-WB/internal/repository"
 	"github.com/Tommych123/L0-WB/internal/service"
+	"github.com/Tommych123/L0-WB/internal/stream"
+	"github.com/Tommych123/L0-WB/internal/tracing"
+	"github.com/Tommych123/L0-WB/internal/webhook"
 )
 
 func main() {
 	// Загружаем конфиг
 	cfg := config.Load()
 
+	// Структурированные логи; стандартный log тоже пишет через этот обработчик
+	slog.SetDefault(logger.New(os.Stdout, cfg.LogLevel, cfg.LogFormat))
+
+	// Язык ответов по умолчанию
+	if err := i18n.SetDefault(cfg.DefaultLang); err != nil {
+		fatal("invalid DEFAULT_LANG", err)
+	}
+
+	// Трассировка
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		File:        cfg.TracingFile,
+		ServiceName: "l0-service",
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		fatal("failed to init tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("error flushing traces", logger.KeyError, err)
+		}
+	}()
+
 	// Подключение к БД
 	dsn := fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
@@ -31,46 +68,240 @@ func main() {
 	)
 	db, err := sqlx.Connect("postgres", dsn)
 	if err != nil {
-		log.Fatalf("failed to connect to DB: %v", err)
+		fatal("failed to connect to DB", err)
 	}
 	defer db.Close()
 
+	// Ключи шифрования персональных данных
+	var keyring *encryption.Keyring
+	if cfg.EncryptionKeyFile == "" {
+		slog.Warn("ENCRYPTION_KEYFILE is not set, personal data is stored unencrypted")
+	} else if keyring, err = encryption.LoadKeyring(cfg.EncryptionKeyFile); err != nil {
+		fatal("failed to load encryption keys", err)
+	}
+
 	// Репозиторий
-	repo := repository.NewPostgresOrderRepository(db)
+	repo := repository.NewPostgresOrderRepository(db, keyring)
 
 	// Сервис заказов
 	orderService := service.NewOrderService(repo)
+	// Удаление данных клиента обезличивает и архивы партиций
+	orderService.UseArchives(service.NewArchiveEraser(cfg.ArchiveDir))
 
-	// Загрузка кеша из БД при старте
-	if err := orderService.LoadCache(); err != nil {
-		log.Fatalf("failed to load cache: %v", err)
-	}
+	// Метрики кэша читаются из сервиса в момент сбора
+	metrics.RegisterCache(orderService.CacheStats)
 
-	// Kafka consumer
-	consumer := kafka.NewConsumer(cfg.KafkaBroker, "orders-topic", "orders-group", orderService)
+	// Лента новых заказов для SSE/WebSocket
+	broker := stream.NewBroker(1000, 64)
+	orderService.OnOrderSaved(broker.Publish)
+
+	// Аналитика из таблиц заказов или из дневных агрегатов
+	var analytics repository.AnalyticsRepository = repo
+	if cfg.AnalyticsRollups {
+		analytics = repository.NewRollupAnalytics(repo)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Запуск Kafka consumer
+	// Шифрование строк, записанных открытым текстом или старым ключом
+	if keyring != nil {
+		go func() {
+			n, err := repo.EncryptExisting(ctx, cfg.EncryptionMigrateBatch)
+			if err != nil && err != context.Canceled {
+				slog.Error("encryption migration stopped", logger.KeyError, err, "rows", n)
+				return
+			}
+			slog.Info("encryption migration finished", "rows", n, "active_key", keyring.ActiveKey())
+		}()
+	}
+
+	// Партиции заказов: создание наперед и архивация устаревших
+	retention := service.NewRetention(repo, orderService, cfg.ArchiveDir, cfg.RetentionMonths, cfg.RetentionInterval)
+	go func() {
+		if err := retention.Run(ctx); err != nil && err != context.Canceled {
+			slog.Error("partition retention stopped", logger.KeyError, err)
+		}
+	}()
+
+	// Удаление заказов из кэша по уведомлениям других экземпляров
+	evictions := repository.NewEvictionListener(dsn)
+	go func() {
+		if err := evictions.Run(ctx, orderService.EvictCached, orderService.ResetCache); err != nil && err != context.Canceled {
+			slog.Error("cache eviction listener stopped", logger.KeyError, err)
+		}
+	}()
+
+	// Лента заказов и кэш получают заказы, сохраненные любым экземпляром сервиса.
+	// После потери уведомлений кэш сбрасывается, пропущенные события ленты не повторяются
+	savedOrders := repository.NewNotifyListener(dsn, repository.OrderSavedChannel)
+	go func() {
+		reload := func(uid string) {
+			if err := orderService.ReloadSaved(ctx, uid); err != nil {
+				slog.Error("error reloading saved order", logger.KeyOrderUID, uid, logger.KeyError, err)
+			}
+		}
+		if err := savedOrders.Run(ctx, reload, orderService.ResetCache); err != nil && err != context.Canceled {
+			slog.Error("saved orders listener stopped", logger.KeyError, err)
+		}
+	}()
+
+	// Окончательное удаление заказов после периода ожидания
+	purger := service.NewPurger(repo, cfg.DeletedOrdersGrace, cfg.PurgeInterval)
+	go func() {
+		if err := purger.Run(ctx); err != nil && err != context.Canceled {
+			slog.Error("deleted orders purger stopped", logger.KeyError, err)
+		}
+	}()
+
+	// Удаление устаревших ключей идемпотентности
+	idempotencyRepo := repository.NewPostgresIdempotencyRepository(db)
+	idempotencyCleaner := service.NewIdempotencyCleaner(idempotencyRepo, cfg.IdempotencyTTL, cfg.IdempotencyCleanupInterval)
 	go func() {
-		if err := consumer.Run(ctx); err != nil {
-			log.Printf("Kafka consumer stopped: %v", err)
+		if err := idempotencyCleaner.Run(ctx); err != nil && err != context.Canceled {
+			slog.Error("idempotency keys cleaner stopped", logger.KeyError, err)
 		}
 	}()
 
+	// Kafka consumer
+	consumer := kafka.NewConsumer(cfg.KafkaBroker, cfg.KafkaTopic, "orders-group", orderService)
+
+	// Рассылка webhook подписчикам
+	webhookRepo := repository.NewPostgresWebhookRepository(db, keyring)
+	dispatcher := webhook.NewDispatcher(webhookRepo, webhook.Config{
+		Workers:          cfg.WebhookWorkers,
+		PollInterval:     cfg.WebhookPollInterval,
+		Timeout:          cfg.WebhookTimeout,
+		MaxAttempts:      cfg.WebhookMaxAttempts,
+		BaseBackoff:      5 * time.Second,
+		MaxBackoff:       time.Hour,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+	})
+	go func() {
+		if err := dispatcher.Run(ctx); err != nil && err != context.Canceled {
+			slog.Error("webhook dispatcher stopped", logger.KeyError, err)
+		}
+	}()
+
+	// Публикация событий о сохраненных заказах из outbox в Kafka
+	eventProducer := kafka.NewEventProducer(cfg.KafkaBroker, cfg.OutboxTopic)
+	defer eventProducer.Close()
+	relay := service.NewOutboxRelay(repo, eventProducer,
+		cfg.OutboxPollInterval, cfg.OutboxBatchSize, cfg.OutboxRetention)
+	go func() {
+		if err := relay.Run(ctx); err != nil && err != context.Canceled {
+			slog.Error("outbox relay stopped", logger.KeyError, err)
+		}
+	}()
+
+	// Прием заказов через HTTP: напрямую в БД или через Kafka
+	var ingester httphandler.OrderIngester
+	switch cfg.IngestMode {
+	case "kafka":
+		producer := kafka.NewProducer(cfg.KafkaBroker, cfg.KafkaTopic)
+		defer producer.Close()
+		ingester = httphandler.NewKafkaIngester(producer)
+	case "db":
+		ingester = httphandler.NewServiceIngester(orderService)
+	default:
+		fatal("unknown INGEST_MODE", fmt.Errorf("%q", cfg.IngestMode))
+	}
+
+	// Проверки готовности зависимостей
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("postgres", db.PingContext)
+	checker.Add("kafka", consumer.CheckReader)
+	checker.Add("cache", orderService.CheckCache)
+	checker.Add("consumer_lag", consumer.CheckLag(int64(cfg.ReadyMaxLag)))
+
 	// HTTP сервер
-	router := httphandler.NewRouter(orderService)
+	// Аутентификация HTTP API
+	var authenticator *auth.Authenticator
+	if cfg.AuthDisabled {
+		slog.Warn("authentication is disabled, every request has the admin role")
+	} else {
+		authenticator, err = auth.New(auth.Config{
+			APIKeys:            cfg.AuthAPIKeys,
+			HS256Secret:        cfg.AuthJWTSecret,
+			RS256PublicKeyFile: cfg.AuthJWTPublicKeyFile,
+			JWKSFile:           cfg.AuthJWKSFile,
+			Issuer:             cfg.AuthJWTIssuer,
+			Audience:           cfg.AuthJWTAudience,
+		})
+		if err != nil {
+			fatal("failed to init authentication", err)
+		}
+	}
+
+	// Скрытие персональных данных в ответах и логах
+	rules := cfg.RedactRules
+	if rules == "" {
+		rules = redact.DefaultRules
+	}
+	redaction, err := redact.ParsePolicy(rules)
+	if err != nil {
+		fatal("invalid REDACT_RULES", err)
+	}
+	redact.SetLogPolicy(redaction)
+
+	// Ограничение частоты запросов
+	var rateLimiter *httphandler.RateLimiter
+	if cfg.RateLimitDisabled {
+		slog.Warn("rate limiting is disabled")
+	} else {
+		spec := cfg.RateLimits
+		if spec == "" {
+			spec = httphandler.DefaultRateLimits
+		}
+		limits, err := httphandler.ParseRateLimits(spec)
+		if err != nil {
+			fatal("invalid RATE_LIMITS", err)
+		}
+		rateLimiter = httphandler.NewRateLimiter(limits, cfg.RateLimitTrustForwardedFor)
+	}
+
+	router := httphandler.NewRouter(httphandler.RouterDeps{
+		OrderService: orderService,
+		Ingester:     ingester,
+		Idempotency:  idempotencyRepo,
+		Ledger:       repo,
+		Lookup:       repo,
+		Analytics:    analytics,
+		Webhooks:     webhookRepo,
+		Stream:       broker,
+		Health:       checker,
+		Auth:         authenticator,
+		Redaction:    redaction,
+		RateLimiter:  rateLimiter,
+
+		OrderCacheControl: cfg.OrderCacheControl,
+		StreamOrigins:     cfg.StreamAllowedOrigins,
+	})
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.ServicePort),
 		Handler: router,
+		// Отмена ctx при остановке закрывает открытые ленты заказов
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	go func() {
-		log.Printf("HTTP server listening on :%d", cfg.ServicePort)
+		slog.Info("HTTP server listening", "port", cfg.ServicePort)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+			fatal("HTTP server error", err)
+		}
+	}()
+
+	// Загрузка кеша из БД при старте; до ее завершения /readyz отвечает 503
+	if err := orderService.LoadCache(ctx); err != nil {
+		fatal("failed to load cache", err)
+	}
+
+	// Запуск Kafka consumer после прогрева кеша
+	go func() {
+		if err := consumer.Run(ctx); err != nil && err != context.Canceled {
+			slog.Error("Kafka consumer stopped", logger.KeyError, err)
 		}
 	}()
 
@@ -78,14 +309,21 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("shutting down server")
+	cancel()
 
 	// Завершаем работу HTTP сервера
 	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelShutdown()
 	if err := srv.Shutdown(ctxShutdown); err != nil {
-		log.Fatalf("HTTP server forced to shutdown: %v", err)
+		fatal("HTTP server forced to shutdown", err)
 	}
 
-	log.Println("Server exiting")
+	slog.Info("server exiting")
+}
+
+// Логирует фатальную ошибку и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, logger.KeyError, err)
+	os.Exit(1)
 }
@@ -2,15 +2,35 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"os"
 	"time"
 
+	"github.com/Tommych123/L0-WB/internal/config"
 	"github.com/Tommych123/L0-WB/internal/domain"
 	"github.com/Tommych123/L0-WB/internal/kafka"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/tracing"
 )
 
 // Пример приходящего заказа для теста обработки сообщений
 func main() {
+	// Трассировка настраивается теми же переменными, что и сервис (TRACING_EXPORTER=stdout)
+	cfg := config.Load()
+	slog.SetDefault(logger.New(os.Stdout, cfg.LogLevel, cfg.LogFormat))
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		File:        cfg.TracingFile,
+		ServiceName: "l0-producer",
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		slog.Error("failed to init tracing", logger.KeyError, err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
 	producer := kafka.NewProducer("localhost:9092", "orders-topic")
 	defer producer.Close()
 	// Заказ
@@ -63,8 +83,10 @@ func main() {
 	}
 
 	// Отправка его
-	err := producer.SendOrder(context.Background(), order)
+	err = producer.SendOrder(context.Background(), order)
 	if err != nil {
-		log.Fatalf("failed to send order: %v", err)
+		shutdownTracing(context.Background())
+		slog.Error("failed to send order", logger.KeyError, err)
+		os.Exit(1)
 	}
 }
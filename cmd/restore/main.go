@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+
+	"github.com/Tommych123/L0-WB/internal/archive"
+	"github.com/Tommych123/L0-WB/internal/config"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Восстановление архива партиций заказов в БД:
+//
+//	go run ./cmd/restore -archive ./archive/orders-2025-01-1740787200
+//
+// Заказы, которые уже есть в БД, пропускаются, поэтому повторный запуск безопасен
+func main() {
+	dirFlag := flag.String("archive", "", "archive directory")
+	flag.Parse()
+
+	cfg := config.Load()
+	slog.SetDefault(logger.New(os.Stdout, cfg.LogLevel, cfg.LogFormat))
+
+	if *dirFlag == "" {
+		fatal("invalid -archive", errors.New("archive directory is required"))
+	}
+	src, err := archive.Open(*dirFlag)
+	if err != nil {
+		fatal("failed to open archive", err)
+	}
+	month, err := src.Manifest.MonthStart()
+	if err != nil {
+		fatal("invalid archive manifest", err)
+	}
+
+	dsn := fmt.Sprintf(
+		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
+		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName,
+	)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		fatal("failed to connect to DB", err)
+	}
+	defer db.Close()
+
+	// Строки архива хранятся в том виде, в каком были в БД, ключи не нужны
+	repo := repository.NewPostgresOrderRepository(db, nil)
+	start := time.Now()
+	restored, err := repo.RestoreArchive(context.Background(), month, src)
+	if err != nil {
+		fatal("failed to restore archive", err)
+	}
+	args := []any{"month", src.Manifest.Month, "path", *dirFlag, "duration_ms", time.Since(start).Milliseconds(),
+		"anonymized_orders", restored.Anonymized}
+	for _, t := range src.Manifest.Tables {
+		args = append(args, t.Table, restored.Rows[t.Table])
+	}
+	slog.Info("archive restored", args...)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, logger.KeyError, err)
+	os.Exit(1)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+
+	"github.com/Tommych123/L0-WB/internal/config"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Пересчет дневных агрегатов аналитики за период из таблиц заказов:
+//
+//	go run ./cmd/rollups -from 2025-03-01 -to 2025-04-01
+//
+// to не включается; по умолчанию — следующий день после from
+func main() {
+	fromFlag := flag.String("from", "", "first day to rebuild, YYYY-MM-DD")
+	toFlag := flag.String("to", "", "day after the last one to rebuild, YYYY-MM-DD")
+	flag.Parse()
+
+	cfg := config.Load()
+	slog.SetDefault(logger.New(os.Stdout, cfg.LogLevel, cfg.LogFormat))
+
+	from, err := time.Parse(time.DateOnly, *fromFlag)
+	if err != nil {
+		fatal("invalid -from", err)
+	}
+	to := from.AddDate(0, 0, 1)
+	if *toFlag != "" {
+		if to, err = time.Parse(time.DateOnly, *toFlag); err != nil {
+			fatal("invalid -to", err)
+		}
+	}
+	if !to.After(from) {
+		fatal("invalid period", fmt.Errorf("-to %s is not after -from %s", to.Format(time.DateOnly), from.Format(time.DateOnly)))
+	}
+
+	dsn := fmt.Sprintf(
+		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
+		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName,
+	)
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		fatal("failed to connect to DB", err)
+	}
+	defer db.Close()
+
+	// Агрегаты не читают зашифрованные поля, ключи не нужны
+	repo := repository.NewPostgresOrderRepository(db, nil)
+	start := time.Now()
+	rows, err := repo.RebuildRollups(context.Background(), from, to)
+	if err != nil {
+		fatal("failed to rebuild rollups", err)
+	}
+	slog.Info("rollups rebuilt",
+		"from", from.Format(time.DateOnly), "to", to.Format(time.DateOnly),
+		"rows", rows, "duration_ms", time.Since(start).Milliseconds())
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, logger.KeyError, err)
+	os.Exit(1)
+}
@@ -0,0 +1,359 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Имя файла с описанием архива
+const ManifestFile = "manifest.json"
+
+// Описание архива месячной партиции
+type Manifest struct {
+	// Месяц партиции, YYYY-MM
+	Month     string          `json:"month"`
+	CreatedAt time.Time       `json:"created_at"`
+	Tables    []ManifestTable `json:"tables"`
+}
+
+// Файл со строками одной таблицы
+type ManifestTable struct {
+	Table string `json:"table"`
+	File  string `json:"file"`
+	Rows  int64  `json:"rows"`
+	// SHA-256 сжатого файла
+	SHA256 string `json:"sha256"`
+}
+
+// Первый день месяца архива
+func (m *Manifest) MonthStart() (time.Time, error) {
+	return time.Parse("2006-01", m.Month)
+}
+
+// Открытый файл таблицы в записываемом архиве
+type tableFile struct {
+	file *os.File
+	hash hash.Hash
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+	rows int64
+}
+
+// Архив партиции в каталоге: по сжатому NDJSON на таблицу и manifest.json.
+// До Commit архив пишется во временный каталог и не виден восстановлению
+type Writer struct {
+	dir      string
+	tmp      string
+	month    time.Time
+	tables   map[string]*tableFile
+	order    []string
+	finished bool
+	// Время создания в manifest.json; нулевое — время Commit
+	createdAt time.Time
+}
+
+// Создает архив месяца month в каталоге root; имя каталога архива содержит месяц и время создания
+func Create(root string, month time.Time) (*Writer, error) {
+	name := "orders-" + month.Format("2006-01") + "-" + strconv.FormatInt(time.Now().Unix(), 10)
+	dir := filepath.Join(root, name)
+	tmp := dir + ".tmp"
+	if err := os.MkdirAll(tmp, 0o750); err != nil {
+		return nil, err
+	}
+	return &Writer{dir: dir, tmp: tmp, month: month, tables: make(map[string]*tableFile)}, nil
+}
+
+// Каталог архива после Commit
+func (w *Writer) Dir() string {
+	return w.dir
+}
+
+// Добавляет строку таблицы
+func (w *Writer) WriteRow(table string, row []byte) error {
+	tf, ok := w.tables[table]
+	if !ok {
+		var err error
+		if tf, err = w.open(table); err != nil {
+			return err
+		}
+	}
+	if _, err := tf.buf.Write(row); err != nil {
+		return err
+	}
+	if err := tf.buf.WriteByte('\n'); err != nil {
+		return err
+	}
+	tf.rows++
+	return nil
+}
+
+func (w *Writer) open(table string) (*tableFile, error) {
+	f, err := os.OpenFile(filepath.Join(w.tmp, table+".ndjson.gz"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	tf := &tableFile{file: f, hash: h, gz: gz, buf: bufio.NewWriterSize(gz, 64<<10)}
+	w.tables[table] = tf
+	w.order = append(w.order, table)
+	return tf, nil
+}
+
+// Дописывает файлы на диск, пишет manifest.json и переименовывает временный каталог
+func (w *Writer) Commit() error {
+	createdAt := w.createdAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	manifest := Manifest{
+		Month:     w.month.Format("2006-01"),
+		CreatedAt: createdAt,
+		Tables:    make([]ManifestTable, 0, len(w.order)),
+	}
+	for _, table := range w.order {
+		tf := w.tables[table]
+		if err := tf.buf.Flush(); err != nil {
+			return err
+		}
+		if err := tf.gz.Close(); err != nil {
+			return err
+		}
+		if err := tf.file.Sync(); err != nil {
+			return err
+		}
+		if err := tf.file.Close(); err != nil {
+			return err
+		}
+		manifest.Tables = append(manifest.Tables, ManifestTable{
+			Table:  table,
+			File:   filepath.Base(tf.file.Name()),
+			Rows:   tf.rows,
+			SHA256: hex.EncodeToString(tf.hash.Sum(nil)),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(filepath.Join(w.tmp, ManifestFile), data); err != nil {
+		return err
+	}
+	if err := os.Rename(w.tmp, w.dir); err != nil {
+		return err
+	}
+	w.finished = true
+	return syncDir(filepath.Dir(w.dir))
+}
+
+// Удаляет архив: незавершенный или уже зафиксированный, если партиции удалить не удалось
+func (w *Writer) Abort() error {
+	for _, tf := range w.tables {
+		tf.file.Close()
+	}
+	if w.finished {
+		return os.RemoveAll(w.dir)
+	}
+	return os.RemoveAll(w.tmp)
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Архив, открытый для восстановления
+type Reader struct {
+	dir      string
+	Manifest Manifest
+}
+
+// Открывает архив по каталогу и читает manifest.json
+func Open(dir string) (*Reader, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{dir: dir}
+	if err := json.Unmarshal(data, &r.Manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestFile, err)
+	}
+	if _, err := r.Manifest.MonthStart(); err != nil {
+		return nil, fmt.Errorf("invalid month in %s: %w", ManifestFile, err)
+	}
+	return r, nil
+}
+
+// Передает в fn строки таблицы. Число строк и контрольная сумма файла сверяются
+// с manifest.json после чтения; таблица без файла пропускается
+func (r *Reader) Rows(table string, fn func(row []byte) error) error {
+	var entry *ManifestTable
+	for i := range r.Manifest.Tables {
+		if r.Manifest.Tables[i].Table == table {
+			entry = &r.Manifest.Tables[i]
+		}
+	}
+	if entry == nil {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(r.dir, filepath.Base(entry.File)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(f, h))
+	if err != nil {
+		return fmt.Errorf("%s: %w", entry.File, err)
+	}
+	br := bufio.NewReaderSize(gz, 64<<10)
+
+	var rows int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if n := len(line); n > 0 && line[n-1] == '\n' {
+			line = line[:n-1]
+		}
+		if len(line) > 0 {
+			if err := fn(line); err != nil {
+				return err
+			}
+			rows++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.File, err)
+		}
+	}
+	// Дочитываем хвост gzip, чтобы сумма охватила весь файл
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if rows != entry.Rows {
+		return fmt.Errorf("%s: %d rows, manifest says %d", entry.File, rows, entry.Rows)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != entry.SHA256 {
+		return fmt.Errorf("%s: checksum mismatch", entry.File)
+	}
+	return nil
+}
+
+// Каталоги зафиксированных архивов в root по имени; незавершенные записи пропускаются
+func List(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if !e.IsDir() || filepath.Ext(e.Name()) != "" {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// Переписывает строки архива через fn. Новый архив пишется рядом и заменяет старый
+// только после записи на диск; если fn не изменил ни одной строки, архив не трогается.
+// Возвращает true, если архив переписан
+func Rewrite(dir string, fn func(table string, row []byte) ([]byte, error)) (bool, error) {
+	src, err := Open(dir)
+	if err != nil {
+		return false, err
+	}
+	month, err := src.Manifest.MonthStart()
+	if err != nil {
+		return false, err
+	}
+	next := dir + ".rewrite"
+	w := &Writer{
+		dir:       next,
+		tmp:       next + ".tmp",
+		month:     month,
+		tables:    make(map[string]*tableFile),
+		createdAt: src.Manifest.CreatedAt,
+	}
+	if err := os.MkdirAll(w.tmp, 0o750); err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, entry := range src.Manifest.Tables {
+		err := src.Rows(entry.Table, func(row []byte) error {
+			out, err := fn(entry.Table, row)
+			if err != nil {
+				return err
+			}
+			changed = changed || !bytes.Equal(out, row)
+			return w.WriteRow(entry.Table, out)
+		})
+		if err != nil {
+			w.Abort()
+			return false, err
+		}
+	}
+	if !changed {
+		return false, w.Abort()
+	}
+	if err := w.Commit(); err != nil {
+		w.Abort()
+		return false, err
+	}
+
+	// Старый архив убирается в сторону и удаляется после того, как новый занял его место
+	old := dir + ".old"
+	if err := os.Rename(dir, old); err != nil {
+		w.Abort()
+		return false, err
+	}
+	if err := os.Rename(next, dir); err != nil {
+		return false, errors.Join(err, os.Rename(old, dir))
+	}
+	if err := syncDir(filepath.Dir(dir)); err != nil {
+		return true, err
+	}
+	return true, os.RemoveAll(old)
+}
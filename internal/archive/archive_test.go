@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriterReader_RoundTrip(t *testing.T) {
+	root := t.TempDir()
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	w, err := Create(root, month)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := map[string][]string{
+		"orders": {`{"order_uid":"o1"}`, `{"order_uid":"o2"}`},
+		"items":  {`{"rid":"r1","order_uid":"o1"}`},
+	}
+	for table, list := range rows {
+		for _, row := range list {
+			if err := w.WriteRow(table, []byte(row)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(w.Dir() + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary directory must be renamed: %v", err)
+	}
+
+	r, err := Open(w.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Manifest.MonthStart(); !got.Equal(month) {
+		t.Errorf("unexpected month %v", got)
+	}
+	for table, want := range rows {
+		var got []string
+		if err := r.Rows(table, func(row []byte) error {
+			got = append(got, string(row))
+			return nil
+		}); err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+		if len(got) != len(want) || got[0] != want[0] {
+			t.Errorf("%s: got %v, want %v", table, got, want)
+		}
+	}
+	// Таблицы без строк в архиве нет
+	if err := r.Rows("payment", func([]byte) error { t.Error("unexpected row"); return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Поврежденный файл не проходит проверку контрольной суммы
+func TestReader_ChecksumMismatch(t *testing.T) {
+	w, err := Create(t.TempDir(), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteRow("orders", []byte(`{"order_uid":"o1"}`))
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(w.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Manifest.Tables[0].SHA256 = "00"
+	if err := r.Rows("orders", func([]byte) error { return nil }); err == nil {
+		t.Fatal("expected checksum error")
+	}
+}
+
+// Abort после Commit удаляет зафиксированный архив
+func TestWriter_AbortAfterCommit(t *testing.T) {
+	root := t.TempDir()
+	w, _ := Create(root, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	w.WriteRow("orders", []byte(`{}`))
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 0 {
+		t.Errorf("archive not removed: %v", filepath.Join(root, entries[0].Name()))
+	}
+}
+
+// Rewrite заменяет архив с новыми контрольными суммами; архив без изменений не трогается
+func TestRewrite(t *testing.T) {
+	root := t.TempDir()
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	w, err := Create(root, month)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{`{"order_uid":"o1"}`, `{"order_uid":"o2"}`} {
+		if err := w.WriteRow("orders", []byte(row)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	created, err := Open(w.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := Rewrite(w.Dir(), func(table string, row []byte) ([]byte, error) { return row, nil })
+	if err != nil || changed {
+		t.Fatalf("identity rewrite: changed=%v err=%v", changed, err)
+	}
+
+	changed, err = Rewrite(w.Dir(), func(table string, row []byte) ([]byte, error) {
+		if string(row) == `{"order_uid":"o2"}` {
+			return []byte(`{"order_uid":"o2","erased":true}`), nil
+		}
+		return row, nil
+	})
+	if err != nil || !changed {
+		t.Fatalf("rewrite: changed=%v err=%v", changed, err)
+	}
+
+	dirs, err := List(root)
+	if err != nil || len(dirs) != 1 || dirs[0] != w.Dir() {
+		t.Fatalf("only the rewritten archive must remain: %v %v", dirs, err)
+	}
+	r, err := Open(w.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Manifest.CreatedAt.Equal(created.Manifest.CreatedAt) {
+		t.Errorf("created_at must be kept: %v", r.Manifest.CreatedAt)
+	}
+	var got []string
+	if err := r.Rows("orders", func(row []byte) error {
+		got = append(got, string(row))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1] != `{"order_uid":"o2","erased":true}` {
+		t.Errorf("unexpected rows: %v", got)
+	}
+}
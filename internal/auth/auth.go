@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Способы аутентификации
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Заголовок со статическим API ключом
+const APIKeyHeader = "X-API-Key"
+
+var (
+	// Учетные данные не переданы
+	ErrNoCredentials = errors.New("no credentials")
+	// Учетные данные переданы, но не прошли проверку
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Аутентифицированный вызывающий
+type Principal struct {
+	// Имя API ключа или subject токена
+	Subject string
+	Role    Role
+	Method  string
+}
+
+// Настройки аутентификации
+type Config struct {
+	// Статические ключи в формате "name:role:key", через запятую
+	APIKeys string
+	// Секрет для токенов HS256
+	HS256Secret string
+	// PEM файл с открытым ключом для токенов RS256
+	RS256PublicKeyFile string
+	// Локальный JWKS файл с открытыми ключами RS256 (выбор по kid)
+	JWKSFile string
+	// Ожидаемые iss и aud токена; пустые значения не проверяются
+	Issuer   string
+	Audience string
+}
+
+// Проверяет API ключи и JWT
+type Authenticator struct {
+	apiKeys []apiKey
+	jwt     *jwtVerifier
+}
+
+// Статический ключ; хранится только хэш
+type apiKey struct {
+	name string
+	role Role
+	hash [sha256.Size]byte
+}
+
+// Создание аутентификатора; ошибка, если не настроен ни один способ
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{}
+
+	for i, entry := range strings.Split(cfg.APIKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid API key entry #%d: expected name:role:key", i+1)
+		}
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("API key %s: %w", parts[0], err)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: parts[0], role: role, hash: sha256.Sum256([]byte(parts[2]))})
+	}
+
+	v, err := newJWTVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.jwt = v
+
+	if len(a.apiKeys) == 0 && a.jwt == nil {
+		return nil, errors.New("no API keys or JWT keys configured")
+	}
+	return a, nil
+}
+
+// Аутентификация запроса по заголовку X-API-Key или Authorization: Bearer.
+// Для SSE и WebSocket, где браузер не передает заголовки, токен можно передать
+// в параметре access_token
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.checkAPIKey(key)
+	}
+	if h := r.Header.Get("Authorization"); h != "" {
+		token, ok := strings.CutPrefix(h, "Bearer ")
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+		return a.checkToken(strings.TrimSpace(token))
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return a.checkToken(token)
+	}
+	return nil, ErrNoCredentials
+}
+
+// Токен без точек считается API ключом
+func (a *Authenticator) checkToken(token string) (*Principal, error) {
+	if strings.Count(token, ".") != 2 {
+		return a.checkAPIKey(token)
+	}
+	if a.jwt == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return a.jwt.verify(token)
+}
+
+// Сравнение хэшей за постоянное время, чтобы не раскрывать ключ по времени ответа
+func (a *Authenticator) checkAPIKey(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	var found *apiKey
+	for i := range a.apiKeys {
+		if subtle.ConstantTimeCompare(sum[:], a.apiKeys[i].hash[:]) == 1 {
+			found = &a.apiKeys[i]
+		}
+	}
+	if found == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: found.name, Role: found.role, Method: MethodAPIKey}, nil
+}
+
+type ctxKey struct{}
+
+// Сохраняет вызывающего в контексте запроса
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// Вызывающий из контекста; nil для анонимного запроса
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
+
+// Роль вызывающего из контекста; RoleNone для анонимного запроса
+func RoleFrom(ctx context.Context) Role {
+	if p := FromContext(ctx); p != nil {
+		return p.Role
+	}
+	return RoleNone
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func authenticate(t *testing.T, a *Authenticator, header, value string) (*Principal, error) {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/orders/1", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return a.Authenticate(r)
+}
+
+func hs256(t *testing.T, secret string, c jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Статические ключи: роль берется из конфигурации
+func TestAuthenticate_APIKey(t *testing.T) {
+	a, err := New(Config{APIKeys: "partner:reader:k1, ops:admin:k2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := authenticate(t, a, APIKeyHeader, "k2")
+	if err != nil || p.Subject != "ops" || p.Role != RoleAdmin {
+		t.Fatalf("unexpected principal: %+v, %v", p, err)
+	}
+	if _, err := authenticate(t, a, APIKeyHeader, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := authenticate(t, a, "", ""); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+// HS256: проверяются подпись, срок действия, issuer и роль
+func TestAuthenticate_HS256(t *testing.T) {
+	a, err := New(Config{HS256Secret: "s3cret", Issuer: "sso"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	token := hs256(t, "s3cret", jwt.MapClaims{"sub": "alice", "iss": "sso", "exp": exp, "roles": []string{"reader", "support"}})
+	p, err := authenticate(t, a, "Authorization", "Bearer "+token)
+	if err != nil || p.Subject != "alice" || p.Role != RoleSupport || p.Method != MethodJWT {
+		t.Fatalf("unexpected principal: %+v, %v", p, err)
+	}
+
+	bad := map[string]string{
+		"wrong secret":  hs256(t, "other", jwt.MapClaims{"iss": "sso", "exp": exp, "role": "admin"}),
+		"expired":       hs256(t, "s3cret", jwt.MapClaims{"iss": "sso", "exp": time.Now().Add(-time.Minute).Unix(), "role": "admin"}),
+		"no exp":        hs256(t, "s3cret", jwt.MapClaims{"iss": "sso", "role": "admin"}),
+		"wrong issuer":  hs256(t, "s3cret", jwt.MapClaims{"iss": "evil", "exp": exp, "role": "admin"}),
+		"no role":       hs256(t, "s3cret", jwt.MapClaims{"sub": "alice", "iss": "sso", "exp": exp}),
+		"no subject":    hs256(t, "s3cret", jwt.MapClaims{"iss": "sso", "exp": exp, "role": "admin"}),
+		"blank subject": hs256(t, "s3cret", jwt.MapClaims{"sub": " ", "iss": "sso", "exp": exp, "role": "admin"}),
+	}
+	for name, token := range bad {
+		if _, err := authenticate(t, a, "Authorization", "Bearer "+token); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+}
+
+// RS256: ключ выбирается из JWKS по kid, токен HS256 с тем же ключом не принимается
+func TestAuthenticate_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(Config{JWKSFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "svc", "exp": time.Now().Add(time.Hour).Unix(), "role": "admin"})
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := authenticate(t, a, "Authorization", "Bearer "+signed)
+	if err != nil || p.Role != RoleAdmin {
+		t.Fatalf("unexpected principal: %+v, %v", p, err)
+	}
+
+	tok.Header["kid"] = "unknown"
+	signed, _ = tok.SignedString(key)
+	if _, err := authenticate(t, a, "Authorization", "Bearer "+signed); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for unknown kid, got %v", err)
+	}
+}
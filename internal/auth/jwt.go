@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Утверждения токена: роль в claim role или список ролей в roles
+type claims struct {
+	jwt.RegisteredClaims
+	Role  string   `json:"role,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Самая сильная из известных ролей токена
+func (c *claims) role() Role {
+	best := RoleNone
+	for _, name := range append([]string{c.Role}, c.Roles...) {
+		if r, err := ParseRole(name); err == nil && r > best {
+			best = r
+		}
+	}
+	return best
+}
+
+// Проверка подписи и срока действия JWT по локально настроенным ключам
+type jwtVerifier struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	jwks      map[string]*rsa.PublicKey
+	parser    *jwt.Parser
+}
+
+// Создание проверяющего; nil, если ключи для JWT не настроены
+func newJWTVerifier(cfg Config) (*jwtVerifier, error) {
+	v := &jwtVerifier{}
+	var methods []string
+
+	if cfg.HS256Secret != "" {
+		v.secret = []byte(cfg.HS256Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RS256PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.RS256PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("RS256 public key: %w", err)
+		}
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("JWKS file: %w", err)
+		}
+		v.jwks = keys
+	}
+	if v.publicKey != nil || len(v.jwks) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Проверка токена; токен без известной роли или без sub не принимается:
+// по subject записываются владелец загруженных заказов и ключ ограничения частоты
+func (v *jwtVerifier) verify(raw string) (*Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(raw, &c, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if strings.TrimSpace(c.Subject) == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	role := c.role()
+	if role == RoleNone {
+		return nil, fmt.Errorf("%w: token has no known role", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: c.Subject, Role: role, Method: MethodJWT}, nil
+}
+
+// Выбор ключа проверки по алгоритму и kid токена
+func (v *jwtVerifier) key(t *jwt.Token) (any, error) {
+	switch t.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		if kid, ok := t.Header["kid"].(string); ok && v.jwks != nil {
+			if key, ok := v.jwks[kid]; ok {
+				return key, nil
+			}
+		}
+		if v.publicKey != nil {
+			return v.publicKey, nil
+		}
+		return nil, errors.New("unknown key id")
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+}
+
+// Ключ из JWKS (RFC 7517); поддерживаются только ключи RSA
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Загружает открытые ключи RSA из JWKS файла
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || !slices.Contains([]string{"", "RS256"}, k.Alg) {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+	return keys, nil
+}
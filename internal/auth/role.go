@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Роль вызывающего; каждая следующая роль включает права предыдущих
+type Role int
+
+const (
+	RoleNone Role = iota
+	// Чтение заказов и ленты новых заказов
+	RoleReader
+	// Поддержка: прием заказов и журнал обработанных сообщений
+	RoleSupport
+	// Администрирование: webhook и служебные эндпоинты
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleNone:    "none",
+	RoleReader:  "reader",
+	RoleSupport: "support",
+	RoleAdmin:   "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// Включает ли роль права роли required
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// Разбор названия роли (reader, support, admin)
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if role != RoleNone && strings.EqualFold(s, name) {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", s)
+}
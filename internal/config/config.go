@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,7 +17,73 @@ type Config struct {
 	DBPassword  string
 	DBName      string
 	KafkaBroker string
+	KafkaTopic  string
 	ServicePort int
+	// Куда направлять заказы, принятые через HTTP: "db" или "kafka"
+	IngestMode string
+	// Топик для событий order.persisted / order.updated
+	OutboxTopic        string
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	// Сколько хранятся опубликованные события outbox; 0 — хранятся всегда
+	OutboxRetention time.Duration
+	// Доставка webhook
+	WebhookWorkers      int
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookPollInterval time.Duration
+	// Трассировка: "none", "stdout" или "otlp"
+	TracingExporter    string
+	TracingFile        string
+	TracingSampleRatio float64
+	// Логирование: уровень (debug, info, warn, error) и формат (json, text)
+	LogLevel  string
+	LogFormat string
+	// Максимальное отставание consumer, при котором сервис считается готовым
+	ReadyMaxLag int
+	// Язык ответов, если клиент его не указал (ru, en)
+	DefaultLang string
+	// Аутентификация HTTP API; выключать только для локальной разработки
+	AuthDisabled bool
+	// Статические API ключи "name:role:key" через запятую
+	AuthAPIKeys string
+	// Ключи проверки JWT: секрет HS256, PEM ключ RS256, JWKS файл
+	AuthJWTSecret        string
+	AuthJWTPublicKeyFile string
+	AuthJWKSFile         string
+	// Ожидаемые iss и aud токенов
+	AuthJWTIssuer   string
+	AuthJWTAudience string
+	// Правила скрытия персональных данных "field:action:role" через запятую;
+	// пустое значение — правила по умолчанию
+	RedactRules string
+	// Файл ключей шифрования персональных данных; пустое значение выключает шифрование
+	EncryptionKeyFile string
+	// Размер пачки при перешифровании существующих строк
+	EncryptionMigrateBatch int
+	// Лимиты запросов "класс=rate:burst" через запятую (классы read, write, admin);
+	// пустое значение — лимиты по умолчанию
+	RateLimits        string
+	RateLimitDisabled bool
+	// Брать IP клиента из X-Forwarded-For; включать только за доверенным прокси
+	RateLimitTrustForwardedFor bool
+	// Cache-Control ответа GET /orders/{id}; пустое значение — "private, no-cache"
+	OrderCacheControl string
+	// Origin страниц других доменов, которым разрешена лента заказов через WebSocket
+	StreamAllowedOrigins []string
+	// Читать выручку, разбивку по службе доставки и провайдеру и сводку из дневных агрегатов
+	AnalyticsRollups bool
+	// Каталог архивов партиций заказов
+	ArchiveDir string
+	// Сколько полных месяцев до текущего хранить в БД; 0 выключает архивацию
+	RetentionMonths   int
+	RetentionInterval time.Duration
+	// Через сколько после мягкого удаления заказ удаляется окончательно
+	DeletedOrdersGrace time.Duration
+	PurgeInterval      time.Duration
+	// Сколько хранится ключ идемпотентности POST /orders
+	IdempotencyTTL             time.Duration
+	IdempotencyCleanupInterval time.Duration
 }
 
 // Функция загрузки переменных окружения из env
@@ -28,7 +96,62 @@ func Load() *Config {
 		DBPassword:  getEnv("DB_PASSWORD", "secret"),
 		DBName:      getEnv("DB_NAME", "orders_db"),
 		KafkaBroker: getEnv("KAFKA_BROKER", "localhost:9092"),
+		KafkaTopic:  getEnv("KAFKA_TOPIC", "orders-topic"),
 		ServicePort: getEnvAsInt("SERVICE_PORT", 8080),
+		IngestMode:  getEnv("INGEST_MODE", "db"),
+
+		OutboxTopic:        getEnv("OUTBOX_TOPIC", "orders-events"),
+		OutboxPollInterval: getEnvAsInterval("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxBatchSize:    getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetention:    getEnvAsDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		WebhookWorkers:      getEnvAsInt("WEBHOOK_WORKERS", 4),
+		WebhookTimeout:      getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:  getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookPollInterval: getEnvAsInterval("WEBHOOK_POLL_INTERVAL", 2*time.Second),
+
+		TracingExporter:    getEnv("TRACING_EXPORTER", "none"),
+		TracingFile:        getEnv("TRACING_FILE", ""),
+		TracingSampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		ReadyMaxLag: getEnvAsInt("READY_MAX_LAG", 10000),
+
+		DefaultLang: getEnv("DEFAULT_LANG", "ru"),
+
+		AuthDisabled:         getEnvAsBool("AUTH_DISABLED", false),
+		AuthAPIKeys:          getEnv("AUTH_API_KEYS", ""),
+		AuthJWTSecret:        getEnv("AUTH_JWT_HS256_SECRET", ""),
+		AuthJWTPublicKeyFile: getEnv("AUTH_JWT_RS256_PUBLIC_KEY_FILE", ""),
+		AuthJWKSFile:         getEnv("AUTH_JWKS_FILE", ""),
+		AuthJWTIssuer:        getEnv("AUTH_JWT_ISSUER", ""),
+		AuthJWTAudience:      getEnv("AUTH_JWT_AUDIENCE", ""),
+
+		RedactRules: getEnv("REDACT_RULES", ""),
+
+		EncryptionKeyFile:      getEnv("ENCRYPTION_KEYFILE", ""),
+		EncryptionMigrateBatch: getEnvAsInt("ENCRYPTION_MIGRATE_BATCH", 500),
+
+		RateLimits:                 getEnv("RATE_LIMITS", ""),
+		RateLimitDisabled:          getEnvAsBool("RATE_LIMIT_DISABLED", false),
+		RateLimitTrustForwardedFor: getEnvAsBool("RATE_LIMIT_TRUST_FORWARDED_FOR", false),
+
+		OrderCacheControl:    getEnv("ORDER_CACHE_CONTROL", ""),
+		StreamAllowedOrigins: getEnvAsList("STREAM_ALLOWED_ORIGINS"),
+
+		AnalyticsRollups: getEnvAsBool("ANALYTICS_ROLLUPS", false),
+
+		ArchiveDir:        getEnv("ARCHIVE_DIR", "./archive"),
+		RetentionMonths:   getEnvAsInt("RETENTION_MONTHS", 0),
+		RetentionInterval: getEnvAsInterval("RETENTION_INTERVAL", time.Hour),
+
+		DeletedOrdersGrace: getEnvAsDuration("DELETED_ORDERS_GRACE", 30*24*time.Hour),
+		PurgeInterval:      getEnvAsInterval("PURGE_INTERVAL", time.Hour),
+
+		IdempotencyTTL:             getEnvAsDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyCleanupInterval: getEnvAsInterval("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour),
 	}
 }
 
@@ -48,3 +171,46 @@ func getEnvAsInt(name string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// Вспомогательная функция для получения длительности из env (например, "500ms", "5s")
+func getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
+	if val, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		return val
+	}
+	return defaultVal
+}
+
+// Период фоновой задачи из env; нулевой или отрицательный период заменяется дефолтным
+func getEnvAsInterval(name string, defaultVal time.Duration) time.Duration {
+	if val := getEnvAsDuration(name, defaultVal); val > 0 {
+		return val
+	}
+	return defaultVal
+}
+
+// Список значений из env через запятую; пустые элементы отбрасываются
+func getEnvAsList(name string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Вспомогательная функция для получения float из env или задания дефолтного значения
+func getEnvAsFloat(name string, defaultVal float64) float64 {
+	if val, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return val
+	}
+	return defaultVal
+}
+
+// Вспомогательная функция для получения bool из env (true/false, 1/0)
+func getEnvAsBool(name string, defaultVal bool) bool {
+	if val, err := strconv.ParseBool(os.Getenv(name)); err == nil {
+		return val
+	}
+	return defaultVal
+}
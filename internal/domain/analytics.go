@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+// Шаг временного ряда аналитики
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// Измерения разбивки заказов
+const (
+	DimensionDeliveryService = "delivery_service"
+	DimensionProvider        = "provider"
+	DimensionBank            = "bank"
+	DimensionEntry           = "entry"
+)
+
+// Группировка и сортировка топа позиций
+const (
+	TopByBrand    = "brand"
+	TopByNmID     = "nm_id"
+	TopByQuantity = "quantity"
+	TopByRevenue  = "revenue"
+)
+
+// Период и валюта для агрегатов; заказы берутся по date_created в [From, To)
+type AnalyticsFilter struct {
+	From time.Time
+	To   time.Time
+	// Пустая строка — все валюты
+	Currency string
+}
+
+// Число заказов и выручка за период в одной валюте
+type RevenuePoint struct {
+	Period   time.Time `json:"period" db:"period"`
+	Currency string    `json:"currency" db:"currency"`
+	Orders   int64     `json:"orders" db:"orders"`
+	Revenue  int64     `json:"revenue" db:"revenue"`
+}
+
+// Число заказов и выручка по значению измерения в одной валюте
+type BreakdownRow struct {
+	Key      string `json:"key" db:"key"`
+	Currency string `json:"currency" db:"currency"`
+	Orders   int64  `json:"orders" db:"orders"`
+	Revenue  int64  `json:"revenue" db:"revenue"`
+}
+
+// Бренд или nm_id с числом проданных позиций и выручкой по total_price
+type TopItem struct {
+	Key      string `json:"key" db:"key"`
+	Currency string `json:"currency" db:"currency"`
+	Quantity int64  `json:"quantity" db:"quantity"`
+	Revenue  int64  `json:"revenue" db:"revenue"`
+}
+
+// Сводка по валюте: средняя стоимость доставки и доля позиций со скидкой
+type AnalyticsSummary struct {
+	Currency        string  `json:"currency" db:"currency"`
+	Orders          int64   `json:"orders" db:"orders"`
+	Revenue         int64   `json:"revenue" db:"revenue"`
+	AvgDeliveryCost float64 `json:"avg_delivery_cost" db:"avg_delivery_cost"`
+	Items           int64   `json:"items" db:"items"`
+	// Доля позиций с sale > 0
+	SaleRate float64 `json:"sale_rate" db:"sale_rate"`
+	// Средняя скидка по позициям, %
+	AvgSale float64 `json:"avg_sale" db:"avg_sale"`
+}
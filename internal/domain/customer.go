@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Выгрузка всех данных клиента по запросу субъекта данных
+type CustomerExport struct {
+	CustomerID string    `json:"customer_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	Orders     []*Order  `json:"orders"`
+	// Заказы из Orders, помеченные удаленными, но еще не удаленные окончательно
+	DeletedOrderUIDs []string `json:"deleted_order_uids,omitempty"`
+}
+
+// Финансовые итоги удаленных заказов в одной валюте
+type ErasureTotal struct {
+	Currency     string `json:"currency" db:"currency"`
+	Orders       int    `json:"orders" db:"orders"`
+	Amount       int64  `json:"amount" db:"amount"`
+	GoodsTotal   int64  `json:"goods_total" db:"goods_total"`
+	DeliveryCost int64  `json:"delivery_cost" db:"delivery_cost"`
+	CustomFee    int64  `json:"custom_fee" db:"custom_fee"`
+}
+
+// Запись аудита об удалении персональных данных клиента. Вместо customer_id
+// хранится его HMAC, по которому можно подтвердить факт удаления
+type ErasureRecord struct {
+	ID          int64          `json:"id"`
+	CustomerRef string         `json:"customer_ref"`
+	OrderUIDs   []string       `json:"order_uids"`
+	Totals      []ErasureTotal `json:"totals"`
+	RequestedBy string         `json:"requested_by"`
+	Reason      string         `json:"reason"`
+	ErasedAt    time.Time      `json:"erased_at"`
+}
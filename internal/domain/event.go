@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Типы событий о заказах для внешних потребителей
+const (
+	EventOrderPersisted = "order.persisted"
+	EventOrderUpdated   = "order.updated"
+)
+
+// Событие о сохранении заказа
+type OrderEvent struct {
+	Type       string    `json:"type"`
+	OrderUID   string    `json:"order_uid"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      *Order    `json:"order"`
+}
+
+// Запись transactional outbox, ожидающая публикации
+type OutboxEvent struct {
+	ID          int64     `db:"id"`
+	AggregateID string    `db:"aggregate_id"`
+	EventType   string    `db:"event_type"`
+	Payload     []byte    `db:"payload"`
+	CreatedAt   time.Time `db:"created_at"`
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// Заголовок Kafka с уникальным идентификатором сообщения
+const MessageIDHeader = "message-id"
+
+// Координаты сообщения Kafka, из которого получен заказ
+type MessageRef struct {
+	Topic     string `json:"topic" db:"topic"`
+	Partition int    `json:"partition" db:"partition"`
+	Offset    int64  `json:"offset" db:"offset"`
+	// Пустой, если продюсер не передал заголовок message-id
+	MessageID string `json:"message_id,omitempty" db:"message_id"`
+}
+
+// Запись журнала обработанных сообщений
+type ProcessedMessage struct {
+	MessageRef
+	OrderUID    string    `json:"order_uid" db:"order_uid"`
+	ProcessedAt time.Time `json:"processed_at" db:"processed_at"`
+}
+
+// Фильтр для выборки из журнала обработанных сообщений
+type ProcessedMessageFilter struct {
+	Topic     string
+	Partition *int
+	OrderUID  string
+	MessageID string
+	Limit     int
+}
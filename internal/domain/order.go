@@ -4,58 +4,81 @@ import "time"
 
 // Структура заказа
 type Order struct {
-	OrderUID          string    `json:"order_uid" db:"order_uid"`
-	TrackNumber       string    `json:"track_number" db:"track_number"`
-	Entry             string    `json:"entry" db:"entry"`
-	Delivery          Delivery  `json:"delivery" db:"-"`
-	Payment           Payment   `json:"payment" db:"-"`
-	Items             []Item    `json:"items" db:"-"`
-	Locale            string    `json:"locale" db:"locale"`
-	InternalSignature string    `json:"internal_signature" db:"internal_signature"`
-	CustomerID        string    `json:"customer_id" db:"customer_id"`
-	DeliveryService   string    `json:"delivery_service" db:"delivery_service"`
-	ShardKey          string    `json:"shardkey" db:"shardkey"`
-	SmID              int       `json:"sm_id" db:"sm_id"`
-	DateCreated       time.Time `json:"date_created" db:"date_created"`
-	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+	OrderUID          string    `json:"order_uid" db:"order_uid" xml:"order_uid"`
+	TrackNumber       string    `json:"track_number" db:"track_number" xml:"track_number"`
+	Entry             string    `json:"entry" db:"entry" xml:"entry"`
+	Delivery          Delivery  `json:"delivery" db:"-" xml:"delivery"`
+	Payment           Payment   `json:"payment" db:"-" xml:"payment"`
+	Items             []Item    `json:"items" db:"-" xml:"items>item"`
+	Locale            string    `json:"locale" db:"locale" xml:"locale"`
+	InternalSignature string    `json:"internal_signature" db:"internal_signature" xml:"internal_signature"`
+	CustomerID        string    `json:"customer_id" db:"customer_id" xml:"customer_id"`
+	DeliveryService   string    `json:"delivery_service" db:"delivery_service" xml:"delivery_service"`
+	ShardKey          string    `json:"shardkey" db:"shardkey" xml:"shardkey"`
+	SmID              int       `json:"sm_id" db:"sm_id" xml:"sm_id"`
+	DateCreated       time.Time `json:"date_created" db:"date_created" xml:"date_created"`
+	OofShard          string    `json:"oof_shard" db:"oof_shard" xml:"oof_shard"`
+	// Время последнего сохранения в БД; в JSON заказа не входит
+	UpdatedAt time.Time `json:"-" db:"updated_at" xml:"-"`
+	// Заказ помечен удаленным; такой заказ не отдается клиентам и не кэшируется
+	Deleted bool `json:"-" db:"-" xml:"-"`
+}
+
+// Пометка мягкого удаления заказа
+type DeletedOrder struct {
+	OrderUID    string    `json:"order_uid" db:"order_uid"`
+	DateCreated time.Time `json:"date_created" db:"date_created"`
+	DeletedAt   time.Time `json:"deleted_at" db:"deleted_at"`
+	DeletedBy   string    `json:"deleted_by" db:"deleted_by"`
+	Reason      string    `json:"reason" db:"delete_reason"`
 }
 
 // Структура доставки
 type Delivery struct {
-	Name    string `json:"name" db:"name"`
-	Phone   string `json:"phone" db:"phone"`
-	Zip     string `json:"zip" db:"zip"`
-	City    string `json:"city" db:"city"`
-	Address string `json:"address" db:"address"`
-	Region  string `json:"region" db:"region"`
-	Email   string `json:"email" db:"email"`
+	Name    string `json:"name" db:"name" xml:"name"`
+	Phone   string `json:"phone" db:"phone" xml:"phone"`
+	Zip     string `json:"zip" db:"zip" xml:"zip"`
+	City    string `json:"city" db:"city" xml:"city"`
+	Address string `json:"address" db:"address" xml:"address"`
+	Region  string `json:"region" db:"region" xml:"region"`
+	Email   string `json:"email" db:"email" xml:"email"`
 }
 
 // Структура платежа
 type Payment struct {
-	Transaction  string `json:"transaction" db:"transaction"`
-	RequestID    string `json:"request_id" db:"request_id"`
-	Currency     string `json:"currency" db:"currency"`
-	Provider     string `json:"provider" db:"provider"`
-	Amount       int    `json:"amount" db:"amount"`
-	PaymentDt    int64  `json:"payment_dt" db:"payment_dt"`
-	Bank         string `json:"bank" db:"bank"`
-	DeliveryCost int    `json:"delivery_cost" db:"delivery_cost"`
-	GoodsTotal   int    `json:"goods_total" db:"goods_total"`
-	CustomFee    int    `json:"custom_fee" db:"custom_fee"`
+	Transaction  string `json:"transaction" db:"transaction" xml:"transaction"`
+	RequestID    string `json:"request_id" db:"request_id" xml:"request_id"`
+	Currency     string `json:"currency" db:"currency" xml:"currency"`
+	Provider     string `json:"provider" db:"provider" xml:"provider"`
+	Amount       int    `json:"amount" db:"amount" xml:"amount"`
+	PaymentDt    int64  `json:"payment_dt" db:"payment_dt" xml:"payment_dt"`
+	Bank         string `json:"bank" db:"bank" xml:"bank"`
+	DeliveryCost int    `json:"delivery_cost" db:"delivery_cost" xml:"delivery_cost"`
+	GoodsTotal   int    `json:"goods_total" db:"goods_total" xml:"goods_total"`
+	CustomFee    int    `json:"custom_fee" db:"custom_fee" xml:"custom_fee"`
 }
 
 // Структура предмета
 type Item struct {
-	ChrtID      int    `json:"chrt_id" db:"chrt_id"`
-	TrackNumber string `json:"track_number" db:"track_number"`
-	Price       int    `json:"price" db:"price"`
-	RID         string `json:"rid" db:"rid"`
-	Name        string `json:"name" db:"name"`
-	Sale        int    `json:"sale" db:"sale"`
-	Size        string `json:"size" db:"size"`
-	TotalPrice  int    `json:"total_price" db:"total_price"`
-	NmID        int    `json:"nm_id" db:"nm_id"`
-	Brand       string `json:"brand" db:"brand"`
-	Status      int    `json:"status" db:"status"`
+	ChrtID      int    `json:"chrt_id" db:"chrt_id" xml:"chrt_id"`
+	TrackNumber string `json:"track_number" db:"track_number" xml:"track_number"`
+	Price       int    `json:"price" db:"price" xml:"price"`
+	RID         string `json:"rid" db:"rid" xml:"rid"`
+	Name        string `json:"name" db:"name" xml:"name"`
+	Sale        int    `json:"sale" db:"sale" xml:"sale"`
+	Size        string `json:"size" db:"size" xml:"size"`
+	TotalPrice  int    `json:"total_price" db:"total_price" xml:"total_price"`
+	NmID        int    `json:"nm_id" db:"nm_id" xml:"nm_id"`
+	Brand       string `json:"brand" db:"brand" xml:"brand"`
+	Status      int    `json:"status" db:"status" xml:"status"`
 }
+
+// Связанные сущности заказа, которые нужно загрузить из БД
+type OrderParts struct {
+	Delivery bool
+	Payment  bool
+	Items    bool
+}
+
+// Заказ целиком
+var AllOrderParts = OrderParts{Delivery: true, Payment: true, Items: true}
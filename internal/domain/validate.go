@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ошибка валидации. Key — ключ сообщения в каталоге переводов,
+// Args — его параметры; Error() возвращает текст на английском для логов
+type ValidationError struct {
+	Key     string
+	Args    []any
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Создание ошибки валидации
+func NewValidationError(key, message string, args ...any) *ValidationError {
+	return &ValidationError{Key: key, Args: args, Message: message}
+}
+
+// Проверка корректности заказа, общая для Kafka и HTTP
+func ValidateOrder(order *Order) error {
+	if order.OrderUID == "" {
+		return NewValidationError("validation.order_uid_required", "order_uid is required")
+	}
+	if order.Payment.Amount < 0 || order.Payment.Currency == "" {
+		return NewValidationError("validation.payment_invalid", "payment amount must be non-negative and currency is required")
+	}
+	if order.Delivery.Email == "" || !strings.Contains(order.Delivery.Email, "@") {
+		return NewValidationError("validation.email_invalid", "delivery email is invalid")
+	}
+	if len(order.Items) == 0 {
+		return NewValidationError("validation.items_required", "order must contain at least one item")
+	}
+
+	for i, item := range order.Items {
+		if item.Name == "" || item.Price < 0 || item.TotalPrice < 0 {
+			return NewValidationError("validation.item_invalid", fmt.Sprintf("item %d is invalid", i), i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+// Статусы доставки webhook
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Подписка партнера на события о заказах.
+// Пустые Entry, DeliveryService и EventTypes означают «любые»
+type WebhookSubscription struct {
+	ID              int64     `json:"id"`
+	URL             string    `json:"url"`
+	Secret          string    `json:"secret,omitempty"`
+	Entry           string    `json:"entry"`
+	DeliveryService string    `json:"delivery_service"`
+	EventTypes      []string  `json:"event_types"`
+	Active          bool      `json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Попытка доставки события подписчику
+type WebhookDelivery struct {
+	ID             int64      `json:"id" db:"id"`
+	SubscriptionID int64      `json:"subscription_id" db:"subscription_id"`
+	EventID        int64      `json:"event_id" db:"event_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	OrderUID       string     `json:"order_uid" db:"order_uid"`
+	Payload        []byte     `json:"-" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	LastError      string     `json:"last_error,omitempty" db:"last_error"`
+	ResponseCode   int        `json:"response_code,omitempty" db:"response_code"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+}
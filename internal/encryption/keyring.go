@@ -0,0 +1,260 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Префикс зашифрованного значения; значения без него считаются открытым текстом
+// (строки, записанные до включения шифрования)
+const prefix = "enc:v1:"
+
+// Размер ключей: AES-256 и HMAC-SHA256
+const keySize = 32
+
+// Связка ключей шифрования (KEK) с активным ключом для новых значений
+// и отдельным ключом для слепых индексов
+type Keyring struct {
+	active   string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+// Формат файла ключей; ключи в base64
+type keyFile struct {
+	ActiveKey string            `json:"active_key"`
+	Keys      map[string]string `json:"keys"`
+	IndexKey  string            `json:"index_key"`
+}
+
+// Загружает ключи из локального JSON файла
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("keyfile: %w", err)
+	}
+
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, encoded := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("keyfile: key %s: %w", id, err)
+		}
+		keys[id] = key
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(f.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("keyfile: index_key: %w", err)
+	}
+	return NewKeyring(f.ActiveKey, keys, indexKey)
+}
+
+// Создание связки ключей; все ключи должны быть длиной 32 байта
+func NewKeyring(active string, keys map[string][]byte, indexKey []byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", active)
+	}
+	for id, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %s must be %d bytes", id, keySize)
+		}
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key id %q must not contain ':'", id)
+		}
+	}
+	if len(indexKey) != keySize {
+		return nil, fmt.Errorf("index key must be %d bytes", keySize)
+	}
+	return &Keyring{active: active, keys: keys, indexKey: indexKey}, nil
+}
+
+// Идентификатор ключа, которым шифруются новые значения
+func (k *Keyring) ActiveKey() string {
+	return k.active
+}
+
+// Префикс значений, зашифрованных активным ключом (для поиска устаревших строк)
+func (k *Keyring) ActivePrefix() string {
+	return prefix + k.active + ":"
+}
+
+// Зашифровано ли значение
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Шифрует значение конвертом: данные — случайным ключом (DEK), DEK — активным ключом.
+// aad привязывает шифротекст к колонке и строке, чтобы значения нельзя было переставить.
+// Пустая строка не шифруется
+func (k *Keyring) Encrypt(plaintext, aad string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := seal(dek, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(k.active, dek, data, aad)
+}
+
+// Расшифровывает значение; открытый текст (строки до включения шифрования) возвращается как есть
+func (k *Keyring) Decrypt(value, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	dek, data, err := k.unwrap(value, aad)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, data, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Нужно ли перезаписать значение: открытый текст или ключ, отличный от активного
+func (k *Keyring) NeedsRewrap(value string) bool {
+	return value != "" && !strings.HasPrefix(value, k.ActivePrefix())
+}
+
+// Переводит значение на активный ключ. Для зашифрованного значения
+// перешифровывается только DEK, сами данные не расшифровываются
+func (k *Keyring) Rewrap(value, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return k.Encrypt(value, aad)
+	}
+	if !k.NeedsRewrap(value) {
+		return value, nil
+	}
+	dek, data, err := k.unwrap(value, aad)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(k.active, dek, data, aad)
+}
+
+// Формат: enc:v1:<key id>:<DEK, зашифрованный ключом>:<данные, зашифрованные DEK>
+func (k *Keyring) wrap(keyID string, dek, data []byte, aad string) (string, error) {
+	wrapped, err := seal(k.keys[keyID], dek, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return prefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (k *Keyring) unwrap(value, aad string) (dek, data []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("malformed encrypted value")
+	}
+	key, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown encryption key %q", parts[0])
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	data, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	dek, err = open(key, wrapped, []byte(aad))
+	if err != nil {
+		return nil, nil, err
+	}
+	return dek, data, nil
+}
+
+// Слепой индекс: детерминированный HMAC нормализованного значения.
+// Позволяет искать по равенству, не храня значение в открытом виде
+func (k *Keyring) BlindIndex(kind, value string) string {
+	value = Normalize(kind, value)
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(kind + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Виды значений для слепых индексов
+const (
+	KindEmail    = "email"
+	KindPhone    = "phone"
+	KindCustomer = "customer"
+)
+
+// Приводит значение к виду для сравнения: email без регистра, телефон — только цифры
+func Normalize(kind, value string) string {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case KindEmail:
+		return strings.ToLower(value)
+	case KindPhone:
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, value)
+	}
+	return value
+}
+
+// AES-256-GCM; nonce записывается перед шифротекстом
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, errors.New("decryption failed: wrong key or tampered value")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
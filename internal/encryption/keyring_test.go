@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, active string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(active, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, keySize),
+		"k2": bytes.Repeat([]byte{2}, keySize),
+	}, bytes.Repeat([]byte{9}, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// Шифрование и расшифровка, привязка к aad
+func TestEncryptDecrypt(t *testing.T) {
+	k := testKeyring(t, "k1")
+
+	enc, err := k.Encrypt("test@gmail.com", "delivery.email|o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, "enc:v1:k1:") || strings.Contains(enc, "gmail") {
+		t.Fatalf("unexpected ciphertext: %s", enc)
+	}
+
+	got, err := k.Decrypt(enc, "delivery.email|o1")
+	if err != nil || got != "test@gmail.com" {
+		t.Fatalf("decrypt: %q, %v", got, err)
+	}
+	if _, err := k.Decrypt(enc, "delivery.email|o2"); err == nil {
+		t.Error("value moved to another row must not decrypt")
+	}
+
+	// Строки, записанные до включения шифрования, читаются как есть
+	if got, _ := k.Decrypt("plain", "x"); got != "plain" {
+		t.Errorf("plaintext passthrough: %q", got)
+	}
+}
+
+// После ротации старые значения читаются и переводятся на новый ключ
+func TestRewrap(t *testing.T) {
+	old := testKeyring(t, "k1")
+	enc, _ := old.Encrypt("+9720000000", "delivery.phone|o1")
+
+	k := testKeyring(t, "k2")
+	if !k.NeedsRewrap(enc) || !k.NeedsRewrap("plain") || k.NeedsRewrap("") {
+		t.Fatal("unexpected NeedsRewrap result")
+	}
+
+	rewrapped, err := k.Rewrap(enc, "delivery.phone|o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rewrapped, k.ActivePrefix()) || k.NeedsRewrap(rewrapped) {
+		t.Fatalf("not rewrapped: %s", rewrapped)
+	}
+	if got, err := k.Decrypt(rewrapped, "delivery.phone|o1"); err != nil || got != "+9720000000" {
+		t.Fatalf("decrypt after rewrap: %q, %v", got, err)
+	}
+}
+
+// Слепой индекс не зависит от формы записи и ключа шифрования
+func TestBlindIndex(t *testing.T) {
+	k1, k2 := testKeyring(t, "k1"), testKeyring(t, "k2")
+
+	if k1.BlindIndex(KindEmail, "Test@Gmail.com ") != k2.BlindIndex(KindEmail, "test@gmail.com") {
+		t.Error("email index must be case-insensitive and stable across key rotation")
+	}
+	if k1.BlindIndex(KindPhone, "+972 000-00-00") != k1.BlindIndex(KindPhone, "+9720000000") {
+		t.Error("phone index must ignore formatting")
+	}
+	if k1.BlindIndex(KindEmail, "a@b.c") == k1.BlindIndex(KindPhone, "a@b.c") {
+		t.Error("indexes of different kinds must differ")
+	}
+}
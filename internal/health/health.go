@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Статусы проверок
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Проверка одной зависимости; nil означает, что зависимость готова
+type Check func(ctx context.Context) error
+
+// Результат одной проверки
+type Result struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Итог всех проверок
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Набор проверок готовности сервиса
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// Создание набора проверок; каждая проверка ограничена timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Добавляет проверку под именем name
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Выполняет все проверки параллельно
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(c.checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := nc.check(checkCtx)
+			res := Result{Status: StatusUp, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				res.Status = StatusDown
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[nc.name] = res
+			if err != nil {
+				report.Status = StatusDown
+			}
+			mu.Unlock()
+		}(nc)
+	}
+	wg.Wait()
+
+	return report
+}
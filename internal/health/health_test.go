@@ -0,0 +1,36 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Одна упавшая проверка делает сервис неготовым
+func TestChecker_Run(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("db", func(ctx context.Context) error { return nil })
+	c.Add("kafka", func(ctx context.Context) error { return errors.New("broker unreachable") })
+
+	report := c.Run(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("expected down, got %s", report.Status)
+	}
+	if report.Checks["db"].Status != StatusUp || report.Checks["kafka"].Error != "broker unreachable" {
+		t.Errorf("unexpected checks: %+v", report.Checks)
+	}
+}
+
+// Зависшая проверка прерывается по таймауту
+func TestChecker_Timeout(t *testing.T) {
+	c := NewChecker(10 * time.Millisecond)
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if report := c.Run(context.Background()); report.Checks["slow"].Status != StatusDown {
+		t.Errorf("expected timed out check to be down: %+v", report)
+	}
+}
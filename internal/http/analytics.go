@@ -0,0 +1,147 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/service"
+)
+
+// Период аналитики, если from не задан
+const defaultAnalyticsRange = 30 * 24 * time.Hour
+
+// Размер топа позиций по умолчанию и максимальный
+const (
+	defaultTopItemsLimit = 10
+	maxTopItemsLimit     = 100
+)
+
+// Заказы и выручка по дням, неделям или месяцам и валютам
+func (h *Handler) AnalyticsRevenue(w http.ResponseWriter, r *http.Request) {
+	filter, ok := analyticsFilter(w, r)
+	if !ok {
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	switch interval {
+	case "":
+		interval = domain.IntervalDay
+	case domain.IntervalDay, domain.IntervalWeek, domain.IntervalMonth:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "interval")
+		return
+	}
+
+	points, err := h.analytics.Revenue(r.Context(), filter, interval)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+// Заказы и выручка по службе доставки, платежному провайдеру, банку или entry
+func (h *Handler) AnalyticsBreakdown(w http.ResponseWriter, r *http.Request) {
+	filter, ok := analyticsFilter(w, r)
+	if !ok {
+		return
+	}
+	by := r.URL.Query().Get("by")
+	switch by {
+	case domain.DimensionDeliveryService, domain.DimensionProvider, domain.DimensionBank, domain.DimensionEntry:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "by")
+		return
+	}
+
+	rows, err := h.analytics.Breakdown(r.Context(), filter, by)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// Топ брендов или nm_id по числу позиций или выручке
+func (h *Handler) AnalyticsTopItems(w http.ResponseWriter, r *http.Request) {
+	filter, ok := analyticsFilter(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	by := q.Get("by")
+	switch by {
+	case "":
+		by = domain.TopByBrand
+	case domain.TopByBrand, domain.TopByNmID:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "by")
+		return
+	}
+	sortBy := q.Get("sort")
+	switch sortBy {
+	case "":
+		sortBy = domain.TopByQuantity
+	case domain.TopByQuantity, domain.TopByRevenue:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "sort")
+		return
+	}
+	limit := defaultTopItemsLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxTopItemsLimit {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "limit")
+			return
+		}
+		limit = n
+	}
+
+	items, err := h.analytics.TopItems(r.Context(), filter, by, sortBy, limit)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// Средняя стоимость доставки и доля позиций со скидкой по валютам
+func (h *Handler) AnalyticsSummary(w http.ResponseWriter, r *http.Request) {
+	filter, ok := analyticsFilter(w, r)
+	if !ok {
+		return
+	}
+	summary, err := h.analytics.Summary(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// Разбирает from, to и currency; по умолчанию — последние 30 дней.
+// false — ответ с ошибкой уже записан
+func analyticsFilter(w http.ResponseWriter, r *http.Request) (domain.AnalyticsFilter, bool) {
+	q := r.URL.Query()
+	filter := domain.AnalyticsFilter{To: time.Now().UTC(), Currency: q.Get("currency")}
+	if v := q.Get("to"); v != "" {
+		to, err := parseTimeParam(v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "to")
+			return filter, false
+		}
+		filter.To = to
+	}
+	filter.From = filter.To.Add(-defaultAnalyticsRange)
+	if v := q.Get("from"); v != "" {
+		from, err := parseTimeParam(v)
+		if err != nil || !filter.To.After(from) {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "from")
+			return filter, false
+		}
+		filter.From = from
+	}
+	return filter, true
+}
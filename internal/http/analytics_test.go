@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Запоминает параметры последнего запроса
+type fakeAnalytics struct {
+	filter domain.AnalyticsFilter
+	arg    string
+	limit  int
+}
+
+func (f *fakeAnalytics) Revenue(ctx context.Context, filter domain.AnalyticsFilter, interval string) ([]domain.RevenuePoint, error) {
+	f.filter, f.arg = filter, interval
+	return []domain.RevenuePoint{{Currency: "USD", Orders: 2, Revenue: 3634}}, nil
+}
+
+func (f *fakeAnalytics) Breakdown(ctx context.Context, filter domain.AnalyticsFilter, dimension string) ([]domain.BreakdownRow, error) {
+	f.filter, f.arg = filter, dimension
+	return []domain.BreakdownRow{}, nil
+}
+
+func (f *fakeAnalytics) TopItems(ctx context.Context, filter domain.AnalyticsFilter, by, sortBy string, limit int) ([]domain.TopItem, error) {
+	f.filter, f.arg, f.limit = filter, by+":"+sortBy, limit
+	return []domain.TopItem{}, nil
+}
+
+func (f *fakeAnalytics) Summary(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.AnalyticsSummary, error) {
+	f.filter = filter
+	return []domain.AnalyticsSummary{}, nil
+}
+
+func TestAnalytics_Parameters(t *testing.T) {
+	fake := &fakeAnalytics{}
+	router := NewRouter(RouterDeps{Analytics: fake})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/analytics/revenue?from=2025-03-01&to=2025-04-01&interval=week&currency=USD")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revenue: got %d", rec.Code)
+	}
+	want := domain.AnalyticsFilter{
+		From:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		Currency: "USD",
+	}
+	if fake.filter != want || fake.arg != domain.IntervalWeek {
+		t.Errorf("unexpected revenue query: %+v %q", fake.filter, fake.arg)
+	}
+	var points []domain.RevenuePoint
+	if err := json.NewDecoder(rec.Body).Decode(&points); err != nil || len(points) != 1 {
+		t.Errorf("unexpected revenue body: %v %v", points, err)
+	}
+
+	// Без from — последние 30 дней до to
+	if rec := get("/analytics/top-items?to=2025-04-01&by=nm_id&sort=revenue&limit=5"); rec.Code != http.StatusOK {
+		t.Fatalf("top items: got %d", rec.Code)
+	}
+	if fake.arg != "nm_id:revenue" || fake.limit != 5 || fake.filter.To.Sub(fake.filter.From) != defaultAnalyticsRange {
+		t.Errorf("unexpected top items query: %+v %q %d", fake.filter, fake.arg, fake.limit)
+	}
+
+	for _, target := range []string{
+		"/analytics/revenue?interval=year",
+		"/analytics/breakdown?by=customer_id",
+		"/analytics/top-items?limit=1000",
+		"/analytics/summary?from=2025-04-02&to=2025-04-01",
+	} {
+		if rec := get(target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/gorilla/mux"
+)
+
+const (
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+)
+
+// Вызывающий при выключенной аутентификации (только для локальной разработки)
+var anonymousAdmin = &auth.Principal{Subject: "anonymous", Role: auth.RoleAdmin}
+
+// Middleware, определяющий вызывающего по API ключу или JWT.
+// Запрос без учетных данных проходит дальше анонимным, права проверяет requireRole.
+// a == nil означает выключенную аутентификацию: все запросы выполняются с ролью admin.
+// Неудачные попытки ограничиваются по IP лимитом класса auth
+func authMiddleware(a *auth.Authenticator, rl *RateLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := anonymousAdmin
+			if a != nil {
+				var err error
+				p, err = a.Authenticate(r)
+				noCredentials := errors.Is(err, auth.ErrNoCredentials)
+				if !noCredentials && rl.authThrottled(w, r) {
+					return
+				}
+				if err != nil && !noCredentials {
+					rl.authFailed(r)
+					slog.InfoContext(r.Context(), "authentication failed", logger.KeyError, err)
+					writeUnauthorized(w, r)
+					return
+				}
+			}
+
+			ctx := r.Context()
+			if p != nil {
+				ctx = auth.WithPrincipal(ctx, p)
+				ctx = logger.With(ctx, logger.KeySubject, p.Subject)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Пропускает запрос только вызывающим с ролью не ниже role
+func requireRole(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := auth.FromContext(r.Context())
+		if p == nil {
+			writeUnauthorized(w, r)
+			return
+		}
+		if !p.Role.Allows(role) {
+			slog.InfoContext(r.Context(), "access denied", "role", p.Role.String(), "required_role", role.String())
+			writeProblem(w, r, http.StatusForbidden, codeForbidden, role.String())
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+	writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized)
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+)
+
+// Маршруты проверяют роль вызывающего
+func TestRouter_RoleAccess(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: "r:reader:rk,s:support:sk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(RouterDeps{Auth: a})
+
+	cases := []struct {
+		method, path, key string
+		want              int
+	}{
+		{http.MethodGet, "/webhooks", "", http.StatusUnauthorized},
+		{http.MethodGet, "/webhooks", "bad", http.StatusUnauthorized},
+		{http.MethodGet, "/webhooks", "rk", http.StatusForbidden},
+		{http.MethodGet, "/webhooks", "sk", http.StatusForbidden},
+		{http.MethodPost, "/orders", "rk", http.StatusForbidden},
+		{http.MethodGet, "/healthz", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.key != "" {
+			req.Header.Set(auth.APIKeyHeader, c.key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s %s with key %q: got %d, want %d", c.method, c.path, c.key, rec.Code, c.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+)
+
+// Максимальное число order_uid в одном запросе batchGet
+const maxBatchGetSize = 1000
+
+// Тело запроса POST /orders:batchGet
+type batchGetRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+// Ответ batchGet: найденные заказы в порядке запроса и отсутствующие id
+type batchGetResponse struct {
+	Orders   []json.RawMessage `json:"orders"`
+	NotFound []string          `json:"not_found"`
+}
+
+// Пакетная выдача заказов по списку order_uid
+func (h *Handler) BatchGetOrders(w http.ResponseWriter, r *http.Request) {
+	var req batchGetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBodySize)).Decode(&req); err != nil {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+	if len(req.OrderUIDs) == 0 || len(req.OrderUIDs) > maxBatchGetSize {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidBatch, maxBatchGetSize)
+		return
+	}
+
+	proj, err := parseProjection(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	orders, missing, err := h.orderService.GetOrders(r.Context(), req.OrderUIDs, proj.parts())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	l := localizer(r)
+	setContentLanguage(w, l.Lang())
+	role := auth.RoleFrom(r.Context())
+	resp := batchGetResponse{
+		Orders:   make([]json.RawMessage, 0, len(orders)),
+		NotFound: missing,
+	}
+	for _, order := range orders {
+		data, err := encodeOrder(orderFormats[0], proj, newOrderResponse(h.redaction.Order(order, role), l))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp.Orders = append(resp.Orders, data)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
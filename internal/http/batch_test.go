@@ -0,0 +1,21 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Маршрут с двоеточием не перехватывается /orders/{id}; пустой список отклоняется
+func TestBatchGetOrders_InvalidBatch(t *testing.T) {
+	router := NewRouter(RouterDeps{})
+	for _, body := range []string{`{"order_uids":[]}`, `{"order_uids":"o1"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/orders:batchGet", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", body, rec.Code)
+		}
+	}
+}
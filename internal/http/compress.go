@@ -0,0 +1,228 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Минимальный размер тела, начиная с которого ответ сжимается
+const minCompressSize = 1024
+
+// Кодировщик сжатия, переиспользуемый через пул
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Поддерживаемые кодировки в порядке предпочтения сервера
+var compressionCodings = []string{"br", "zstd", "gzip"}
+
+var compressorPools = map[string]*sync.Pool{
+	"br": {New: func() any { return brotli.NewWriterLevel(nil, 5) }},
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	"gzip": {New: func() any { return gzip.NewWriter(nil) }},
+}
+
+// Выбирает кодировку по Accept-Encoding с учетом q; при равном q — по
+// предпочтению сервера. Пустая строка — ответ без сжатия
+func negotiateCoding(acceptEncoding string) string {
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+		} else if name != "" {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range compressionCodings {
+		q, ok := weights[coding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// Middleware, сжимающий ответы br, zstd или gzip по Accept-Encoding.
+// Не сжимаются WebSocket, потоки SSE, ответы без тела и тела меньше minCompressSize
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		coding := negotiateCoding(r.Header.Get("Accept-Encoding"))
+		if coding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// ETag сжатого представления отличается суффиксом; для сравнения он снимается
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			r.Header.Set("If-None-Match", stripCodingSuffixes(inm))
+		}
+
+		cw := &compressWriter{ResponseWriter: w, coding: coding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Убирает суффикс кодировки из ETag в списке If-None-Match
+func stripCodingSuffixes(header string) string {
+	for _, coding := range compressionCodings {
+		header = strings.ReplaceAll(header, "-"+coding+`"`, `"`)
+	}
+	return header
+}
+
+// ResponseWriter, решающий о сжатии после первых minCompressSize байт тела
+type compressWriter struct {
+	http.ResponseWriter
+	coding  string
+	status  int
+	buf     []byte
+	decided bool
+	enc     compressor
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = code
+	// Ответы без тела уходят сразу
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) >= minCompressSize {
+			if err := cw.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Отправляет заголовки и накопленное тело; compress — тело достаточно велико для сжатия
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	h := cw.Header()
+	if compress && compressible(h) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.coding)
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+cw.coding+`"`)
+		}
+		cw.enc = compressorPools[cw.coding].Get().(compressor)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+// Сжимать можно, если обработчик не сжал ответ сам и это не поток событий
+func compressible(h http.Header) bool {
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType != "text/event-stream"
+}
+
+// Flush до накопления minCompressSize отправляет ответ без сжатия (потоковые ответы)
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Завершает ответ: дописывает короткое тело без сжатия или закрывает кодировщик
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// Обработчик ничего не записал (например, после Hijack)
+			return nil
+		}
+		return cw.start(false)
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	cw.enc.Reset(nil)
+	compressorPools[cw.coding].Put(cw.enc)
+	cw.enc = nil
+	return err
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	cw.decided = true
+	return h.Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
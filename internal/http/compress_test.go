@@ -0,0 +1,87 @@
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateCoding(t *testing.T) {
+	cases := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br":       "br",
+		"gzip;q=1, br;q=0.5":      "gzip",
+		"zstd, gzip":              "zstd",
+		"*":                       "br",
+		"*;q=0.1, gzip;q=0.5":     "gzip",
+		"br;q=0, gzip;q=0, *;q=0": "",
+	}
+	for header, want := range cases {
+		if got := negotiateCoding(header); got != want {
+			t.Errorf("%q: got %q, want %q", header, got, want)
+		}
+	}
+}
+
+// Крупные ответы сжимаются выбранной кодировкой, мелкие и SSE — нет
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"order_uid":"o1"}`, 200)
+	handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, large)
+		case "/small":
+			io.WriteString(w, "{}")
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, strings.Repeat("data: x\n\n", 200))
+		}
+	}))
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	for coding, decode := range decoders {
+		req := httptest.NewRequest(http.MethodGet, "/large", nil)
+		req.Header.Set("Accept-Encoding", coding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Encoding") != coding {
+			t.Fatalf("%s: unexpected Content-Encoding %q", coding, rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Header().Get("ETag") != `"abc-`+coding+`"` {
+			t.Errorf("%s: unexpected ETag %q", coding, rec.Header().Get("ETag"))
+		}
+		r, err := decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil || string(body) != large {
+			t.Errorf("%s: body mismatch (err %v, %d bytes)", coding, err, len(body))
+		}
+	}
+
+	for _, path := range []string{"/small", "/stream"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s must not be compressed", path)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cache-Control ответа с заказом по умолчанию: ответ зависит от роли вызывающего,
+// поэтому кэшируется только клиентом и перепроверяется по ETag
+const DefaultOrderCacheControl = "private, no-cache"
+
+// Заголовки запроса, от которых зависит тело ответа с заказом
+const orderVary = "Accept, Accept-Language, Authorization, X-API-Key"
+
+// Пишет тело ответа с сильным ETag по содержимому и Last-Modified. Если условный
+// запрос совпадает с текущим представлением, отвечает 304 без тела
+func writeCacheable(w http.ResponseWriter, r *http.Request, contentType string, data []byte, lastModified time.Time, cacheControl string) {
+	sum := sha256.Sum256(data)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Add("Vary", orderVary)
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Проверка условий запроса по RFC 9110: If-Modified-Since учитывается,
+// только если нет If-None-Match
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// Last-Modified передается с точностью до секунды
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// Слабое сравнение ETag из списка If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
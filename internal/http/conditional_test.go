@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Повторный запрос с ETag или датой изменения получает 304 без тела
+func TestWriteCacheableJSON_Conditional(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 10, 0, 0, 500, time.UTC)
+	body := []byte(`{"order_uid":"o1"}`)
+
+	rec := httptest.NewRecorder()
+	writeCacheable(rec, httptest.NewRequest(http.MethodGet, "/orders/o1", nil), "application/json", body, modified, DefaultOrderCacheControl)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("Last-Modified") != "Sat, 01 Mar 2025 10:00:00 GMT" {
+		t.Fatalf("unexpected response: %d %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Cache-Control") != DefaultOrderCacheControl {
+		t.Errorf("unexpected Cache-Control: %q", rec.Header().Get("Cache-Control"))
+	}
+
+	cases := []struct {
+		name, header, value string
+		want                int
+	}{
+		{"etag match", "If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"weak etag match", "If-None-Match", "W/" + etag, http.StatusNotModified},
+		{"etag mismatch", "If-None-Match", `"other"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Sat, 01 Mar 2025 10:00:00 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Sat, 01 Mar 2025 09:59:59 GMT", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/orders/o1", nil)
+		req.Header.Set(c.header, c.value)
+		rec := httptest.NewRecorder()
+		writeCacheable(rec, req, "application/json", body, modified, DefaultOrderCacheControl)
+		if rec.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, rec.Code, c.want)
+		}
+		if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 must not have a body", c.name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Тело запроса на удаление данных клиента
+type erasureRequest struct {
+	// Основание: номер обращения или ссылка на запрос субъекта данных
+	Reason string `json:"reason"`
+}
+
+// Выгрузка всех заказов клиента JSON архивом
+func (h *Handler) ExportCustomer(w http.ResponseWriter, r *http.Request) {
+	export, err := h.orderService.ExportCustomer(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="customer-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, export)
+}
+
+// Обезличивание заказов клиента; возвращает запись аудита
+func (h *Handler) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	// Тело необязательно
+	var req erasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+
+	record, err := h.orderService.EraseCustomer(r.Context(), mux.Vars(r)["id"], requestSubject(r), req.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
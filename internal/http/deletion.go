@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/gorilla/mux"
+)
+
+// Тело запроса на удаление заказа
+type deleteOrderRequest struct {
+	// Причина удаления, например отмена клиентом или дубликат
+	Reason string `json:"reason"`
+}
+
+// Мягкое удаление заказа; возвращает пометку удаления
+func (h *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	var req deleteOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "reason")
+		return
+	}
+
+	deleted, err := h.orderService.DeleteOrder(r.Context(), mux.Vars(r)["id"], requestSubject(r), req.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// Список удаленных заказов, последние удаленные первыми
+func (h *Handler) ListDeletedOrders(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "limit")
+			return
+		}
+		limit = n
+	}
+
+	deleted, err := h.orderService.ListDeletedOrders(r.Context(), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// Восстановление удаленного заказа; возвращает заказ
+func (h *Handler) RestoreOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.orderService.RestoreOrder(r.Context(), mux.Vars(r)["id"], requestSubject(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.redaction.Order(order, auth.RoleFrom(r.Context())))
+}
+
+// Субъект аутентификации для аудита; без аутентификации — "anonymous"
+func requestSubject(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.Subject
+	}
+	return "anonymous"
+}
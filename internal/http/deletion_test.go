@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeleteOrder_ReasonRequired(t *testing.T) {
+	router := NewRouter(RouterDeps{})
+	for _, body := range []string{"", `{"reason": "  "}`} {
+		req := httptest.NewRequest(http.MethodDelete, "/orders/o1", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: got %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestListDeletedOrders_InvalidLimit(t *testing.T) {
+	router := NewRouter(RouterDeps{})
+	req := httptest.NewRequest(http.MethodGet, "/orders/deleted?limit=-1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/i18n"
+)
+
+// Заказ в ответе API вместе с отформатированными для языка клиента значениями
+type orderResponse struct {
+	XMLName xml.Name `json:"-" xml:"order"`
+	*domain.Order
+	Display orderDisplay `json:"display" xml:"display"`
+}
+
+// Суммы и даты заказа, отформатированные по правилам языка
+type orderDisplay struct {
+	Lang         string        `json:"lang" xml:"lang"`
+	DateCreated  string        `json:"date_created" xml:"date_created"`
+	PaymentDt    string        `json:"payment_dt" xml:"payment_dt"`
+	Amount       string        `json:"amount" xml:"amount"`
+	DeliveryCost string        `json:"delivery_cost" xml:"delivery_cost"`
+	GoodsTotal   string        `json:"goods_total" xml:"goods_total"`
+	CustomFee    string        `json:"custom_fee" xml:"custom_fee"`
+	Items        []itemDisplay `json:"items" xml:"items>item"`
+}
+
+// Цены позиции, отформатированные по правилам языка
+type itemDisplay struct {
+	RID        string `json:"rid" xml:"rid"`
+	Price      string `json:"price" xml:"price"`
+	TotalPrice string `json:"total_price" xml:"total_price"`
+}
+
+// Создание ответа с заказом на языке l
+func newOrderResponse(order *domain.Order, l *i18n.Localizer) orderResponse {
+	p := order.Payment
+	display := orderDisplay{
+		Lang:         l.Lang(),
+		DateCreated:  l.DateTime(order.DateCreated),
+		PaymentDt:    l.DateTime(time.Unix(p.PaymentDt, 0)),
+		Amount:       l.Money(p.Amount, p.Currency),
+		DeliveryCost: l.Money(p.DeliveryCost, p.Currency),
+		GoodsTotal:   l.Money(p.GoodsTotal, p.Currency),
+		CustomFee:    l.Money(p.CustomFee, p.Currency),
+		Items:        make([]itemDisplay, 0, len(order.Items)),
+	}
+	for _, item := range order.Items {
+		display.Items = append(display.Items, itemDisplay{
+			RID:        item.RID,
+			Price:      l.Money(item.Price, p.Currency),
+			TotalPrice: l.Money(item.TotalPrice, p.Currency),
+		})
+	}
+	return orderResponse{Order: order, Display: display}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/Tommych123/L0-WB/internal/orderpb"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const codeNotAcceptable = "not_acceptable"
+
+// Представление заказа в одном из поддерживаемых форматов
+type orderFormat struct {
+	contentType string
+	// Дополнительные названия типа в Accept
+	aliases []string
+	encode  func(resp orderResponse) ([]byte, error)
+	// Кодирование ответа после ?fields= и ?include=; nil — формат выборку полей не поддерживает
+	encodeDoc func(doc map[string]any) ([]byte, error)
+}
+
+// Форматы ответа с заказом; первый используется по умолчанию
+var orderFormats = []orderFormat{
+	{contentType: "application/json", encode: encodeJSON, encodeDoc: encodeJSONDoc},
+	{contentType: "application/x-msgpack", aliases: []string{"application/msgpack", "application/vnd.msgpack"}, encode: encodeMsgpack, encodeDoc: encodeMsgpackDoc},
+	{contentType: "application/protobuf", aliases: []string{"application/x-protobuf", "application/vnd.google.protobuf"}, encode: encodeProtobuf},
+	{contentType: "application/xml", aliases: []string{"text/xml"}, encode: encodeXML},
+}
+
+// Выбирает формат по заголовку Accept с учетом q. Пустой Accept и */* — JSON.
+// Другой формат выбирается, только если клиент предпочитает его всем остальным типам
+// и JSON, указанному явно: браузер с "text/html,application/xml;q=0.9,*/*;q=0.8"
+// получает JSON. false, если ни один из поддерживаемых форматов клиенту не подходит
+func negotiateOrderFormat(accept string) (orderFormat, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return orderFormats[0], true
+	}
+	// q самого предпочтительного типа, включая неподдерживаемые
+	var topQ float64
+	// q JSON: явного application/json или шаблонов */* и application/*
+	var jsonQ, wildcardQ float64
+	jsonExplicit := false
+	// Лучший формат, отличный от JSON
+	other, otherQ := -1, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		topQ = math.Max(topQ, q)
+		switch idx := matchOrderFormat(mediaType); {
+		case idx < 0:
+		case mediaType == "*/*" || mediaType == "application/*":
+			wildcardQ = math.Max(wildcardQ, q)
+		case idx == 0:
+			jsonExplicit = true
+			jsonQ = math.Max(jsonQ, q)
+		case q > otherQ:
+			other, otherQ = idx, q
+		}
+	}
+	if !jsonExplicit {
+		jsonQ = wildcardQ
+	}
+
+	switch {
+	case other >= 0 && otherQ == topQ && (!jsonExplicit || otherQ > jsonQ):
+		return orderFormats[other], true
+	case jsonQ > 0:
+		return orderFormats[0], true
+	case other >= 0:
+		return orderFormats[other], true
+	}
+	return orderFormat{}, false
+}
+
+// Индекс формата для типа из Accept; -1, если тип не поддерживается
+func matchOrderFormat(mediaType string) int {
+	if mediaType == "*/*" || mediaType == "application/*" {
+		return 0
+	}
+	for i, f := range orderFormats {
+		if mediaType == f.contentType {
+			return i
+		}
+		for _, alias := range f.aliases {
+			if mediaType == alias {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func encodeJSON(resp orderResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(resp)
+	return buf.Bytes(), err
+}
+
+func encodeJSONDoc(doc map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(doc)
+	return buf.Bytes(), err
+}
+
+func encodeMsgpackDoc(doc map[string]any) ([]byte, error) {
+	return msgpack.Marshal(doc)
+}
+
+// MessagePack с теми же именами полей, что и в JSON
+func encodeMsgpack(resp orderResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(resp)
+	return buf.Bytes(), err
+}
+
+// Сообщение orders.v1.OrderResponse из proto/order.proto
+func encodeProtobuf(resp orderResponse) ([]byte, error) {
+	d := resp.Display
+	display := &orderpb.OrderDisplay{
+		Lang:         d.Lang,
+		DateCreated:  d.DateCreated,
+		PaymentDt:    d.PaymentDt,
+		Amount:       d.Amount,
+		DeliveryCost: d.DeliveryCost,
+		GoodsTotal:   d.GoodsTotal,
+		CustomFee:    d.CustomFee,
+		Items:        make([]*orderpb.ItemDisplay, 0, len(d.Items)),
+	}
+	for _, item := range d.Items {
+		display.Items = append(display.Items, &orderpb.ItemDisplay{
+			Rid:        item.RID,
+			Price:      item.Price,
+			TotalPrice: item.TotalPrice,
+		})
+	}
+	return proto.Marshal(&orderpb.OrderResponse{
+		Order:   orderpb.FromDomain(resp.Order),
+		Display: display,
+	})
+}
+
+func encodeXML(resp orderResponse) ([]byte, error) {
+	data, err := xml.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
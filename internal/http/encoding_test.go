@@ -0,0 +1,85 @@
+package http
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/i18n"
+	"github.com/Tommych123/L0-WB/internal/orderpb"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNegotiateOrderFormat(t *testing.T) {
+	cases := map[string]string{
+		"":                                 "application/json",
+		"*/*":                              "application/json",
+		"application/x-msgpack":            "application/x-msgpack",
+		"application/x-protobuf":           "application/protobuf",
+		"text/html, application/xml;q=0.9": "application/xml",
+		"application/json;q=0.5, application/msgpack":                     "application/x-msgpack",
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": "application/json",
+		"application/xml, */*":                                            "application/xml",
+		"application/xml, application/json":                               "application/json",
+		"application/json;q=0.9, text/xml":                                "application/xml",
+		"text/html, application/json;q=0.5, text/xml;q=0.9":               "application/json",
+		"application/json;q=0, application/x-protobuf;q=0.1":              "application/protobuf",
+	}
+	for accept, want := range cases {
+		f, ok := negotiateOrderFormat(accept)
+		if !ok || f.contentType != want {
+			t.Errorf("%q: got %q (%v), want %q", accept, f.contentType, ok, want)
+		}
+	}
+	if _, ok := negotiateOrderFormat("text/html"); ok {
+		t.Error("text/html must not be acceptable")
+	}
+}
+
+// Все форматы содержат поля заказа
+func TestOrderFormats_Encode(t *testing.T) {
+	order := &domain.Order{
+		OrderUID:    "o1",
+		DateCreated: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Payment:     domain.Payment{Currency: "USD", Amount: 1817},
+		Items:       []domain.Item{{RID: "r1", Price: 453}},
+	}
+	resp := newOrderResponse(order, i18n.For("en"))
+
+	data, err := encodeMsgpack(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := msgpack.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["order_uid"] != "o1" || decoded["display"] == nil {
+		t.Errorf("unexpected msgpack payload: %v", decoded)
+	}
+
+	data, err = encodeProtobuf(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg orderpb.OrderResponse
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetOrder().GetOrderUid() != "o1" || msg.GetOrder().GetPayment().GetAmount() != 1817 ||
+		msg.GetOrder().GetDateCreated().AsTime() != order.DateCreated || msg.GetDisplay().GetLang() != "en" {
+		t.Errorf("unexpected protobuf payload: %v", &msg)
+	}
+
+	data, err = encodeXML(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<order><order_uid>o1</order_uid>", "<items><item><chrt_id>", "<display><lang>en</lang>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("xml payload must contain %q: %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+)
+
+// Через сколько заказов выгрузка сбрасывает накопленное клиенту
+const exportFlushEvery = 500
+
+// Формат выгрузки заказов
+type orderExporter interface {
+	contentType() string
+	extension() string
+	// Начало выгрузки (например, строка заголовков CSV)
+	begin() error
+	write(order *domain.Order) error
+	flush() error
+}
+
+// Выгрузка заказов за период в NDJSON или CSV. Заказы читаются из БД курсором
+// и пишутся клиенту по мере чтения
+func (h *Handler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, err := parseTimeParam(q.Get("from"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "from")
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"))
+	if err != nil || !to.After(from) {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "to")
+		return
+	}
+
+	var exp orderExporter
+	switch q.Get("format") {
+	case "", "ndjson":
+		exp = newNDJSONExporter(w)
+	case "csv":
+		exp = newCSVExporter(w)
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "format")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	role := auth.RoleFrom(r.Context())
+	written := 0
+	start := func() error {
+		name := "orders-" + from.UTC().Format("20060102T150405") + "-" + to.UTC().Format("20060102T150405") + "." + exp.extension()
+		w.Header().Set("Content-Type", exp.contentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.WriteHeader(http.StatusOK)
+		return exp.begin()
+	}
+
+	err = h.orderService.ExportOrders(r.Context(), from, to, func(order *domain.Order) error {
+		if written == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := exp.write(h.redaction.Order(order, role)); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := exp.flush(); err != nil {
+				return err
+			}
+			rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if written == 0 {
+			writeError(w, r, err)
+			return
+		}
+		// Заголовки уже отправлены: обрываем ответ, чтобы клиент не принял неполную выгрузку за целую
+		slog.ErrorContext(r.Context(), "order export aborted", "orders_written", written, logger.KeyError, err)
+		panic(http.ErrAbortHandler)
+	}
+	if written == 0 {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	exp.flush()
+}
+
+// Граница периода в параметре запроса: дата (2006-01-02, начало дня UTC) или RFC 3339
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// Один заказ в JSON на строку
+type ndjsonExporter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONExporter(w io.Writer) *ndjsonExporter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonExporter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (e *ndjsonExporter) contentType() string { return "application/x-ndjson" }
+func (e *ndjsonExporter) extension() string   { return "ndjson" }
+func (e *ndjsonExporter) begin() error        { return nil }
+func (e *ndjsonExporter) flush() error        { return e.buf.Flush() }
+
+func (e *ndjsonExporter) write(order *domain.Order) error {
+	return e.enc.Encode(order)
+}
+
+// Колонки CSV: поля заказа и оплаты повторяются в каждой строке позиции
+var csvExportHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "customer_id", "delivery_service",
+	"shardkey", "sm_id", "date_created", "oof_shard",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+	"item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// Строка на каждую позицию заказа; заказ без позиций — одна строка с пустыми колонками позиции
+type csvExporter struct {
+	w   *csv.Writer
+	row []string
+}
+
+func newCSVExporter(w io.Writer) *csvExporter {
+	return &csvExporter{w: csv.NewWriter(w), row: make([]string, 0, len(csvExportHeader))}
+}
+
+func (e *csvExporter) contentType() string { return "text/csv; charset=utf-8" }
+func (e *csvExporter) extension() string   { return "csv" }
+func (e *csvExporter) begin() error        { return e.w.Write(csvExportHeader) }
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) write(o *domain.Order) error {
+	p := o.Payment
+	e.row = append(e.row[:0],
+		csvText(o.OrderUID), csvText(o.TrackNumber), csvText(o.Entry), csvText(o.Locale),
+		csvText(o.CustomerID), csvText(o.DeliveryService), csvText(o.ShardKey),
+		strconv.Itoa(o.SmID), o.DateCreated.UTC().Format(time.RFC3339), csvText(o.OofShard),
+		csvText(p.Transaction), csvText(p.RequestID), csvText(p.Currency), csvText(p.Provider),
+		strconv.Itoa(p.Amount), strconv.FormatInt(p.PaymentDt, 10), csvText(p.Bank),
+		strconv.Itoa(p.DeliveryCost), strconv.Itoa(p.GoodsTotal), strconv.Itoa(p.CustomFee),
+	)
+	orderCols := len(e.row)
+
+	if len(o.Items) == 0 {
+		for len(e.row) < len(csvExportHeader) {
+			e.row = append(e.row, "")
+		}
+		return e.w.Write(e.row)
+	}
+	for _, item := range o.Items {
+		e.row = append(e.row[:orderCols],
+			strconv.Itoa(item.ChrtID), csvText(item.TrackNumber), strconv.Itoa(item.Price),
+			csvText(item.RID), csvText(item.Name), strconv.Itoa(item.Sale), csvText(item.Size),
+			strconv.Itoa(item.TotalPrice), strconv.Itoa(item.NmID), csvText(item.Brand),
+			strconv.Itoa(item.Status),
+		)
+		if err := e.w.Write(e.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Текстовая ячейка; значения, которые табличный редактор принял бы за формулу, экранируются апострофом
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
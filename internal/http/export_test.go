@@ -0,0 +1,66 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+func TestExportOrders_InvalidParameters(t *testing.T) {
+	router := NewRouter(RouterDeps{})
+	for _, query := range []string{
+		"from=yesterday&to=2025-03-02",
+		"from=2025-03-02&to=2025-03-01",
+		"from=2025-03-01&to=2025-03-02&format=xlsx",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/orders/export?"+query, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+// Строка на каждую позицию с повтором полей заказа; заказ без позиций — одна строка
+func TestCSVExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exp := newCSVExporter(&buf)
+	exp.begin()
+	exp.write(&domain.Order{
+		OrderUID:    "o1",
+		DateCreated: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Payment:     domain.Payment{Currency: "USD", Amount: 1817},
+		Items:       []domain.Item{{RID: "r1", Name: "=HYPERLINK()"}, {RID: "r2"}},
+	})
+	exp.write(&domain.Order{OrderUID: "o2"})
+	if err := exp.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d", len(rows))
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[name] = i
+	}
+	if rows[1][col["order_uid"]] != "o1" || rows[2][col["payment_amount"]] != "1817" || rows[2][col["item_rid"]] != "r2" {
+		t.Errorf("unexpected rows: %v", rows[1:3])
+	}
+	if got := rows[1][col["item_name"]]; got != "'=HYPERLINK()" {
+		t.Errorf("formula not escaped: %q", got)
+	}
+	if rows[3][col["order_uid"]] != "o2" || rows[3][col["item_rid"]] != "" {
+		t.Errorf("unexpected row for order without items: %v", rows[3])
+	}
+}
@@ -2,20 +2,64 @@ package http
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
 
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/health"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/redact"
+	"github.com/Tommych123/L0-WB/internal/repository"
 	"github.com/Tommych123/L0-WB/internal/service"
+	"github.com/Tommych123/L0-WB/internal/stream"
+	"github.com/Tommych123/L0-WB/web"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 )
 
 // Структура handler
 type Handler struct {
 	orderService *service.OrderService
+	ingester     OrderIngester
+	idempotency  repository.IdempotencyRepository
+	ledger       repository.LedgerRepository
+	lookup       repository.LookupRepository
+	analytics    repository.AnalyticsRepository
+	webhooks     repository.WebhookRepository
+	stream       *stream.Broker
+	health       *health.Checker
+	redaction    *redact.Policy
+	rateLimiter  *RateLimiter
+	// Cache-Control ответа GET /orders/{id}
+	orderCacheControl string
+	upgrader          websocket.Upgrader
 }
 
 // Создание нового handler
-func NewHandler(orderService *service.OrderService) *Handler {
-	return &Handler{orderService: orderService}
+func NewHandler(deps RouterDeps) *Handler {
+	h := &Handler{
+		orderService: deps.OrderService,
+		ingester:     deps.Ingester,
+		idempotency:  deps.Idempotency,
+		ledger:       deps.Ledger,
+		lookup:       deps.Lookup,
+		analytics:    deps.Analytics,
+		webhooks:     deps.Webhooks,
+		stream:       deps.Stream,
+		health:       deps.Health,
+		redaction:    deps.Redaction,
+		rateLimiter:  deps.RateLimiter,
+
+		orderCacheControl: deps.OrderCacheControl,
+		upgrader:          websocket.Upgrader{CheckOrigin: checkStreamOrigin(deps.StreamOrigins)},
+	}
+	if h.redaction == nil {
+		h.redaction = redact.DefaultPolicy()
+	}
+	if h.orderCacheControl == "" {
+		h.orderCacheControl = DefaultOrderCacheControl
+	}
+	return h
 }
 
 // Получение заказа по ID
@@ -23,21 +67,58 @@ func (h *Handler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	order, err := h.orderService.GetOrder(id)
+	// JSON, MessagePack, protobuf или XML по заголовку Accept
+	format, ok := negotiateOrderFormat(r.Header.Get("Accept"))
+	if !ok {
+		writeProblem(w, r, http.StatusNotAcceptable, codeNotAcceptable)
+		return
+	}
+
+	// Выборка полей и встраиваемых сущностей; ненужные таблицы не читаются
+	proj, err := parseProjection(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Ошибка сервера: "+err.Error(), http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
 		return
 	}
-	if order == nil {
-		http.Error(w, "Заказ не найден", http.StatusNotFound)
+	if proj != nil && format.encodeDoc == nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "fields")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(order)
+	order, err := h.orderService.GetOrderParts(r.Context(), id, proj.parts())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Поля, недоступные роли вызывающего, скрываются до кодирования ответа
+	order = h.redaction.Order(order, auth.RoleFrom(r.Context()))
+
+	// Без явного выбора языка используется locale заказа
+	l := localizer(r, order.Locale)
+	setContentLanguage(w, l.Lang())
+
+	data, err := encodeOrder(format, proj, newOrderResponse(order, l))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeCacheable(w, r, format.contentType, data, order.UpdatedAt, h.orderCacheControl)
 }
 
-// Отдает статическую HTML-страницу
+// Отдает HTML-страницу поиска заказа на языке клиента
 func (h *Handler) ServeWebUI(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "./web/index.html")
+	l := localizer(r)
+	setContentLanguage(w, l.Lang())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := web.Index.Execute(w, l); err != nil {
+		slog.ErrorContext(r.Context(), "error rendering web UI", logger.KeyError, err)
+	}
+}
+
+// Кодирует значение в JSON с указанным кодом ответа
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
 }
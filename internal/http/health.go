@@ -0,0 +1,23 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/health"
+)
+
+// Liveness: процесс жив и обслуживает HTTP
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, health.Report{Status: health.StatusUp, Checks: map[string]health.Result{}})
+}
+
+// Readiness: все зависимости доступны и кэш прогрет
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.health.Run(r.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,22 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/i18n"
+)
+
+// Параметр запроса, явно задающий язык ответа
+const langQueryParam = "lang"
+
+// Язык ответа: параметр ?lang=, затем Accept-Language, затем fallback
+// (например, locale заказа), иначе язык по умолчанию
+func localizer(r *http.Request, fallback ...string) *i18n.Localizer {
+	return i18n.Negotiate(r.URL.Query().Get(langQueryParam), r.Header.Get("Accept-Language"), fallback...)
+}
+
+// Помечает ответ языком; ответ зависит от Accept-Language, что важно для кэшей
+func setContentLanguage(w http.ResponseWriter, lang string) {
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+}
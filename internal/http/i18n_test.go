@@ -0,0 +1,47 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Текст ошибки переводится по Accept-Language, ?lang= имеет приоритет
+func TestProblem_Localized(t *testing.T) {
+	h := newIngestHandler(&mockIngester{})
+	cases := []struct {
+		target, accept, lang, detail string
+	}{
+		{"/orders", "en-US,en;q=0.9", "en", "order_uid is required."},
+		{"/orders", "ru-RU", "ru", "Не указан order_uid."},
+		{"/orders?lang=en", "ru-RU", "en", "order_uid is required."},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, c.target, strings.NewReader(`{"order_uid":""}`))
+		req.Header.Set("Accept-Language", c.accept)
+		rec := httptest.NewRecorder()
+		h.CreateOrders(rec, req)
+
+		var p problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("invalid problem JSON: %v", err)
+		}
+		if p.Detail != c.detail || rec.Header().Get("Content-Language") != c.lang {
+			t.Errorf("%s %s: got %q (%s)", c.target, c.accept, p.Detail, rec.Header().Get("Content-Language"))
+		}
+	}
+}
+
+// Страница поиска отрисовывается из каталога
+func TestServeWebUI_Localized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?lang=en", nil)
+	rec := httptest.NewRecorder()
+	(&Handler{}).ServeWebUI(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "<title>Order lookup</title>") || !strings.Contains(body, `lang="en"`) {
+		t.Errorf("unexpected page: %s", body)
+	}
+}
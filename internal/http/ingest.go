@@ -0,0 +1,245 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/i18n"
+	"github.com/Tommych123/L0-WB/internal/kafka"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/service"
+)
+
+const (
+	// Максимальный размер тела запроса на прием заказов
+	maxIngestBodySize = 10 << 20
+	// Максимальное количество заказов в одном пакете
+	maxIngestBatchSize = 1000
+)
+
+// Приемник заказов, поступивших через HTTP
+type OrderIngester interface {
+	IngestOrder(ctx context.Context, order *domain.Order) error
+	// Код ответа при успешном приеме заказа
+	SuccessStatus() int
+}
+
+// Сохраняет заказы напрямую через сервис (БД + кэш)
+type ServiceIngester struct {
+	orderService *service.OrderService
+}
+
+// Создание приемника, сохраняющего заказы в БД
+func NewServiceIngester(orderService *service.OrderService) *ServiceIngester {
+	return &ServiceIngester{orderService: orderService}
+}
+
+func (i *ServiceIngester) IngestOrder(ctx context.Context, order *domain.Order) error {
+	return i.orderService.SaveOrder(ctx, order)
+}
+
+func (i *ServiceIngester) SuccessStatus() int {
+	return http.StatusCreated
+}
+
+// Публикует заказы в Kafka, откуда их заберет consumer
+type KafkaIngester struct {
+	producer *kafka.Producer
+}
+
+// Создание приемника, публикующего заказы в Kafka
+func NewKafkaIngester(producer *kafka.Producer) *KafkaIngester {
+	return &KafkaIngester{producer: producer}
+}
+
+func (i *KafkaIngester) IngestOrder(ctx context.Context, order *domain.Order) error {
+	if err := i.producer.SendOrder(ctx, *order); err != nil {
+		return service.Unavailable(codeBrokerUnavailable, err)
+	}
+	return nil
+}
+
+func (i *KafkaIngester) SuccessStatus() int {
+	return http.StatusAccepted
+}
+
+// Результат приема одного заказа в пакете
+type ingestResult struct {
+	OrderUID string `json:"order_uid"`
+	Status   string `json:"status"`
+	Code     string `json:"code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Ответ на пакетный прием заказов
+type ingestBatchResponse struct {
+	Results []ingestResult `json:"results"`
+}
+
+// Прием одного заказа или пакета заказов
+func (h *Handler) CreateOrders(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge)
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidBody)
+		return
+	}
+
+	key := r.Header.Get("Idempotency-Key")
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+	// Ключи разных вызывающих не пересекаются
+	owner := requestSubject(r)
+
+	// Ключ резервируется до обработки: параллельный повтор получает 409, а не второй прием заказа.
+	// Повторный запрос после завершения получает сохраненный ответ
+	if key != "" {
+		rec, err := h.idempotency.ReserveIdempotencyKey(r.Context(), owner, key, requestHash)
+		if err != nil {
+			writeError(w, r, service.StorageError(err))
+			return
+		}
+		if rec != nil {
+			switch {
+			case rec.RequestHash != requestHash:
+				writeProblem(w, r, http.StatusUnprocessableEntity, codeIdempotencyKeyReused)
+			case rec.StatusCode == 0:
+				w.Header().Set("Retry-After", "1")
+				writeProblem(w, r, http.StatusConflict, codeIdempotencyKeyInProgress)
+			default:
+				w.Header().Set("Idempotent-Replayed", "true")
+				writeJSONBytes(w, rec.StatusCode, rec.Response)
+			}
+			return
+		}
+	}
+
+	status, resp := h.ingest(r, body)
+	data, err := json.Marshal(resp)
+	if err != nil {
+		status = http.StatusInternalServerError
+	}
+	if key != "" {
+		h.finishIdempotencyKey(r, owner, key, status, data)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setContentLanguage(w, localizer(r).Lang())
+	writeJSONBytes(w, status, data)
+}
+
+// Сохраняет ответ под зарезервированным ключом. Ответы с ошибкой сервера не сохраняются,
+// резерв снимается, чтобы клиент мог повторить запрос
+func (h *Handler) finishIdempotencyKey(r *http.Request, owner, key string, status int, data []byte) {
+	// Ответ сохраняется, даже если клиент уже отключился
+	ctx := context.WithoutCancel(r.Context())
+	var err error
+	if status >= http.StatusInternalServerError {
+		err = h.idempotency.ReleaseIdempotencyKey(ctx, owner, key)
+	} else {
+		err = h.idempotency.CompleteIdempotencyKey(ctx, owner, key, status, data)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "error saving idempotency key", "idempotency_key", key, logger.KeyError, err)
+	}
+}
+
+// Разбирает тело запроса и передает заказы приемнику.
+// Ошибка одиночного заказа или всего пакета возвращается как problem
+func (h *Handler) ingest(r *http.Request, body []byte) (int, any) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return h.ingestBatch(r, trimmed)
+	}
+
+	uid, err := h.ingestOne(r.Context(), trimmed)
+	if err != nil {
+		p := problemForError(r, err)
+		return p.Status, p
+	}
+	return h.ingester.SuccessStatus(), ingestResultFor(localizer(r), uid, nil)
+}
+
+// Прием пакета заказов; каждый заказ обрабатывается независимо
+func (h *Handler) ingestBatch(r *http.Request, body []byte) (int, any) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return http.StatusBadRequest, newProblem(r, http.StatusBadRequest, codeInvalidJSON, err.Error())
+	}
+	if len(raw) == 0 || len(raw) > maxIngestBatchSize {
+		return http.StatusBadRequest, newProblem(r, http.StatusBadRequest, codeInvalidBatch, maxIngestBatchSize)
+	}
+
+	l := localizer(r)
+	resp := ingestBatchResponse{Results: make([]ingestResult, 0, len(raw))}
+	allAccepted := true
+	for _, msg := range raw {
+		uid, err := h.ingestOne(r.Context(), msg)
+		if err != nil {
+			allAccepted = false
+			if statusForError(err) >= http.StatusInternalServerError {
+				slog.ErrorContext(r.Context(), "error ingesting order", logger.KeyOrderUID, uid, logger.KeyError, err)
+			}
+		}
+		resp.Results = append(resp.Results, ingestResultFor(l, uid, err))
+	}
+
+	if !allAccepted {
+		return http.StatusMultiStatus, resp
+	}
+	return h.ingester.SuccessStatus(), resp
+}
+
+// Разбор, валидация и передача одного заказа; возвращает order_uid, если его удалось прочитать
+func (h *Handler) ingestOne(ctx context.Context, msg []byte) (string, error) {
+	var order domain.Order
+	if err := json.Unmarshal(msg, &order); err != nil {
+		return "", invalidJSON(err)
+	}
+	if err := domain.ValidateOrder(&order); err != nil {
+		return order.OrderUID, service.InvalidInput(service.CodeInvalidOrder, err.Error(), err)
+	}
+	return order.OrderUID, h.ingester.IngestOrder(ctx, &order)
+}
+
+// Результат приема заказа в пакете на языке запроса; внутренние ошибки клиенту не раскрываются
+func ingestResultFor(l *i18n.Localizer, uid string, err error) ingestResult {
+	res := ingestResult{OrderUID: uid, Status: "accepted"}
+	if err == nil {
+		return res
+	}
+
+	res.Status = "failed"
+	if errors.Is(err, service.ErrInvalidInput) {
+		res.Status = "invalid"
+	}
+	res.Code, res.Error = codeForError(err), localizeError(l, err)
+	return res
+}
+
+// Записывает готовый JSON с указанным кодом ответа; ответы с ошибкой
+// сохраняются под ключом идемпотентности в формате problem
+func writeJSONBytes(w http.ResponseWriter, status int, data []byte) {
+	contentType := "application/json"
+	if status >= http.StatusBadRequest {
+		contentType = problemContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
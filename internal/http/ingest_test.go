@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// --- mocks ---
+type mockIngester struct {
+	ingested []string
+	err      error
+}
+
+func (m *mockIngester) IngestOrder(ctx context.Context, order *domain.Order) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.ingested = append(m.ingested, order.OrderUID)
+	return nil
+}
+func (m *mockIngester) SuccessStatus() int { return http.StatusCreated }
+
+type memIdempotency struct {
+	records map[string]*repository.IdempotencyRecord
+}
+
+func (m *memIdempotency) ReserveIdempotencyKey(ctx context.Context, owner, key, requestHash string) (*repository.IdempotencyRecord, error) {
+	if rec, ok := m.records[owner+"/"+key]; ok {
+		return rec, nil
+	}
+	m.records[owner+"/"+key] = &repository.IdempotencyRecord{Owner: owner, Key: key, RequestHash: requestHash}
+	return nil, nil
+}
+func (m *memIdempotency) CompleteIdempotencyKey(ctx context.Context, owner, key string, statusCode int, response []byte) error {
+	rec := m.records[owner+"/"+key]
+	rec.StatusCode, rec.Response = statusCode, response
+	return nil
+}
+func (m *memIdempotency) ReleaseIdempotencyKey(ctx context.Context, owner, key string) error {
+	delete(m.records, owner+"/"+key)
+	return nil
+}
+func (m *memIdempotency) DeleteIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+const validOrder = `{"order_uid":"o1","payment":{"currency":"USD","amount":10},
+	"delivery":{"email":"a@b.c"},"items":[{"name":"x","price":1,"total_price":1}]}`
+
+func newIngestHandler(ing *mockIngester) *Handler {
+	return NewHandler(RouterDeps{
+		Ingester:    ing,
+		Idempotency: &memIdempotency{records: map[string]*repository.IdempotencyRecord{}},
+	})
+}
+
+func postOrders(h *Handler, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.CreateOrders(rec, req)
+	return rec
+}
+
+// --- Тесты ---
+
+// Один валидный заказ
+func TestCreateOrders_Single(t *testing.T) {
+	ing := &mockIngester{}
+	rec := postOrders(newIngestHandler(ing), validOrder, "")
+
+	if rec.Code != http.StatusCreated || len(ing.ingested) != 1 {
+		t.Fatalf("unexpected result: %d, %v", rec.Code, ing.ingested)
+	}
+}
+
+// Невалидный заказ не доходит до приемника
+func TestCreateOrders_Invalid(t *testing.T) {
+	ing := &mockIngester{}
+	rec := postOrders(newIngestHandler(ing), `{"order_uid":""}`, "")
+
+	if rec.Code != http.StatusBadRequest || len(ing.ingested) != 0 {
+		t.Fatalf("unexpected result: %d, %v", rec.Code, ing.ingested)
+	}
+}
+
+// Пакет с невалидным заказом возвращает 207
+func TestCreateOrders_BatchPartial(t *testing.T) {
+	ing := &mockIngester{}
+	rec := postOrders(newIngestHandler(ing), "["+validOrder+`,{"order_uid":"bad"}]`, "")
+
+	if rec.Code != http.StatusMultiStatus || len(ing.ingested) != 1 {
+		t.Fatalf("unexpected result: %d, %v", rec.Code, ing.ingested)
+	}
+}
+
+// Ошибка приемника не сохраняется под ключом идемпотентности
+func TestCreateOrders_FailureNotRemembered(t *testing.T) {
+	ing := &mockIngester{err: errors.New("db down")}
+	h := newIngestHandler(ing)
+
+	if rec := postOrders(h, validOrder, "k1"); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	ing.err = nil
+	if rec := postOrders(h, validOrder, "k1"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected retry to succeed, got %d", rec.Code)
+	}
+}
+
+// Повтор с тем же ключом отдает сохраненный ответ
+func TestCreateOrders_IdempotentReplay(t *testing.T) {
+	ing := &mockIngester{}
+	h := newIngestHandler(ing)
+
+	postOrders(h, validOrder, "k1")
+	rec := postOrders(h, validOrder, "k1")
+
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected replayed response, got %d", rec.Code)
+	}
+	if len(ing.ingested) != 1 {
+		t.Errorf("order ingested %d times, want 1", len(ing.ingested))
+	}
+
+	if rec := postOrders(h, `{"order_uid":"other"}`, "k1"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for key reuse, got %d", rec.Code)
+	}
+}
+
+// Повтор с ключом, запрос по которому еще выполняется, получает 409
+func TestCreateOrders_KeyInProgress(t *testing.T) {
+	ing := &mockIngester{}
+	idem := &memIdempotency{records: map[string]*repository.IdempotencyRecord{}}
+	h := NewHandler(RouterDeps{Ingester: ing, Idempotency: idem})
+
+	sum := sha256.Sum256([]byte(validOrder))
+	idem.ReserveIdempotencyKey(context.Background(), "anonymous", "k1", hex.EncodeToString(sum[:]))
+
+	rec := postOrders(h, validOrder, "k1")
+	if rec.Code != http.StatusConflict || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 409 with Retry-After, got %d", rec.Code)
+	}
+	if len(ing.ingested) != 0 {
+		t.Errorf("order ingested while key is in progress")
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/service"
+)
+
+// Выборка из журнала обработанных сообщений Kafka для аудита
+func (h *Handler) ListProcessedMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := domain.ProcessedMessageFilter{
+		Topic:     q.Get("topic"),
+		OrderUID:  q.Get("order_uid"),
+		MessageID: q.Get("message_id"),
+	}
+	if v := q.Get("partition"); v != "" {
+		partition, err := strconv.Atoi(v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "partition")
+			return
+		}
+		filter.Partition = &partition
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	messages, err := h.ledger.ListProcessedMessages(r.Context(), filter)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
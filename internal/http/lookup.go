@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/encryption"
+	"github.com/Tommych123/L0-WB/internal/service"
+)
+
+// Поиск order_uid по email или телефону получателя (ровно один из параметров)
+func (h *Handler) LookupOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	email, phone := q.Get("email"), q.Get("phone")
+
+	var kind, value string
+	switch {
+	case email != "" && phone == "":
+		kind, value = encryption.KindEmail, email
+	case phone != "" && email == "":
+		kind, value = encryption.KindPhone, phone
+	default:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "email|phone")
+		return
+	}
+
+	uids, err := h.lookup.FindOrderUIDs(r.Context(), kind, value)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"order_uids": uids})
+}
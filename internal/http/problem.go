@@ -0,0 +1,159 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/i18n"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/service"
+)
+
+// Тип содержимого ответов об ошибках (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// Стабильные коды ошибок HTTP слоя; коды сервиса описаны в service.
+// Текст ошибки для клиента берется из каталога по ключу "error.<code>"
+const (
+	codeInternal                 = "internal_error"
+	codeInvalidBody              = "invalid_body"
+	codeBodyTooLarge             = "body_too_large"
+	codeInvalidJSON              = "invalid_json"
+	codeInvalidParameter         = "invalid_parameter"
+	codeInvalidBatch             = "invalid_batch"
+	codeIdempotencyKeyReused     = "idempotency_key_reused"
+	codeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	codeBrokerUnavailable        = "broker_unavailable"
+	codeInvalidWebhook           = "invalid_webhook"
+	codeWebhookNotFound          = "webhook_not_found"
+	codeDeliveryNotFound         = "delivery_not_found"
+	codeRouteNotFound            = "route_not_found"
+	codeMethodNotAllowed         = "method_not_allowed"
+)
+
+// Описание ошибки в формате RFC 7807 с расширениями code и request_id
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	lang      string
+}
+
+// Создание описания ошибки HTTP слоя; текст переводится на язык запроса
+func newProblem(r *http.Request, status int, code string, args ...any) problem {
+	l := localizer(r)
+	return problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    l.T("error."+code, args...),
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logger.RequestID(r.Context()),
+		lang:      l.Lang(),
+	}
+}
+
+// Код ответа для категории ошибки сервиса
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Стабильный код ошибки; неклассифицированные ошибки считаются внутренними
+func codeForError(err error) string {
+	var svcErr *service.Error
+	if errors.As(err, &svcErr) {
+		return svcErr.Code
+	}
+	return codeInternal
+}
+
+// Текст ошибки для клиента: перевод ошибки валидации или сообщения по коду.
+// Внутренние ошибки заменяются общим сообщением
+func localizeError(l *i18n.Localizer, err error) string {
+	var ve *domain.ValidationError
+	if errors.As(err, &ve) {
+		return l.T(ve.Key, ve.Args...)
+	}
+	var svcErr *service.Error
+	if errors.As(err, &svcErr) {
+		if key := "error." + svcErr.Code; l.Has(key) {
+			return l.T(key, svcErr.Args...)
+		}
+		return svcErr.Message
+	}
+	return l.T("error." + codeInternal)
+}
+
+// Преобразует ошибку в описание для клиента. Клиент видит только код и
+// безопасное сообщение; исходная ошибка пишется в лог
+func problemForError(r *http.Request, err error) problem {
+	status := statusForError(err)
+	code := codeForError(err)
+
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "code", code, logger.KeyError, err)
+	} else {
+		slog.InfoContext(r.Context(), "request rejected", "code", code, logger.KeyError, err)
+	}
+
+	p := newProblem(r, status, code)
+	p.Detail = localizeError(localizer(r), err)
+	return p
+}
+
+// Отвечает описанием ошибки сервиса или внутренней ошибки
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblemBody(w, problemForError(r, err))
+}
+
+// Отвечает ошибкой HTTP слоя с указанным кодом; args — параметры сообщения
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, args ...any) {
+	writeProblemBody(w, newProblem(r, status, code, args...))
+}
+
+func writeProblemBody(w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	setContentLanguage(w, p.lang)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Ошибка разбора JSON из тела запроса
+func invalidJSON(err error) error {
+	return &service.Error{
+		Kind:    service.ErrInvalidInput,
+		Code:    codeInvalidJSON,
+		Message: "invalid JSON: " + err.Error(),
+		Args:    []any{err.Error()},
+		Err:     err,
+	}
+}
+
+// Ответ для неизвестного маршрута
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, codeRouteNotFound)
+}
+
+// Ответ для метода, не поддерживаемого маршрутом
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed)
+}
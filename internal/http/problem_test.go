@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tommych123/L0-WB/internal/service"
+)
+
+// Категории ошибок сервиса отображаются в коды ответа
+func TestStatusForError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{service.NotFound(service.CodeOrderNotFound, "order not found"), http.StatusNotFound},
+		{service.InvalidInput(service.CodeInvalidOrder, "bad", nil), http.StatusBadRequest},
+		{service.Conflict(service.CodeWriteConflict, "retry", nil), http.StatusConflict},
+		{service.Unavailable(service.CodeStorageUnavailable, errors.New("dial tcp")), http.StatusServiceUnavailable},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if got := statusForError(c.err); got != c.want {
+			t.Errorf("statusForError(%v) = %d, want %d", c.err, got, c.want)
+		}
+	}
+}
+
+// Внутренняя ошибка не раскрывается клиенту
+func TestWriteError_HidesInternalDetails(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/orders/o1", nil)
+	rec := httptest.NewRecorder()
+	writeError(rec, req, service.Unavailable(service.CodeStorageUnavailable, errors.New("pq: password authentication failed")))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("internal error leaked: %s", rec.Body.String())
+	}
+
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("invalid problem JSON: %v", err)
+	}
+	if p.Code != service.CodeStorageUnavailable || p.Status != http.StatusServiceUnavailable || p.Instance != "/orders/o1" {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+}
+
+// Невалидный одиночный заказ возвращает problem с кодом invalid_order
+func TestCreateOrders_InvalidProblem(t *testing.T) {
+	rec := postOrders(newIngestHandler(&mockIngester{}), `{"order_uid":""}`, "")
+
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("invalid problem JSON: %v", err)
+	}
+	if rec.Header().Get("Content-Type") != problemContentType || p.Code != service.CodeInvalidOrder {
+		t.Errorf("unexpected response: %s %s", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
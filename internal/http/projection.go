@@ -0,0 +1,243 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Связанные сущности заказа, которыми управляет ?include=
+const (
+	includeDelivery = "delivery"
+	includePayment  = "payment"
+	includeItems    = "items"
+)
+
+// Выборка полей ответа с заказом из ?fields= и ?include=
+type projection struct {
+	// Запрошенные поля: "order_uid" или "payment.amount"; nil — все поля
+	fields map[string]bool
+	// Встраиваемые сущности; nil — все
+	include map[string]bool
+}
+
+// Разбирает ?fields= и ?include=; nil, если ни один параметр не задан.
+// Ошибка содержит имя некорректного параметра
+func parseProjection(q url.Values) (*projection, error) {
+	if !q.Has("fields") && !q.Has("include") {
+		return nil, nil
+	}
+	schema := orderSchema()
+	p := &projection{}
+
+	if q.Has("include") {
+		p.include = make(map[string]bool)
+		for _, name := range splitList(q.Get("include")) {
+			switch name {
+			case includeDelivery, includePayment, includeItems:
+				p.include[name] = true
+			default:
+				return nil, errors.New("include")
+			}
+		}
+	}
+
+	if q.Has("fields") {
+		p.fields = make(map[string]bool)
+		for _, path := range splitList(q.Get("fields")) {
+			parent, child, nested := strings.Cut(path, ".")
+			children, known := schema[parent]
+			if !known || (nested && (children == nil || !children[child])) {
+				return nil, errors.New("fields")
+			}
+			p.fields[path] = true
+		}
+		if len(p.fields) == 0 {
+			return nil, errors.New("fields")
+		}
+	}
+	return p, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Связанные сущности, которые нужно загрузить для ответа
+func (p *projection) parts() domain.OrderParts {
+	if p == nil {
+		return domain.AllOrderParts
+	}
+	// Суммы в display форматируются из payment, цены позиций — из items
+	display := p.wants("display") && p.included(includePayment)
+	return domain.OrderParts{
+		Delivery: p.wants(includeDelivery),
+		Payment:  p.wants(includePayment) || display,
+		Items:    p.wants(includeItems) || (display && p.included(includeItems) && p.wantsField("display", "items")),
+	}
+}
+
+// Разрешена ли сущность параметром ?include=
+func (p *projection) included(name string) bool {
+	return p.include == nil || p.include[name]
+}
+
+// Запрошено ли поле parent.child (или parent целиком)
+func (p *projection) wantsField(parent, child string) bool {
+	return p.fields == nil || p.fields[parent] || p.fields[parent+"."+child]
+}
+
+// Нужна ли в ответе сущность или поле верхнего уровня name
+func (p *projection) wants(name string) bool {
+	if isInclude(name) && !p.included(name) {
+		return false
+	}
+	if p.fields == nil {
+		return true
+	}
+	if p.fields[name] {
+		return true
+	}
+	for path := range p.fields {
+		if strings.HasPrefix(path, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isInclude(name string) bool {
+	return name == includeDelivery || name == includePayment || name == includeItems
+}
+
+// Оставляет в ответе только запрошенные поля. Блок display требует payment,
+// а display.items — items: без них он убирается
+func (p *projection) apply(resp orderResponse) (map[string]any, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(resp); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for key := range doc {
+		if !p.wants(key) {
+			delete(doc, key)
+		}
+	}
+	if display, ok := doc["display"].(map[string]any); ok {
+		if !p.included(includePayment) {
+			delete(doc, "display")
+		} else if !p.included(includeItems) {
+			delete(display, "items")
+		}
+	}
+
+	if p.fields != nil {
+		for key, value := range doc {
+			if p.fields[key] {
+				continue
+			}
+			switch v := value.(type) {
+			case map[string]any:
+				p.pruneObject(key, v)
+			case []any:
+				for _, elem := range v {
+					if obj, ok := elem.(map[string]any); ok {
+						p.pruneObject(key, obj)
+					}
+				}
+			}
+		}
+	}
+	normalizeNumbers(doc)
+	return doc, nil
+}
+
+// Кодирует ответ с заказом с учетом выборки полей; proj == nil — ответ целиком
+func encodeOrder(format orderFormat, proj *projection, resp orderResponse) ([]byte, error) {
+	if proj == nil {
+		return format.encode(resp)
+	}
+	doc, err := proj.apply(resp)
+	if err != nil {
+		return nil, err
+	}
+	return format.encodeDoc(doc)
+}
+
+// Оставляет в объекте parent только поля вида parent.child
+func (p *projection) pruneObject(parent string, obj map[string]any) {
+	for child := range obj {
+		if !p.fields[parent+"."+child] {
+			delete(obj, child)
+		}
+	}
+}
+
+// Числа из json.Number в int64 или float64, чтобы MessagePack кодировал их числами
+func normalizeNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = normalizeNumbers(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = normalizeNumbers(elem)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+var (
+	schemaOnce sync.Once
+	schema     map[string]map[string]bool
+)
+
+// Допустимые поля ответа: верхний уровень и поля вложенных объектов.
+// Строится по JSON представлению пустого заказа
+func orderSchema() map[string]map[string]bool {
+	schemaOnce.Do(func() {
+		keysOf := func(v any) map[string]bool {
+			data, _ := json.Marshal(v)
+			var m map[string]any
+			json.Unmarshal(data, &m)
+			keys := make(map[string]bool, len(m))
+			for k := range m {
+				keys[k] = true
+			}
+			return keys
+		}
+		schema = make(map[string]map[string]bool)
+		for k := range keysOf(orderResponse{Order: &domain.Order{}}) {
+			schema[k] = nil
+		}
+		schema["delivery"] = keysOf(domain.Delivery{})
+		schema["payment"] = keysOf(domain.Payment{})
+		schema["items"] = keysOf(domain.Item{})
+		schema["display"] = keysOf(orderDisplay{})
+	})
+	return schema
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/i18n"
+)
+
+func TestParseProjection(t *testing.T) {
+	if p, err := parseProjection(url.Values{}); p != nil || err != nil {
+		t.Fatalf("no params: got %v, %v", p, err)
+	}
+	bad := map[string]string{
+		"fields=unknown":        "fields",
+		"fields=payment.nope":   "fields",
+		"fields=order_uid.x":    "fields",
+		"fields=,":              "fields",
+		"include=items,reviews": "include",
+	}
+	for query, param := range bad {
+		q, _ := url.ParseQuery(query)
+		if _, err := parseProjection(q); err == nil || err.Error() != param {
+			t.Errorf("%s: got %v, want error %q", query, err, param)
+		}
+	}
+}
+
+// Загружаются только таблицы, нужные для запрошенных полей
+func TestProjection_Parts(t *testing.T) {
+	cases := map[string]domain.OrderParts{
+		"fields=order_uid":                    {},
+		"fields=order_uid,payment.amount":     {Payment: true},
+		"include=items":                       {Items: true},
+		"fields=display.amount":               {Payment: true},
+		"fields=display":                      {Payment: true, Items: true},
+		"fields=display&include=payment":      {Payment: true},
+		"fields=delivery.name,items&include=": {},
+	}
+	for query, want := range cases {
+		q, _ := url.ParseQuery(query)
+		p, err := parseProjection(q)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got := p.parts(); got != want {
+			t.Errorf("%s: got %+v, want %+v", query, got, want)
+		}
+	}
+	if got := (*projection)(nil).parts(); got != domain.AllOrderParts {
+		t.Errorf("nil projection: got %+v", got)
+	}
+}
+
+func TestProjection_Apply(t *testing.T) {
+	order := &domain.Order{
+		OrderUID:    "o1",
+		TrackNumber: "WB1",
+		DateCreated: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Delivery:    domain.Delivery{Name: "Test"},
+		Payment:     domain.Payment{Currency: "USD", Amount: 1817},
+		Items:       []domain.Item{{RID: "r1", Name: "Mascaras", Price: 453}},
+	}
+	resp := newOrderResponse(order, i18n.For("en"))
+
+	q, _ := url.ParseQuery("fields=order_uid,payment.amount,items.name")
+	p, _ := parseProjection(q)
+	doc, err := p.apply(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc) != 3 || doc["order_uid"] != "o1" {
+		t.Fatalf("unexpected fields: %v", doc)
+	}
+	if payment := doc["payment"].(map[string]any); len(payment) != 1 || payment["amount"] != int64(1817) {
+		t.Errorf("unexpected payment: %v", payment)
+	}
+	items := doc["items"].([]any)
+	if item := items[0].(map[string]any); len(item) != 1 || item["name"] != "Mascaras" {
+		t.Errorf("unexpected item: %v", item)
+	}
+
+	q, _ = url.ParseQuery("include=delivery")
+	p, _ = parseProjection(q)
+	if doc, err = p.apply(resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"payment", "items", "display"} {
+		if _, ok := doc[key]; ok {
+			t.Errorf("%s must be excluded: %v", key, doc)
+		}
+	}
+	if doc["track_number"] != "WB1" || doc["delivery"] == nil {
+		t.Errorf("unexpected document: %v", doc)
+	}
+}
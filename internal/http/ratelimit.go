@@ -0,0 +1,336 @@
+package http
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/metrics"
+)
+
+const codeRateLimited = "rate_limited"
+
+// Классы маршрутов с отдельными лимитами
+const (
+	// Чтение заказов и лент
+	RateClassRead = "read"
+	// Прием заказов
+	RateClassWrite = "write"
+	// Аудит и управление
+	RateClassAdmin = "admin"
+	// Неудачные попытки аутентификации с одного IP адреса
+	RateClassAuth = "auth"
+)
+
+// Лимиты по умолчанию: "класс=запросов_в_секунду:всплеск"
+const DefaultRateLimits = "read=50:100,write=10:20,admin=5:10,auth=1:10"
+
+// Лимит token bucket: скорость пополнения и емкость корзины
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Разбирает лимиты в формате "класс=rate:burst" через запятую
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		class, spec, ok := strings.Cut(entry, "=")
+		rateStr, burstStr, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("rate limit %q: expected class=rate:burst", entry)
+		}
+		switch class {
+		case RateClassRead, RateClassWrite, RateClassAdmin, RateClassAuth:
+		default:
+			return nil, fmt.Errorf("rate limit %q: unknown class %q", entry, class)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("rate limit %q: rate must be a positive number", entry)
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("rate limit %q: burst must be a positive integer", entry)
+		}
+		limits[class] = RateLimit{Rate: rate, Burst: burst}
+	}
+	return limits, nil
+}
+
+// Корзина одного клиента в одном классе маршрутов
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	allowed  uint64
+	rejected uint64
+}
+
+type bucketKey struct {
+	client string
+	class  string
+}
+
+// Ограничитель частоты запросов по клиенту и классу маршрута.
+// Клиент — вызывающий, прошедший аутентификацию, иначе IP адрес
+type RateLimiter struct {
+	limits map[string]RateLimit
+	// Брать IP клиента из X-Forwarded-For (только за доверенным прокси)
+	trustForwardedFor bool
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// Создание ограничителя; класс без лимита не ограничивается
+func NewRateLimiter(limits map[string]RateLimit, trustForwardedFor bool) *RateLimiter {
+	return &RateLimiter{
+		limits:            limits,
+		trustForwardedFor: trustForwardedFor,
+		buckets:           make(map[bucketKey]*bucket),
+		now:               time.Now,
+	}
+}
+
+// Решение по одному запросу
+type rateDecision struct {
+	allowed    bool
+	limit      RateLimit
+	remaining  int
+	retryAfter time.Duration
+	// Время до полного пополнения корзины
+	reset time.Duration
+}
+
+// Забирает токен из корзины клиента
+func (l *RateLimiter) take(client, class string, limit RateLimit) rateDecision {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	key := bucketKey{client, class}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = refill(b, limit, now)
+	b.last = now
+
+	d := rateDecision{limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		b.allowed++
+		d.allowed = true
+	} else {
+		b.rejected++
+		d.retryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return d
+}
+
+// Проверяет корзину клиента, не забирая токен; отказ учитывается в счетчиках
+func (l *RateLimiter) peek(client, class string, limit RateLimit) rateDecision {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := rateDecision{limit: limit, allowed: true}
+	b, ok := l.buckets[bucketKey{client, class}]
+	if !ok {
+		return d
+	}
+	if tokens := refill(b, limit, now); tokens < 1 {
+		b.rejected++
+		d.allowed = false
+		d.retryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return d
+}
+
+// Количество токенов с учетом пополнения к моменту now
+func refill(b *bucket, limit RateLimit, now time.Time) float64 {
+	return math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Раз в минуту удаляет полностью пополнившиеся корзины, чтобы карта не росла
+// от разовых клиентов. Вызывается под мьютексом
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if refill(b, l.limits[key.class], now) >= float64(l.limits[key.class].Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Ограничивает обработчик лимитом класса; nil ограничитель пропускает все запросы
+func (l *RateLimiter) limit(class string, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	limit, ok := l.limits[class]
+	if !ok {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := l.take(l.clientKey(r), class, limit)
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+		if !d.allowed {
+			writeRateLimited(w, r, class, d)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Отказывает запросу с учетными данными, если с IP клиента исчерпан лимит неудачных
+// попыток аутентификации. Проверка идет независимо от того, верны ли учетные данные,
+// чтобы ответ не подсказывал результат подбора
+func (l *RateLimiter) authThrottled(w http.ResponseWriter, r *http.Request) bool {
+	if l == nil {
+		return false
+	}
+	limit, ok := l.limits[RateClassAuth]
+	if !ok {
+		return false
+	}
+	d := l.peek(l.authKey(r), RateClassAuth, limit)
+	if d.allowed {
+		return false
+	}
+	writeRateLimited(w, r, RateClassAuth, d)
+	return true
+}
+
+// Учитывает неудачную попытку аутентификации с IP клиента
+func (l *RateLimiter) authFailed(r *http.Request) {
+	if l == nil {
+		return
+	}
+	if limit, ok := l.limits[RateClassAuth]; ok {
+		l.take(l.authKey(r), RateClassAuth, limit)
+	}
+}
+
+// Попытки аутентификации считаются по IP: субъект до проверки неизвестен
+func (l *RateLimiter) authKey(r *http.Request) string {
+	return "ip:" + l.clientIP(r)
+}
+
+func writeRateLimited(w http.ResponseWriter, r *http.Request, class string, d rateDecision) {
+	retry := ceilSeconds(d.retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	metrics.HTTPRateLimited(class)
+	slog.InfoContext(r.Context(), "rate limit exceeded", "class", class, "retry_after_s", retry)
+	writeProblem(w, r, http.StatusTooManyRequests, codeRateLimited, retry)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Ключ клиента: субъект API ключа или JWT, для анонимных запросов — IP адрес
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil && p.Method != "" {
+		return "subject:" + p.Subject
+	}
+	return l.authKey(r)
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Текущее состояние корзины клиента
+type RateLimitUsage struct {
+	Client    string    `json:"client"`
+	Class     string    `json:"class"`
+	Rate      float64   `json:"rate"`
+	Burst     int       `json:"burst"`
+	Remaining int       `json:"remaining"`
+	Allowed   uint64    `json:"allowed"`
+	Rejected  uint64    `json:"rejected"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Снимок корзин активных клиентов. Счетчики сбрасываются, когда корзина
+// полностью пополняется и удаляется
+func (l *RateLimiter) Usage() []RateLimitUsage {
+	now := l.now()
+
+	l.mu.Lock()
+	usage := make([]RateLimitUsage, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		limit := l.limits[key.class]
+		usage = append(usage, RateLimitUsage{
+			Client:    key.client,
+			Class:     key.class,
+			Rate:      limit.Rate,
+			Burst:     limit.Burst,
+			Remaining: int(refill(b, limit, now)),
+			Allowed:   b.allowed,
+			Rejected:  b.rejected,
+			LastSeen:  b.last,
+		})
+	}
+	l.mu.Unlock()
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Client != usage[j].Client {
+			return usage[i].Client < usage[j].Client
+		}
+		return usage[i].Class < usage[j].Class
+	})
+	return usage
+}
+
+// Текущее использование лимитов клиентами
+func (h *Handler) ListRateLimits(w http.ResponseWriter, r *http.Request) {
+	usage := []RateLimitUsage{}
+	if h.rateLimiter != nil {
+		usage = h.rateLimiter.Usage()
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
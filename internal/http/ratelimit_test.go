@@ -0,0 +1,120 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+)
+
+// Разбор лимитов и ошибки формата
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits(DefaultRateLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits[RateClassRead] != (RateLimit{Rate: 50, Burst: 100}) {
+		t.Errorf("unexpected read limit: %+v", limits[RateClassRead])
+	}
+	for _, bad := range []string{"read=1", "other=1:1", "read=0:1", "read=1:0"} {
+		if _, err := ParseRateLimits(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+// После исчерпания всплеска ответ 429 с Retry-After; корзины разных клиентов независимы
+func TestRateLimiter_Limit(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: "a:admin:ak,b:admin:bk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewRateLimiter(map[string]RateLimit{RateClassAdmin: {Rate: 1, Burst: 2}}, false)
+	now := time.Unix(1000, 0)
+	rl.now = func() time.Time { return now }
+	router := NewRouter(RouterDeps{Auth: a, RateLimiter: rl})
+
+	do := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/rate-limits", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := do("ak"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d", i, rec.Code)
+		}
+	}
+	rec := do("ak")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers: %v", rec.Header())
+	}
+	if rec := do("bk"); rec.Code != http.StatusOK {
+		t.Errorf("other client must not be limited, got %d", rec.Code)
+	}
+
+	now = now.Add(time.Second)
+	if rec := do("ak"); rec.Code != http.StatusOK {
+		t.Errorf("expected token to be refilled, got %d", rec.Code)
+	}
+
+	usage := rl.Usage()
+	if len(usage) != 2 || usage[0].Client != "subject:a" || usage[0].Allowed != 3 || usage[0].Rejected != 1 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+// Неудачные попытки аутентификации ограничиваются по IP; при исчерпанном лимите
+// отклоняются и верные учетные данные, чтобы ответ не выдавал результат подбора
+func TestRateLimiter_AuthFailures(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: "a:admin:ak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewRateLimiter(map[string]RateLimit{RateClassAuth: {Rate: 1, Burst: 2}}, false)
+	now := time.Unix(1000, 0)
+	rl.now = func() time.Time { return now }
+	router := NewRouter(RouterDeps{Auth: a, RateLimiter: rl})
+
+	do := func(ip, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/rate-limits", nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := do("192.0.2.1", "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, rec.Code)
+		}
+	}
+	rec := do("192.0.2.1", "wrong")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := do("192.0.2.1", "ak"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("valid key from throttled IP: expected 429, got %d", rec.Code)
+	}
+	if rec := do("192.0.2.1", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without credentials: expected 401, got %d", rec.Code)
+	}
+	if rec := do("192.0.2.2", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("other IP must not be limited, got %d", rec.Code)
+	}
+
+	now = now.Add(time.Second)
+	if rec := do("192.0.2.1", "ak"); rec.Code != http.StatusOK {
+		t.Errorf("expected token to be refilled, got %d", rec.Code)
+	}
+}
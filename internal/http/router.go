@@ -1,17 +1,113 @@
 package http
 
 import (
+	"net/http"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/health"
+	"github.com/Tommych123/L0-WB/internal/redact"
+	"github.com/Tommych123/L0-WB/internal/repository"
 	"github.com/Tommych123/L0-WB/internal/service"
+	"github.com/Tommych123/L0-WB/internal/stream"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func NewRouter(orderService *service.OrderService) *mux.Router {
+// Зависимости HTTP слоя
+type RouterDeps struct {
+	OrderService *service.OrderService
+	Ingester     OrderIngester
+	Idempotency  repository.IdempotencyRepository
+	Ledger       repository.LedgerRepository
+	Lookup       repository.LookupRepository
+	Analytics    repository.AnalyticsRepository
+	Webhooks     repository.WebhookRepository
+	Stream       *stream.Broker
+	Health       *health.Checker
+	// Проверка API ключей и JWT; nil выключает аутентификацию
+	Auth *auth.Authenticator
+	// Скрытие персональных данных по роли; nil — правила по умолчанию
+	Redaction *redact.Policy
+	// Ограничение частоты запросов по клиенту; nil выключает ограничение
+	RateLimiter *RateLimiter
+	// Cache-Control ответа с заказом; пустое значение — DefaultOrderCacheControl
+	OrderCacheControl string
+	// Origin страниц других доменов, которым разрешена лента через WebSocket
+	StreamOrigins []string
+}
+
+func NewRouter(deps RouterDeps) *mux.Router {
 	r := mux.NewRouter()
 
-	h := NewHandler(orderService)
+	h := NewHandler(deps)
+	rl := deps.RateLimiter
+
+	r.Use(requestIDMiddleware, metricsMiddleware, tracingMiddleware, compressionMiddleware, authMiddleware(deps.Auth, rl))
+
+	// Ошибки маршрутизации в том же формате, что и ошибки обработчиков
+	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+
+	// Проверки, метрики и веб страница доступны без аутентификации, остальные
+	// маршруты требуют роль: reader — чтение заказов, support — прием заказов
+	// и аудит, admin — управление webhook и данными клиентов.
+	// Лимиты частоты запросов раздельные для чтения, приема заказов и администрирования
+
+	// Проверки liveness и readiness
+	r.HandleFunc("/healthz", h.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", h.Readyz).Methods("GET")
+
+	// Метрики Prometheus
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// Прием заказов напрямую (один заказ или массив)
+	r.HandleFunc("/orders", rl.limit(RateClassWrite, requireRole(auth.RoleSupport, h.CreateOrders))).Methods("POST")
+
+	// Пакетная выдача заказов по списку order_uid
+	r.HandleFunc("/orders:batchGet", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.BatchGetOrders))).Methods("POST")
+
+	// Лента новых заказов (регистрируется раньше /orders/{id})
+	r.HandleFunc("/orders/stream", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.StreamOrdersSSE))).Methods("GET")
+	r.HandleFunc("/orders/ws", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.StreamOrdersWS))).Methods("GET")
+
+	// Выгрузка заказов за период в NDJSON или CSV
+	r.HandleFunc("/orders/export", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.ExportOrders))).Methods("GET")
+
+	// Поиск заказов по email или телефону получателя
+	r.HandleFunc("/orders/lookup", rl.limit(RateClassRead, requireRole(auth.RoleSupport, h.LookupOrders))).Methods("GET")
+
+	// Мягкое удаление, список удаленных и восстановление заказов
+	r.HandleFunc("/orders/deleted", rl.limit(RateClassAdmin, requireRole(auth.RoleSupport, h.ListDeletedOrders))).Methods("GET")
+	r.HandleFunc("/orders/{id}", rl.limit(RateClassWrite, requireRole(auth.RoleSupport, h.DeleteOrder))).Methods("DELETE")
+	r.HandleFunc("/orders/{id}/restore", rl.limit(RateClassWrite, requireRole(auth.RoleSupport, h.RestoreOrder))).Methods("POST")
+
+	// Журнал обработанных сообщений Kafka
+	r.HandleFunc("/processed-messages", rl.limit(RateClassAdmin, requireRole(auth.RoleSupport, h.ListProcessedMessages))).Methods("GET")
+
+	// Подписки на webhook и журнал доставок
+	r.HandleFunc("/webhooks", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.ListWebhooks))).Methods("GET")
+	r.HandleFunc("/webhooks", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.CreateWebhook))).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.GetWebhook))).Methods("GET")
+	r.HandleFunc("/webhooks/{id}", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.UpdateWebhook))).Methods("PUT")
+	r.HandleFunc("/webhooks/{id}", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.DeleteWebhook))).Methods("DELETE")
+	r.HandleFunc("/webhooks/{id}/deliveries", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.ListWebhookDeliveries))).Methods("GET")
+	r.HandleFunc("/webhooks/deliveries/{id}/redeliver", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.RedeliverWebhook))).Methods("POST")
+
+	// Агрегаты по заказам: выручка, разбивки, топ позиций
+	r.HandleFunc("/analytics/revenue", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.AnalyticsRevenue))).Methods("GET")
+	r.HandleFunc("/analytics/breakdown", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.AnalyticsBreakdown))).Methods("GET")
+	r.HandleFunc("/analytics/top-items", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.AnalyticsTopItems))).Methods("GET")
+	r.HandleFunc("/analytics/summary", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.AnalyticsSummary))).Methods("GET")
+
+	// Текущее использование лимитов запросов
+	r.HandleFunc("/rate-limits", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.ListRateLimits))).Methods("GET")
+
+	// Выгрузка и удаление данных клиента по запросу субъекта данных
+	r.HandleFunc("/customers/{id}/export", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.ExportCustomer))).Methods("GET")
+	r.HandleFunc("/customers/{id}/erase", rl.limit(RateClassAdmin, requireRole(auth.RoleAdmin, h.EraseCustomer))).Methods("POST")
 
 	// Эндпоинт для выдачи заказа по ID
-	r.HandleFunc("/orders/{id}", h.GetOrderByID).Methods("GET")
+	r.HandleFunc("/orders/{id}", rl.limit(RateClassRead, requireRole(auth.RoleReader, h.GetOrderByID))).Methods("GET")
 
 	// Веб страница
 	r.HandleFunc("/", h.ServeWebUI).Methods("GET")
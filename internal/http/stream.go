@@ -0,0 +1,186 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/stream"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Интервал keep-alive сообщений для прокси и балансировщиков
+	streamHeartbeat = 15 * time.Second
+	// Сколько ждать записи клиенту, прежде чем считать его зависшим
+	streamWriteTimeout = 10 * time.Second
+)
+
+// Проверка Origin при открытии WebSocket: разрешены запросы без Origin (не из браузера),
+// со страниц того же хоста и из allowed. Значения allowed — "scheme://host[:port]"
+func checkStreamOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range allowed {
+			if strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// Сообщение ленты заказов в WebSocket. Reset — продолжить с last_event_id нельзя,
+// заказы нужно перечитать; Order в таком сообщении нет
+type streamMessage struct {
+	ID    string        `json:"id"`
+	Reset bool          `json:"reset,omitempty"`
+	Order *domain.Order `json:"order,omitempty"`
+}
+
+// Фильтр и точка возобновления ленты из запроса
+func streamParams(r *http.Request) (stream.Filter, string) {
+	q := r.URL.Query()
+	filter := stream.Filter{
+		Entry:           q.Get("entry"),
+		DeliveryService: q.Get("delivery_service"),
+		CustomerID:      q.Get("customer_id"),
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = q.Get("last_event_id")
+	}
+	return filter, lastID
+}
+
+// Лента новых заказов через Server-Sent Events
+func (h *Handler) StreamOrdersSSE(w http.ResponseWriter, r *http.Request) {
+	filter, lastID := streamParams(r)
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	sub := h.stream.Subscribe(filter, lastID)
+	defer h.stream.Unsubscribe(sub)
+	role := auth.RoleFrom(r.Context())
+
+	// id в событии reset обновляет Last-Event-ID браузера, чтобы следующее переподключение продолжило ленту
+	if reset := sub.Reset(); reset != "" {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: reset\ndata: {}\n\n", reset); err != nil || rc.Flush() != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Клиент не успевал читать и отключен брокером
+				return
+			}
+			data, err := json.Marshal(h.redaction.Order(event.Order, role))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error encoding stream event", logger.KeyOrderUID, event.Order.OrderUID, logger.KeyError, err)
+				continue
+			}
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: order\ndata: %s\n\n", event.ID, data); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// Лента новых заказов через WebSocket
+func (h *Handler) StreamOrdersWS(w http.ResponseWriter, r *http.Request) {
+	filter, lastID := streamParams(r)
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub := h.stream.Subscribe(filter, lastID)
+	defer h.stream.Unsubscribe(sub)
+	role := auth.RoleFrom(r.Context())
+
+	if reset := sub.Reset(); reset != "" {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := conn.WriteJSON(streamMessage{ID: reset, Reset: true}); err != nil {
+			return
+		}
+	}
+
+	// Читаем входящие кадры только чтобы обрабатывать pong и закрытие соединения
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client is too slow"),
+					time.Now().Add(streamWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			msg := streamMessage{ID: event.ID, Order: h.redaction.Order(event.Order, role)}
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+	}
+}
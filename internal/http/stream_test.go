@@ -0,0 +1,27 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// WebSocket открывается без Origin, с того же хоста и из списка разрешенных
+func TestCheckStreamOrigin(t *testing.T) {
+	check := checkStreamOrigin([]string{"https://admin.example.com"})
+	for origin, want := range map[string]bool{
+		"":                          true,
+		"http://example.com":        true,
+		"https://admin.example.com": true,
+		"https://evil.example.net":  false,
+		"https://example.com.evil":  false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/orders/ws", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if got := check(req); got != want {
+			t.Errorf("origin %q: got %v, want %v", origin, got, want)
+		}
+	}
+}
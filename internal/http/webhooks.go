@@ -0,0 +1,238 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// Тело запроса на создание или изменение подписки
+type webhookRequest struct {
+	URL             string   `json:"url"`
+	Secret          string   `json:"secret"`
+	Entry           string   `json:"entry"`
+	DeliveryService string   `json:"delivery_service"`
+	EventTypes      []string `json:"event_types"`
+	Active          *bool    `json:"active"`
+}
+
+// Проверка тела запроса на подписку
+func (req *webhookRequest) validate() error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.NewValidationError("validation.webhook_url_invalid", "url must be an absolute http(s) URL")
+	}
+	for _, t := range req.EventTypes {
+		if t != domain.EventOrderPersisted && t != domain.EventOrderUpdated {
+			return domain.NewValidationError("validation.webhook_event_type_unknown", "unknown event type: "+t, t)
+		}
+	}
+	return nil
+}
+
+// Список подписок
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.webhooks.ListSubscriptions(r.Context())
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+// Создание подписки; секрет возвращается только в этом ответе
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, r, service.InvalidInput(codeInvalidWebhook, err.Error(), err))
+		return
+	}
+	if req.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		req.Secret = secret
+	}
+
+	sub := &domain.WebhookSubscription{
+		URL:             req.URL,
+		Secret:          req.Secret,
+		Entry:           req.Entry,
+		DeliveryService: req.DeliveryService,
+		EventTypes:      req.EventTypes,
+		Active:          req.Active == nil || *req.Active,
+	}
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	if err := h.webhooks.CreateSubscription(r.Context(), sub); err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+// Получение подписки по ID
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	sub, err := h.webhooks.GetSubscription(r.Context(), id)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	if sub == nil {
+		writeProblem(w, r, http.StatusNotFound, codeWebhookNotFound)
+		return
+	}
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// Изменение подписки; пустой secret оставляет прежний
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, r, service.InvalidInput(codeInvalidWebhook, err.Error(), err))
+		return
+	}
+
+	sub, err := h.webhooks.GetSubscription(r.Context(), id)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	if sub == nil {
+		writeProblem(w, r, http.StatusNotFound, codeWebhookNotFound)
+		return
+	}
+	sub.URL = req.URL
+	sub.Entry = req.Entry
+	sub.DeliveryService = req.DeliveryService
+	sub.EventTypes = req.EventTypes
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	found, err := h.webhooks.UpdateSubscription(r.Context(), sub)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	if !found {
+		writeProblem(w, r, http.StatusNotFound, codeWebhookNotFound)
+		return
+	}
+	sub.Secret = ""
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// Удаление подписки
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.webhooks.DeleteSubscription(r.Context(), id)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	if !found {
+		writeProblem(w, r, http.StatusNotFound, codeWebhookNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Журнал доставок подписки
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "limit")
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.webhooks.ListDeliveries(r.Context(), id, limit)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// Ручная повторная отправка доставки
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.webhooks.RequeueDelivery(r.Context(), id)
+	if err != nil {
+		writeError(w, r, service.StorageError(err))
+		return
+	}
+	if !found {
+		writeProblem(w, r, http.StatusNotFound, codeDeliveryNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Разбор числового ID из пути
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "id")
+		return 0, false
+	}
+	return id, true
+}
+
+// Генерирует случайный секрет для подписи
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,178 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Каталоги сообщений, встроенные в бинарник
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+// Поддерживаемые языки
+const (
+	English = "en"
+	Russian = "ru"
+)
+
+var (
+	localizers  = map[string]*Localizer{}
+	langs       []string
+	matcher     language.Matcher
+	defaultLang = Russian
+)
+
+func init() {
+	if err := load(); err != nil {
+		panic(err)
+	}
+}
+
+// Загружает все встроенные каталоги
+func load() error {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		return err
+	}
+
+	var tags []language.Tag
+	for _, f := range files {
+		data, err := locales.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("catalog %s: %w", f.Name(), err)
+		}
+
+		lang := strings.TrimSuffix(f.Name(), ".json")
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return fmt.Errorf("catalog %s: %w", f.Name(), err)
+		}
+		localizers[lang] = &Localizer{lang: lang, messages: messages, printer: message.NewPrinter(tag)}
+		langs = append(langs, lang)
+		tags = append(tags, tag)
+	}
+	matcher = language.NewMatcher(tags)
+	return nil
+}
+
+// Коды поддерживаемых языков
+func Supported() []string {
+	out := append([]string(nil), langs...)
+	sort.Strings(out)
+	return out
+}
+
+// Задает язык по умолчанию; вызывается при старте до обработки запросов
+func SetDefault(lang string) error {
+	if _, ok := localizers[lang]; !ok {
+		return fmt.Errorf("unsupported language %q", lang)
+	}
+	defaultLang = lang
+	return nil
+}
+
+// Локализатор для языка; неизвестный язык заменяется языком по умолчанию
+func For(lang string) *Localizer {
+	if l, ok := localizers[lang]; ok {
+		return l
+	}
+	return localizers[defaultLang]
+}
+
+// Выбор языка: явно заданный (параметр запроса), затем Accept-Language,
+// затем запасные варианты (например, locale заказа), иначе язык по умолчанию
+func Negotiate(explicit, acceptLanguage string, fallback ...string) *Localizer {
+	if lang, ok := match(explicit); ok {
+		return For(lang)
+	}
+	if acceptLanguage != "" {
+		if tags, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil && len(tags) > 0 {
+			if _, idx, conf := matcher.Match(tags...); conf != language.No {
+				return For(langs[idx])
+			}
+		}
+	}
+	for _, f := range fallback {
+		if lang, ok := match(f); ok {
+			return For(lang)
+		}
+	}
+	return For(defaultLang)
+}
+
+// Сопоставляет один тег языка (ru, en-US, ru_RU) с поддерживаемыми
+func match(s string) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	tag, err := language.Parse(strings.ReplaceAll(s, "_", "-"))
+	if err != nil {
+		return "", false
+	}
+	if _, idx, conf := matcher.Match(tag); conf != language.No {
+		return langs[idx], true
+	}
+	return "", false
+}
+
+// Сообщения и форматы одного языка
+type Localizer struct {
+	lang     string
+	messages map[string]string
+	printer  *message.Printer
+}
+
+// Код языка (en, ru)
+func (l *Localizer) Lang() string {
+	return l.lang
+}
+
+// Есть ли сообщение в каталоге языка или языка по умолчанию
+func (l *Localizer) Has(key string) bool {
+	_, ok := l.lookup(key)
+	return ok
+}
+
+// Сообщение по ключу с подстановкой аргументов; числа форматируются по правилам языка.
+// Отсутствующее сообщение берется из языка по умолчанию, иначе возвращается сам ключ
+func (l *Localizer) T(key string, args ...any) string {
+	msg, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return l.printer.Sprintf(msg, args...)
+}
+
+func (l *Localizer) lookup(key string) (string, bool) {
+	if msg, ok := l.messages[key]; ok {
+		return msg, true
+	}
+	msg, ok := localizers[defaultLang].messages[key]
+	return msg, ok
+}
+
+// Сумма в целых единицах валюты с разделителями разрядов: "1 817 USD", "USD 1,817"
+func (l *Localizer) Money(amount int, currency string) string {
+	return l.T("format.money", l.printer.Sprintf("%d", amount), currency)
+}
+
+// Дата и время в UTC в формате языка
+func (l *Localizer) DateTime(t time.Time) string {
+	return t.UTC().Format(l.T("format.datetime"))
+}
@@ -0,0 +1,65 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+)
+
+// Во всех каталогах одинаковый набор ключей
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	base := localizers[English].messages
+	for lang, l := range localizers {
+		for key := range base {
+			if _, ok := l.messages[key]; !ok {
+				t.Errorf("%s: missing key %s", lang, key)
+			}
+		}
+		for key := range l.messages {
+			if _, ok := base[key]; !ok {
+				t.Errorf("%s: extra key %s", lang, key)
+			}
+		}
+	}
+}
+
+// Параметр запроса важнее Accept-Language, locale заказа используется последним
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		explicit, accept string
+		fallback         []string
+		want             string
+	}{
+		{"en", "ru-RU,ru;q=0.9", nil, English},
+		{"", "en-US,en;q=0.9,ru;q=0.8", nil, English},
+		{"", "ru-RU,ru;q=0.9,en;q=0.8", nil, Russian},
+		{"", "de-DE", []string{"en"}, English},
+		{"xx", "", []string{"en_US"}, English},
+		{"", "", nil, Russian},
+	}
+	for _, c := range cases {
+		if got := Negotiate(c.explicit, c.accept, c.fallback...).Lang(); got != c.want {
+			t.Errorf("Negotiate(%q, %q, %v) = %s, want %s", c.explicit, c.accept, c.fallback, got, c.want)
+		}
+	}
+}
+
+// Суммы и даты форматируются по правилам языка
+func TestFormat(t *testing.T) {
+	ts := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	if got := For(English).Money(1817, "USD"); got != "USD 1,817" {
+		t.Errorf("en money: %q", got)
+	}
+	if got := For(Russian).Money(1817, "USD"); got != "1\u00a0817 USD" {
+		t.Errorf("ru money: %q", got)
+	}
+	if got := For(English).DateTime(ts); got != "Nov 26, 2021 6:22 AM UTC" {
+		t.Errorf("en date: %q", got)
+	}
+	if got := For(Russian).DateTime(ts); got != "26.11.2021 06:22 UTC" {
+		t.Errorf("ru date: %q", got)
+	}
+	if got := For(Russian).T("validation.item_invalid", 2); got != "Некорректный товар 2." {
+		t.Errorf("ru message: %q", got)
+	}
+}
@@ -3,18 +3,33 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"strings"
+	"log/slog"
+	"time"
 
 	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/metrics"
+	"github.com/Tommych123/L0-WB/internal/redact"
 	"github.com/Tommych123/L0-WB/internal/service"
+	"github.com/Tommych123/L0-WB/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Пауза перед повтором чтения после ошибки; удваивается до maxFetchBackoff
+const (
+	minFetchBackoff = 100 * time.Millisecond
+	maxFetchBackoff = 10 * time.Second
 )
 
 // Consumer читает сообщения из Kafka
 type Consumer struct {
 	reader       *kafka.Reader
 	orderService *service.OrderService
+	status       consumerStatus
 }
 
 // Создание нового Consumer
@@ -26,75 +41,128 @@ func NewConsumer(broker, topic, groupID string, orderService *service.OrderServi
 			Topic:   topic,
 		}),
 		orderService: orderService,
+		status:       consumerStatus{lag: make(map[int]int64)},
 	}
 }
 
-// Проверка корректности заказа
-func isValidOrder(order *domain.Order) bool {
-	if order.OrderUID == "" {
-		return false
-	}
-	if order.Payment.Amount < 0 || order.Payment.Currency == "" {
-		return false
-	}
-	if order.Delivery.Email == "" || !strings.Contains(order.Delivery.Email, "@") {
-		return false
-	}
-	if len(order.Items) == 0 {
-		return false
-	}
-
-	for _, item := range order.Items {
-		if item.Name == "" || item.Price < 0 || item.TotalPrice < 0 {
-			return false
-		}
-	}
-
-	return true
-}
-
 // Чтение сообщений из Kafka
 func (c *Consumer) Run(ctx context.Context) error {
+	c.status.setRunning(true)
+	defer c.status.setRunning(false)
+
+	backoff := minFetchBackoff
 	for {
 		// Получаем сообщение
 		m, err := c.reader.FetchMessage(ctx)
 		if err != nil {
-			log.Printf("error fetching message: %v", err)
-			continue
-		}
-
-		// Парсим JSON
-		var order domain.Order
-		err = json.Unmarshal(m.Value, &order)
-		if err != nil {
-			log.Printf("invalid message format: %v", err)
-			if commitErr := c.reader.CommitMessages(ctx, m); commitErr != nil {
-				log.Printf("error committing invalid message: %v", commitErr)
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-			continue
-		}
-
-		// Валидация заказа
-		if !isValidOrder(&order) {
-			log.Printf("invalid order data: %v", order.OrderUID)
-			if commitErr := c.reader.CommitMessages(ctx, m); commitErr != nil {
-				log.Printf("error committing invalid order: %v", commitErr)
+			c.status.failed(err)
+			slog.ErrorContext(ctx, "error fetching message", logger.KeyError, err, "retry_in", backoff)
+			// Брокер недоступен: не повторяем чтение в холостом цикле
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
 			}
+			backoff = min(2*backoff, maxFetchBackoff)
 			continue
 		}
+		backoff = minFetchBackoff
 
-		// Сохраняем заказ через сервис (БД + кэш)
-		err = c.orderService.SaveOrder(&order)
-		if err != nil {
-			log.Printf("error saving order: %v", err)
-			continue
-		}
+		start := time.Now()
+		metrics.KafkaMessage(m.Topic, m.Partition, metrics.MessageConsumed)
+		lag := m.HighWaterMark - m.Offset - 1
+		metrics.KafkaLag(m.Topic, m.Partition, lag)
+		c.status.fetched(m.Partition, lag)
 
-		// Подтверждаем успешную обработку
-		if commitErr := c.reader.CommitMessages(ctx, m); commitErr != nil {
-			log.Printf("error committing message: %v", commitErr)
-		}
+		result := c.process(ctx, m)
+
+		metrics.KafkaMessage(m.Topic, m.Partition, result)
+		metrics.KafkaProcessing(m.Topic, time.Since(start))
+	}
+}
+
+// Обработка одного сообщения; возвращает результат для метрик.
+// Трасса продолжается из контекста, переданного продюсером в заголовках
+func (c *Consumer) process(ctx context.Context, m kafka.Message) (result string) {
+	msgCtx := logger.With(ctx, logger.KeyTopic, m.Topic, logger.KeyPartition, m.Partition, logger.KeyOffset, m.Offset)
+	msgCtx = otel.GetTextMapPropagator().Extract(msgCtx, headerCarrier{headers: &m.Headers})
+	msgCtx, span := tracing.Tracer().Start(msgCtx, m.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", m.Topic),
+			attribute.Int("messaging.destination.partition.id", m.Partition),
+			attribute.Int64("messaging.kafka.offset", m.Offset),
+		))
+	defer func() {
+		span.SetAttributes(attribute.String("messaging.result", result))
+		span.End()
+	}()
+
+	// Парсим JSON
+	var order domain.Order
+	err := json.Unmarshal(m.Value, &order)
+	if err != nil {
+		slog.WarnContext(msgCtx, "invalid message format", logger.KeyError, err)
+		c.commit(msgCtx, m)
+		return metrics.MessageInvalid
+	}
+
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+	msgCtx = logger.With(msgCtx, logger.KeyOrderUID, order.OrderUID)
+
+	// Валидация заказа
+	_, validateSpan := tracing.Tracer().Start(msgCtx, "ValidateOrder")
+	err = domain.ValidateOrder(&order)
+	tracing.End(validateSpan, err)
+	if err != nil {
+		slog.WarnContext(msgCtx, "invalid order data", "order", redact.Log(&order), logger.KeyError, err)
+		c.commit(msgCtx, m)
+		return metrics.MessageInvalid
+	}
 
-		log.Printf("order saved: %s", order.OrderUID)
+	// Сохраняем заказ через сервис (БД + кэш) вместе с отметкой в журнале сообщений
+	duplicate, err := c.orderService.SaveOrderFromMessage(msgCtx, &order, messageRef(m))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(msgCtx, "error saving order", logger.KeyError, err)
+		return metrics.MessageFailed
+	}
+	if duplicate {
+		slog.InfoContext(msgCtx, "message already processed")
+		c.commit(msgCtx, m)
+		return metrics.MessageDuplicate
+	}
+
+	// Подтверждаем успешную обработку
+	c.commit(msgCtx, m)
+
+	slog.InfoContext(msgCtx, "order saved")
+	return metrics.MessageSaved
+}
+
+// Подтверждает обработку сообщения
+func (c *Consumer) commit(ctx context.Context, m kafka.Message) {
+	if err := c.reader.CommitMessages(ctx, m); err != nil {
+		slog.ErrorContext(ctx, "error committing message", logger.KeyError, err)
+	}
+}
+
+// Координаты сообщения для журнала обработанных сообщений
+func messageRef(m kafka.Message) domain.MessageRef {
+	ref := domain.MessageRef{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+	}
+	for _, h := range m.Headers {
+		if h.Key == domain.MessageIDHeader {
+			ref.MessageID = string(h.Value)
+		}
 	}
+	return ref
 }
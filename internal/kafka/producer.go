@@ -2,12 +2,20 @@ package kafka
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Отвечает за отправку сообщений в Kafka
@@ -26,27 +34,85 @@ func NewProducer(broker, topic string) *Producer {
 	}
 }
 
-// Отправляет заказ в Kafka в формате JSON
-func (p *Producer) SendOrder(ctx context.Context, order domain.Order) error {
+// Отправляет заказ в Kafka в формате JSON; контекст трассировки передается в заголовках
+func (p *Producer) SendOrder(ctx context.Context, order domain.Order) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, p.writer.Topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.writer.Topic),
+			attribute.String("order.uid", order.OrderUID),
+		))
+	defer func() { tracing.End(span, err) }()
+
 	data, err := json.Marshal(order)
 	if err != nil {
 		return err
 	}
 
+	// Уникальный id позволяет consumer отбросить дубликаты при повторной отправке
+	messageID, err := newMessageID()
+	if err != nil {
+		return err
+	}
+
 	msg := kafka.Message{
 		Key:   []byte(order.OrderUID),
 		Value: data,
 		Time:  time.Now(),
+		Headers: []kafka.Header{
+			{Key: domain.MessageIDHeader, Value: []byte(messageID)},
+		},
 	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &msg.Headers})
 
 	if err := p.writer.WriteMessages(ctx, msg); err != nil {
 		return err
 	}
 
-	log.Printf("Order sent to Kafka: %s", order.OrderUID)
+	slog.InfoContext(ctx, "order sent to Kafka", logger.KeyOrderUID, order.OrderUID, logger.KeyTopic, p.writer.Topic)
 	return nil
 }
 
+// Создает продюсера событий: сообщения с одним ключом попадают в одну партицию,
+// что сохраняет порядок событий внутри заказа
+func NewEventProducer(broker, topic string) *Producer {
+	return &Producer{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(broker),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+// Публикует события outbox одним пакетом в порядке их записи
+func (p *Producer) PublishEvents(ctx context.Context, events []domain.OutboxEvent) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(e.AggregateID),
+			Value: e.Payload,
+			Time:  e.CreatedAt,
+			Headers: []kafka.Header{
+				{Key: "event-type", Value: []byte(e.EventType)},
+				{Key: "event-id", Value: []byte(strconv.FormatInt(e.ID, 10))},
+			},
+		})
+	}
+	return p.writer.WriteMessages(ctx, msgs...)
+}
+
+// Генерирует случайный идентификатор сообщения
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // Закрывает соединение с Kafka
 func (p *Producer) Close() error {
 	return p.writer.Close()
@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Сколько ошибка чтения влияет на готовность; повторяющиеся ошибки
+// обновляют ее не реже раза в maxFetchBackoff
+const fetchErrorTTL = 30 * time.Second
+
+// Состояние consumer для проверок готовности
+type consumerStatus struct {
+	mu       sync.Mutex
+	running  bool
+	lastErr  error
+	failedAt time.Time
+	// Отставание по каждой партиции на момент последнего сообщения
+	lag map[int]int64
+}
+
+func (st *consumerStatus) setRunning(running bool) {
+	st.mu.Lock()
+	st.running = running
+	st.mu.Unlock()
+}
+
+func (st *consumerStatus) fetched(partition int, lag int64) {
+	st.mu.Lock()
+	st.lastErr = nil
+	st.lag[partition] = lag
+	st.mu.Unlock()
+}
+
+func (st *consumerStatus) failed(err error) {
+	st.mu.Lock()
+	st.lastErr = err
+	st.failedAt = time.Now()
+	st.mu.Unlock()
+}
+
+// Ошибка чтения, если она произошла не раньше fetchErrorTTL до now
+func (st *consumerStatus) recentError(now time.Time) error {
+	if st.lastErr == nil || now.Sub(st.failedAt) > fetchErrorTTL {
+		return nil
+	}
+	return st.lastErr
+}
+
+// Проверка reader: consumer запущен, недавних ошибок чтения нет и брокер доступен
+func (c *Consumer) CheckReader(ctx context.Context) error {
+	c.status.mu.Lock()
+	running, lastErr := c.status.running, c.status.recentError(time.Now())
+	c.status.mu.Unlock()
+
+	if !running {
+		return fmt.Errorf("consumer is not running")
+	}
+	if lastErr != nil {
+		return fmt.Errorf("last fetch failed: %w", lastErr)
+	}
+
+	// Пока сообщений нет, FetchMessage просто ждет, поэтому доступность брокера проверяем отдельно
+	conn, err := kafka.DialContext(ctx, "tcp", c.reader.Config().Brokers[0])
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+// Проверка отставания: максимальный lag по партициям не превышает max
+func (c *Consumer) CheckLag(max int64) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		c.status.mu.Lock()
+		defer c.status.mu.Unlock()
+
+		for partition, lag := range c.status.lag {
+			if lag > max {
+				return fmt.Errorf("partition %d lag %d exceeds %d", partition, lag, max)
+			}
+		}
+		return nil
+	}
+}
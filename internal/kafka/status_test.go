@@ -0,0 +1,25 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Ошибка чтения перестает влиять на готовность через fetchErrorTTL или после успешного чтения
+func TestConsumerStatus_RecentError(t *testing.T) {
+	st := consumerStatus{lag: make(map[int]int64)}
+	st.failed(errors.New("broker down"))
+
+	if st.recentError(time.Now()) == nil {
+		t.Fatal("fresh fetch error must be reported")
+	}
+	if err := st.recentError(time.Now().Add(fetchErrorTTL + time.Second)); err != nil {
+		t.Errorf("expired fetch error reported: %v", err)
+	}
+
+	st.fetched(0, 0)
+	if err := st.recentError(time.Now()); err != nil {
+		t.Errorf("fetch error must be cleared by a successful fetch: %v", err)
+	}
+}
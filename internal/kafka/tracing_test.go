@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Контекст трассировки переживает передачу через заголовки Kafka
+func TestHeaderCarrier_RoundTrip(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	headers := []kafka.Header{{Key: "message-id", Value: []byte("m1")}}
+	prop := propagation.TraceContext{}
+	prop.Inject(ctx, headerCarrier{headers: &headers})
+
+	if len(headers) != 2 {
+		t.Fatalf("expected traceparent header to be added, got %v", headers)
+	}
+
+	got := trace.SpanContextFromContext(prop.Extract(context.Background(), headerCarrier{headers: &headers}))
+	if got.TraceID() != traceID || got.SpanID() != spanID {
+		t.Errorf("unexpected span context: %v", got)
+	}
+}
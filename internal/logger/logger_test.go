@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// Поля из контекста попадают в JSON запись
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, "info", "json")
+
+	ctx := With(context.Background(), KeyRequestID, "req-1")
+	ctx = With(ctx, KeyOrderUID, "o1")
+	log.InfoContext(ctx, "order saved")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if rec[KeyRequestID] != "req-1" || rec[KeyOrderUID] != "o1" || rec["msg"] != "order saved" {
+		t.Errorf("unexpected record: %v", rec)
+	}
+	if RequestID(ctx) != "req-1" {
+		t.Errorf("RequestID lost: %q", RequestID(ctx))
+	}
+}
+
+// Записи ниже уровня отбрасываются
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, "warn", "text")
+
+	log.Info("skipped")
+	log.Log(context.Background(), slog.LevelWarn, "kept")
+
+	if bytes.Contains(buf.Bytes(), []byte("skipped")) || !bytes.Contains(buf.Bytes(), []byte("kept")) {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Снимок статистики кэша заказов
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// Коллектор, читающий статистику кэша в момент сбора метрик
+type cacheCollector struct {
+	stats  func() CacheStats
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+	size   *prometheus.Desc
+	ratio  *prometheus.Desc
+}
+
+// Регистрирует метрики кэша, получаемые из stats
+func RegisterCache(stats func() CacheStats) {
+	prometheus.MustRegister(&cacheCollector{
+		stats:  stats,
+		hits:   prometheus.NewDesc("orders_cache_hits_total", "Order lookups served from the cache.", nil, nil),
+		misses: prometheus.NewDesc("orders_cache_misses_total", "Order lookups that went to PostgreSQL.", nil, nil),
+		size:   prometheus.NewDesc("orders_cache_size", "Orders currently held in the cache.", nil, nil),
+		ratio:  prometheus.NewDesc("orders_cache_hit_ratio", "Share of lookups served from the cache since start.", nil, nil),
+	})
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.size
+	ch <- c.ratio
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.stats()
+	ratio := 0.0
+	if total := st.Hits + st.Misses; total > 0 {
+		ratio = float64(st.Hits) / float64(total)
+	}
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(st.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(st.Misses))
+	ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(st.Size))
+	ch <- prometheus.MustNewConstMetric(c.ratio, prometheus.GaugeValue, ratio)
+}
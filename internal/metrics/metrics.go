@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Результаты обработки сообщений Kafka
+const (
+	MessageConsumed  = "consumed"
+	MessageInvalid   = "invalid"
+	MessageSaved     = "saved"
+	MessageDuplicate = "duplicate"
+	MessageFailed    = "failed"
+)
+
+var (
+	kafkaMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_kafka_messages_total",
+		Help: "Kafka messages by processing result.",
+	}, []string{"topic", "partition", "result"})
+
+	kafkaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orders_kafka_consumer_lag",
+		Help: "Messages between the last fetched offset and the partition high watermark.",
+	}, []string{"topic", "partition"})
+
+	kafkaProcessing = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orders_kafka_processing_duration_seconds",
+		Help:    "Time from fetching a Kafka message to committing it.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orders_db_query_duration_seconds",
+		Help:    "PostgreSQL query latency by query.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"query"})
+
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_db_query_errors_total",
+		Help: "PostgreSQL query errors by query.",
+	}, []string{"query"})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_http_requests_total",
+		Help: "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_http_rate_limited_total",
+		Help: "HTTP requests rejected by the rate limiter by route class.",
+	}, []string{"class"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orders_http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Учитывает результат обработки сообщения Kafka
+func KafkaMessage(topic string, partition int, result string) {
+	kafkaMessages.WithLabelValues(topic, strconv.Itoa(partition), result).Inc()
+}
+
+// Обновляет отставание consumer по партиции
+func KafkaLag(topic string, partition int, lag int64) {
+	kafkaLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(lag))
+}
+
+// Учитывает время обработки сообщения Kafka
+func KafkaProcessing(topic string, d time.Duration) {
+	kafkaProcessing.WithLabelValues(topic).Observe(d.Seconds())
+}
+
+// Учитывает время и ошибку запроса к БД; отсутствие строк ошибкой не считается
+func DBQuery(query string, d time.Duration, err error) {
+	dbQueryDuration.WithLabelValues(query).Observe(d.Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		dbQueryErrors.WithLabelValues(query).Inc()
+	}
+}
+
+// Учитывает HTTP запрос
+func HTTPRequest(route, method string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(d.Seconds())
+}
+
+// Учитывает запрос, отклоненный ограничителем частоты
+func HTTPRateLimited(class string) {
+	httpRateLimited.WithLabelValues(class).Inc()
+}
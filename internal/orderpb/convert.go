@@ -0,0 +1,63 @@
+package orderpb
+
+import (
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Преобразует доменный заказ в сообщение protobuf
+func FromDomain(o *domain.Order) *Order {
+	p := o.Payment
+	d := o.Delivery
+	msg := &Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &Delivery{
+			Name:    d.Name,
+			Phone:   d.Phone,
+			Zip:     d.Zip,
+			City:    d.City,
+			Address: d.Address,
+			Region:  d.Region,
+			Email:   d.Email,
+		},
+		Payment: &Payment{
+			Transaction:  p.Transaction,
+			RequestId:    p.RequestID,
+			Currency:     p.Currency,
+			Provider:     p.Provider,
+			Amount:       int64(p.Amount),
+			PaymentDt:    p.PaymentDt,
+			Bank:         p.Bank,
+			DeliveryCost: int64(p.DeliveryCost),
+			GoodsTotal:   int64(p.GoodsTotal),
+			CustomFee:    int64(p.CustomFee),
+		},
+		Items:             make([]*Item, 0, len(o.Items)),
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmID),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		OofShard:          o.OofShard,
+	}
+	for _, item := range o.Items {
+		msg.Items = append(msg.Items, &Item{
+			ChrtId:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmId:        int64(item.NmID),
+			Brand:       item.Brand,
+			Status:      int32(item.Status),
+		})
+	}
+	return msg
+}
@@ -0,0 +1,50 @@
+package redact
+
+import (
+	"log/slog"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Политика для логов; задается при старте вместе с политикой HTTP
+var logPolicy = DefaultPolicy()
+
+// Задает политику, применяемую к заказам в логах
+func SetLogPolicy(p *Policy) {
+	logPolicy = p
+}
+
+// Заказ для записи в лог: поля скрыты так же, как для вызывающего без роли,
+// имя получателя в лог не попадает
+type logOrder struct {
+	order *domain.Order
+}
+
+// Обертка заказа для slog: slog.Any("order", redact.Log(order))
+func Log(o *domain.Order) slog.LogValuer {
+	return logOrder{order: o}
+}
+
+func (l logOrder) LogValue() slog.Value {
+	if l.order == nil {
+		return slog.Value{}
+	}
+	o := logPolicy.Order(l.order, auth.RoleNone)
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.String("track_number", o.TrackNumber),
+		slog.String("customer_id", o.CustomerID),
+		slog.Group("delivery",
+			slog.String("phone", o.Delivery.Phone),
+			slog.String("email", o.Delivery.Email),
+			slog.String("address", o.Delivery.Address),
+		),
+		slog.Group("payment",
+			slog.String("transaction", o.Payment.Transaction),
+			slog.String("currency", o.Payment.Currency),
+			slog.Int("amount", o.Payment.Amount),
+		),
+		slog.Int("items", len(o.Items)),
+	)
+}
@@ -0,0 +1,161 @@
+package redact
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Что делать со значением поля, если роль вызывающего ниже требуемой
+type Action int
+
+const (
+	// Частично скрыть значение
+	ActionMask Action = iota
+	// Убрать значение целиком
+	ActionOmit
+)
+
+// Правило для поля заказа: вызывающий с ролью не ниже Visible видит значение целиком
+type Rule struct {
+	Field   string
+	Action  Action
+	Visible auth.Role
+}
+
+// Поле заказа, доступное для правил, и способ его маскирования
+type field struct {
+	get  func(o *domain.Order) *string
+	mask func(s string) string
+}
+
+var fields = map[string]field{
+	"delivery.name":       {func(o *domain.Order) *string { return &o.Delivery.Name }, maskText},
+	"delivery.phone":      {func(o *domain.Order) *string { return &o.Delivery.Phone }, maskPhone},
+	"delivery.zip":        {func(o *domain.Order) *string { return &o.Delivery.Zip }, maskText},
+	"delivery.city":       {func(o *domain.Order) *string { return &o.Delivery.City }, maskText},
+	"delivery.address":    {func(o *domain.Order) *string { return &o.Delivery.Address }, maskText},
+	"delivery.region":     {func(o *domain.Order) *string { return &o.Delivery.Region }, maskText},
+	"delivery.email":      {func(o *domain.Order) *string { return &o.Delivery.Email }, maskEmail},
+	"payment.transaction": {func(o *domain.Order) *string { return &o.Payment.Transaction }, maskID},
+	"payment.request_id":  {func(o *domain.Order) *string { return &o.Payment.RequestID }, maskID},
+	"customer_id":         {func(o *domain.Order) *string { return &o.CustomerID }, maskID},
+}
+
+// Правила по умолчанию: reader видит контакты частично и не видит транзакцию полностью,
+// support видит контакты целиком, admin — все поля
+const DefaultRules = "delivery.phone:mask:support,delivery.email:mask:support,delivery.address:mask:support,payment.transaction:mask:admin"
+
+// Набор правил скрытия полей заказа
+type Policy struct {
+	rules []Rule
+}
+
+// Разбор правил в формате "field:action:role" через запятую, например
+// "delivery.phone:mask:support,payment.transaction:omit:admin"
+func ParsePolicy(spec string) (*Policy, error) {
+	p := &Policy{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid redaction rule %q: expected field:action:role", entry)
+		}
+		if _, ok := fields[parts[0]]; !ok {
+			return nil, fmt.Errorf("invalid redaction rule %q: unknown field", entry)
+		}
+		var action Action
+		switch parts[1] {
+		case "mask":
+			action = ActionMask
+		case "omit":
+			action = ActionOmit
+		default:
+			return nil, fmt.Errorf("invalid redaction rule %q: action must be mask or omit", entry)
+		}
+		role, err := auth.ParseRole(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", entry, err)
+		}
+		p.rules = append(p.rules, Rule{Field: parts[0], Action: action, Visible: role})
+	}
+	return p, nil
+}
+
+// Политика с правилами по умолчанию
+func DefaultPolicy() *Policy {
+	p, err := ParsePolicy(DefaultRules)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Копия заказа, в которой скрыты поля, недоступные роли role.
+// Исходный заказ (в том числе из кэша) не изменяется
+func (p *Policy) Order(o *domain.Order, role auth.Role) *domain.Order {
+	if o == nil {
+		return nil
+	}
+
+	var out *domain.Order
+	for _, rule := range p.rules {
+		if role.Allows(rule.Visible) {
+			continue
+		}
+		if out == nil {
+			cp := *o
+			out = &cp
+		}
+		f := fields[rule.Field]
+		v := f.get(out)
+		if rule.Action == ActionOmit {
+			*v = ""
+		} else {
+			*v = f.mask(*v)
+		}
+	}
+	if out == nil {
+		return o
+	}
+	return out
+}
+
+// Маскирует произвольный текст: первый символ и "***"
+func maskText(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return ""
+	}
+	return string(r[0]) + "***"
+}
+
+// Маскирует телефон, оставляя код и две последние цифры: +97***00
+func maskPhone(s string) string {
+	if len(s) <= 6 {
+		return maskText(s)
+	}
+	return s[:3] + "***" + s[len(s)-2:]
+}
+
+// Маскирует локальную часть email: t***@gmail.com
+func maskEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return maskText(s)
+	}
+	return maskText(s[:at]) + s[at:]
+}
+
+// Маскирует идентификатор, оставляя первые четыре символа
+func maskID(s string) string {
+	if len(s) <= 4 {
+		return maskText(s)
+	}
+	return s[:4] + "***"
+}
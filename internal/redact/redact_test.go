@@ -0,0 +1,81 @@
+package redact
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Tommych123/L0-WB/internal/auth"
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+func testOrder() *domain.Order {
+	return &domain.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: domain.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Address: "Ploshad Mira 15",
+			Email:   "test@gmail.com",
+		},
+		Payment: domain.Payment{Transaction: "b563feb7b2b84b6test"},
+	}
+}
+
+// Поля скрываются в зависимости от роли, исходный заказ не меняется
+func TestPolicy_Order(t *testing.T) {
+	p := DefaultPolicy()
+	o := testOrder()
+
+	reader := p.Order(o, auth.RoleReader)
+	if reader.Delivery.Phone != "+97***00" || reader.Delivery.Email != "t***@gmail.com" ||
+		reader.Delivery.Address != "P***" || reader.Payment.Transaction != "b563***" {
+		t.Errorf("unexpected reader view: %+v %+v", reader.Delivery, reader.Payment)
+	}
+	if o.Delivery.Phone != "+9720000000" {
+		t.Fatal("original order was modified")
+	}
+
+	support := p.Order(o, auth.RoleSupport)
+	if support.Delivery.Phone != o.Delivery.Phone || support.Payment.Transaction != "b563***" {
+		t.Errorf("unexpected support view: %+v %+v", support.Delivery, support.Payment)
+	}
+
+	if admin := p.Order(o, auth.RoleAdmin); admin != o {
+		t.Error("admin should get the original order")
+	}
+}
+
+// Действие omit убирает значение целиком
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("delivery.name:omit:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Order(testOrder(), auth.RoleSupport); got.Delivery.Name != "" {
+		t.Errorf("name not omitted: %q", got.Delivery.Name)
+	}
+
+	for _, bad := range []string{"delivery.ssn:mask:admin", "delivery.name:hide:admin", "delivery.name:mask:root", "delivery.name"} {
+		if _, err := ParsePolicy(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+// В логи персональные данные попадают только в маскированном виде
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("order", "order", Log(testOrder()))
+
+	out := buf.String()
+	for _, secret := range []string{"+9720000000", "test@gmail.com", "Ploshad Mira", "Test Testov"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "t***@gmail.com") {
+		t.Errorf("masked email missing: %s", out)
+	}
+}
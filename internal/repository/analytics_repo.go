@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Агрегатные запросы тяжелее точечных чтений
+const analyticsQueryTimeout = 30 * time.Second
+
+// Интерфейс для агрегатов по сохраненным заказам
+type AnalyticsRepository interface {
+	// Заказы и выручка по периодам interval (day, week, month) и валютам
+	Revenue(ctx context.Context, filter domain.AnalyticsFilter, interval string) ([]domain.RevenuePoint, error)
+	// Заказы и выручка по значениям измерения и валютам, по убыванию выручки
+	Breakdown(ctx context.Context, filter domain.AnalyticsFilter, dimension string) ([]domain.BreakdownRow, error)
+	// Первые limit брендов или nm_id по числу позиций или выручке
+	TopItems(ctx context.Context, filter domain.AnalyticsFilter, by, sortBy string, limit int) ([]domain.TopItem, error)
+	// Средняя стоимость доставки и доля позиций со скидкой по валютам
+	Summary(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.AnalyticsSummary, error)
+}
+
+// Колонки измерений разбивки
+var breakdownColumns = map[string]string{
+	domain.DimensionDeliveryService: "o.delivery_service",
+	domain.DimensionProvider:        "p.provider",
+	domain.DimensionBank:            "p.bank",
+	domain.DimensionEntry:           "o.entry",
+}
+
+// Колонки группировки топа позиций
+var topItemColumns = map[string]string{
+	domain.TopByBrand: "i.brand",
+	domain.TopByNmID:  "i.nm_id::text",
+}
+
+// Условие по периоду и валюте для запроса по orders o JOIN payment p; удаленные заказы
+// не учитываются, аргументы идут с $1
+func analyticsWhere(filter domain.AnalyticsFilter) (string, []any) {
+	where := "WHERE o.date_created >= $1 AND o.date_created < $2 AND o.deleted_at IS NULL"
+	args := []any{filter.From, filter.To}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		where += fmt.Sprintf(" AND p.currency = $%d", len(args))
+	}
+	return where, args
+}
+
+// Заказы и выручка по периодам и валютам
+func (r *PostgresOrderRepository) Revenue(ctx context.Context, filter domain.AnalyticsFilter, interval string) ([]domain.RevenuePoint, error) {
+	switch interval {
+	case domain.IntervalDay, domain.IntervalWeek, domain.IntervalMonth:
+	default:
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := analyticsWhere(filter)
+	points := []domain.RevenuePoint{}
+	err := selectQuery(ctx, r.db, "analytics.revenue", &points, `
+        SELECT date_trunc('`+interval+`', o.date_created) AS period, p.currency,
+               count(*) AS orders, COALESCE(sum(p.amount), 0) AS revenue
+        FROM orders o
+        JOIN payment p ON p.order_uid = o.order_uid
+        `+where+`
+        GROUP BY 1, 2
+        ORDER BY 1, 2
+    `, args...)
+	return points, err
+}
+
+// Заказы и выручка по значениям измерения и валютам
+func (r *PostgresOrderRepository) Breakdown(ctx context.Context, filter domain.AnalyticsFilter, dimension string) ([]domain.BreakdownRow, error) {
+	column, ok := breakdownColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown dimension %q", dimension)
+	}
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := analyticsWhere(filter)
+	rows := []domain.BreakdownRow{}
+	err := selectQuery(ctx, r.db, "analytics.breakdown", &rows, `
+        SELECT `+column+` AS key, p.currency,
+               count(*) AS orders, COALESCE(sum(p.amount), 0) AS revenue
+        FROM orders o
+        JOIN payment p ON p.order_uid = o.order_uid
+        `+where+`
+        GROUP BY 1, 2
+        ORDER BY revenue DESC, 1, 2
+    `, args...)
+	return rows, err
+}
+
+// Первые limit брендов или nm_id по числу позиций или выручке
+func (r *PostgresOrderRepository) TopItems(ctx context.Context, filter domain.AnalyticsFilter, by, sortBy string, limit int) ([]domain.TopItem, error) {
+	column, ok := topItemColumns[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown top items grouping %q", by)
+	}
+	order := "quantity DESC, revenue DESC"
+	if sortBy == domain.TopByRevenue {
+		order = "revenue DESC, quantity DESC"
+	}
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := analyticsWhere(filter)
+	args = append(args, limit)
+	items := []domain.TopItem{}
+	err := selectQuery(ctx, r.db, "analytics.top_items", &items, `
+        SELECT `+column+` AS key, p.currency,
+               count(*) AS quantity, COALESCE(sum(i.total_price), 0) AS revenue
+        FROM orders o
+        JOIN payment p ON p.order_uid = o.order_uid
+        JOIN items i ON i.order_uid = o.order_uid
+        `+where+`
+        GROUP BY 1, 2
+        ORDER BY `+order+`, 1
+        LIMIT $`+fmt.Sprint(len(args)), args...)
+	return items, err
+}
+
+// Средняя стоимость доставки и доля позиций со скидкой по валютам
+func (r *PostgresOrderRepository) Summary(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.AnalyticsSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := analyticsWhere(filter)
+	summary := []domain.AnalyticsSummary{}
+	err := selectQuery(ctx, r.db, "analytics.summary", &summary, `
+        SELECT p.currency,
+               count(*) AS orders,
+               COALESCE(sum(p.amount), 0) AS revenue,
+               COALESCE(avg(p.delivery_cost), 0)::float8 AS avg_delivery_cost,
+               COALESCE(sum(it.items), 0) AS items,
+               COALESCE(sum(it.discounted)::float8 / NULLIF(sum(it.items), 0), 0) AS sale_rate,
+               COALESCE(sum(it.sale)::float8 / NULLIF(sum(it.items), 0), 0) AS avg_sale
+        FROM orders o
+        JOIN payment p ON p.order_uid = o.order_uid
+        LEFT JOIN LATERAL (
+            SELECT count(*) AS items,
+                   count(*) FILTER (WHERE i.sale > 0) AS discounted,
+                   COALESCE(sum(i.sale), 0) AS sale
+            FROM items i
+            WHERE i.order_uid = o.order_uid
+        ) it ON true
+        `+where+`
+        GROUP BY p.currency
+        ORDER BY p.currency
+    `, args...)
+	return summary, err
+}
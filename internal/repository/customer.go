@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/encryption"
+	"github.com/lib/pq"
+)
+
+// Значение customer_id в обезличенных заказах
+const erasedCustomerID = "erased"
+
+// Возвращает все заказы клиента со связанными сущностями, в том числе мягко удаленные:
+// до окончательного удаления они хранят данные клиента. У удаленных заказов выставлен Deleted
+func (r *PostgresOrderRepository) GetByCustomer(ctx context.Context, customerID string) ([]*domain.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var marks []struct {
+		OrderUID string `db:"order_uid"`
+		Deleted  bool   `db:"deleted"`
+	}
+	err := selectQuery(ctx, r.db, "orders.by_customer", &marks, `
+        SELECT order_uid, deleted_at IS NOT NULL AS deleted FROM orders WHERE customer_id = $1
+    `, customerID)
+	if err != nil || len(marks) == 0 {
+		return []*domain.Order{}, err
+	}
+	uids := make([]string, 0, len(marks))
+	deleted := make(map[string]bool, len(marks))
+	for _, m := range marks {
+		uids = append(uids, m.OrderUID)
+		deleted[m.OrderUID] = m.Deleted
+	}
+
+	filter := "WHERE order_uid = ANY($1)"
+	loaded, err := r.loadOrdersWhere(ctx, "by_customer", domain.AllOrderParts, filter, filter, pq.Array(uids))
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]*domain.Order, 0, len(loaded))
+	for _, order := range loaded {
+		// Заказ мог быть обезличен между запросами
+		if order.CustomerID != customerID {
+			continue
+		}
+		order.Deleted = deleted[order.OrderUID]
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.Before(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+	return orders, nil
+}
+
+// Обезличивает заказы клиента в одной транзакции: очищает контакты, адрес,
+// идентификаторы платежа и трек-номера, в том числе в payload событий outbox
+// и доставок webhook. Суммы, состав заказа, город и регион сохраняются.
+// Итоги по валютам фиксируются в erasure_audit, кэши всех экземпляров очищаются при коммите.
+// Заказы archived обезличиваются в архивах вызывающим и попадают только в запись аудита
+func (r *PostgresOrderRepository) EraseCustomer(ctx context.Context, customerID string, archived []string, requestedBy, reason string) (*domain.ErasureRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	var uids []string
+	err = selectQuery(ctx, tx, "orders.erase_lock", &uids, `
+        SELECT order_uid FROM orders WHERE customer_id = $1 ORDER BY order_uid FOR UPDATE
+    `, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) == 0 && len(archived) == 0 {
+		return nil, nil
+	}
+
+	// Итоги считаются до обезличивания и только по заказам в БД
+	totals := []domain.ErasureTotal{}
+	err = selectQuery(ctx, tx, "payment.erase_totals", &totals, `
+        SELECT currency, count(*) AS orders,
+               coalesce(sum(amount), 0) AS amount, coalesce(sum(goods_total), 0) AS goods_total,
+               coalesce(sum(delivery_cost), 0) AS delivery_cost, coalesce(sum(custom_fee), 0) AS custom_fee
+        FROM payment WHERE order_uid = ANY($1)
+        GROUP BY currency ORDER BY currency
+    `, pq.Array(uids))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := anonymizeOrders(ctx, tx, uids); err != nil {
+		return nil, err
+	}
+
+	// Заказ мог быть восстановлен из архива и одновременно остаться в другом архиве
+	all := append([]string(nil), uids...)
+	for _, uid := range archived {
+		if !slices.Contains(uids, uid) {
+			all = append(all, uid)
+		}
+	}
+	record := &domain.ErasureRecord{
+		CustomerRef: r.customerRef(customerID),
+		OrderUIDs:   all,
+		Totals:      totals,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+	}
+	totalsJSON, err := json.Marshal(totals)
+	if err != nil {
+		return nil, err
+	}
+	var inserted struct {
+		ID       int64     `db:"id"`
+		ErasedAt time.Time `db:"erased_at"`
+	}
+	err = getQuery(ctx, tx, "erasure_audit.insert", &inserted, `
+        INSERT INTO erasure_audit (customer_ref, order_uids, totals, requested_by, reason)
+        VALUES ($1,$2,$3,$4,$5)
+        RETURNING id, erased_at
+    `, record.CustomerRef, pq.Array(all), totalsJSON, requestedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+	record.ID, record.ErasedAt = inserted.ID, inserted.ErasedAt
+
+	// Другие экземпляры не должны отдавать из кэша данные до обезличивания
+	if err := notifyEvict(ctx, tx, uids); err != nil {
+		return nil, err
+	}
+	return record, classifyError(tx.Commit())
+}
+
+// Очищает персональные данные заказов во всех таблицах, включая payload событий
+func anonymizeOrders(ctx context.Context, tx queryer, uids []string) error {
+	ids := pq.Array(uids)
+	steps := []struct{ name, query string }{
+		{"orders.erase", `
+            UPDATE orders SET customer_id = '` + erasedCustomerID + `', track_number = '', internal_signature = '',
+                updated_at = now()
+            WHERE order_uid = ANY($1)`},
+		{"delivery.erase", `
+            UPDATE delivery SET name = '', phone = '', zip = '', address = '', email = '',
+                email_bidx = NULL, phone_bidx = NULL
+            WHERE order_uid = ANY($1)`},
+		{"payment.erase", `
+            UPDATE payment SET transaction = '', request_id = ''
+            WHERE order_uid = ANY($1)`},
+		{"items.erase", `
+            UPDATE items SET track_number = ''
+            WHERE order_uid = ANY($1)`},
+		{"outbox.erase", `
+            UPDATE outbox SET payload = jsonb_set(payload, '{order}', 'null')
+            WHERE aggregate_id = ANY($1) AND payload ? 'order'`},
+		{"webhook_deliveries.erase", `
+            UPDATE webhook_deliveries SET payload = jsonb_set(payload, '{order}', 'null')
+            WHERE order_uid = ANY($1) AND payload ? 'order'`},
+	}
+	for _, step := range steps {
+		if _, err := execQuery(ctx, tx, step.name, step.query, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Колонки, которые очищает anonymizeOrders, и их значения после обезличивания
+var erasedColumns = map[string]map[string]any{
+	"orders":   {"customer_id": erasedCustomerID, "track_number": "", "internal_signature": ""},
+	"delivery": {"name": "", "phone": "", "zip": "", "address": "", "email": "", "email_bidx": nil, "phone_bidx": nil},
+	"payment":  {"transaction": "", "request_id": ""},
+	"items":    {"track_number": ""},
+}
+
+// Обезличивает строку архива таблицы table так же, как anonymizeOrders строку в БД
+func AnonymizeArchiveRow(table string, row []byte) ([]byte, error) {
+	columns, ok := erasedColumns[table]
+	if !ok {
+		return row, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return nil, err
+	}
+	for column, value := range columns {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[column] = raw
+	}
+	return json.Marshal(fields)
+}
+
+// Необратимая ссылка на клиента для аудита: HMAC ключом индексов или SHA-256 без шифрования
+func (r *PostgresOrderRepository) customerRef(customerID string) string {
+	if r.keyring != nil {
+		return r.keyring.BlindIndex(encryption.KindCustomer, customerID)
+	}
+	sum := sha256.Sum256([]byte(encryption.KindCustomer + ":" + customerID))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/lib/pq"
+)
+
+// Канал NOTIFY с order_uid заказов, которые нужно убрать из кэша всех экземпляров сервиса
+const EvictChannel = "order_evict"
+
+// Помечает заказ удаленным и убирает его вклад из дневных агрегатов. Уведомление
+// об очистке кэша отправляется другим экземплярам при коммите
+func (r *PostgresOrderRepository) SoftDelete(ctx context.Context, orderUID, deletedBy, reason string) (*domain.DeletedOrder, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOrderUID(ctx, tx, orderUID); err != nil {
+		return nil, err
+	}
+	stored, err := lockStoredOrder(ctx, tx, orderUID)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	deleted := &domain.DeletedOrder{OrderUID: orderUID, DateCreated: stored.DateCreated}
+	if stored.DeletedAt != nil {
+		deleted.DeletedAt = *stored.DeletedAt
+		if stored.DeletedBy != nil {
+			deleted.DeletedBy = *stored.DeletedBy
+		}
+		if stored.DeleteReason != nil {
+			deleted.Reason = *stored.DeleteReason
+		}
+		return deleted, nil
+	}
+
+	err = getQuery(ctx, tx, "orders.soft_delete", &deleted.DeletedAt, `
+        UPDATE orders SET deleted_at = now(), deleted_by = $3, delete_reason = $4, updated_at = now()
+        WHERE order_uid = $1 AND date_created = $2
+        RETURNING deleted_at
+    `, orderUID, stored.DateCreated, deletedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+	deleted.DeletedBy, deleted.Reason = deletedBy, reason
+
+	if err := applyRollup(ctx, tx, stored.rollupDelta.negate()); err != nil {
+		return nil, err
+	}
+	if err := notifyEvict(ctx, tx, []string{orderUID}); err != nil {
+		return nil, err
+	}
+	return deleted, classifyError(tx.Commit())
+}
+
+// Отправляет в EvictChannel order_uid каждого заказа; другие экземпляры получат
+// уведомления только после коммита транзакции
+func notifyEvict(ctx context.Context, q queryer, uids []string) error {
+	_, err := execQuery(ctx, q, "orders.notify_evict", `
+        SELECT pg_notify($1, uid) FROM unnest($2::text[]) AS uid
+    `, EvictChannel, pq.Array(uids))
+	return err
+}
+
+// Удаленные заказы, последние удаленные первыми; limit вне 1..1000 заменяется на 100
+func (r *PostgresOrderRepository) ListDeleted(ctx context.Context, limit int) ([]domain.DeletedOrder, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	deleted := []domain.DeletedOrder{}
+	err := selectQuery(ctx, r.db, "orders.list_deleted", &deleted, `
+        SELECT order_uid, date_created, deleted_at,
+               COALESCE(deleted_by, '') AS deleted_by, COALESCE(delete_reason, '') AS delete_reason
+        FROM orders
+        WHERE deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC, order_uid
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Снимает пометку удаления и возвращает вклад заказа в дневные агрегаты
+func (r *PostgresOrderRepository) Restore(ctx context.Context, orderUID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOrderUID(ctx, tx, orderUID); err != nil {
+		return false, err
+	}
+	stored, err := lockStoredOrder(ctx, tx, orderUID)
+	if err != nil || stored == nil || stored.DeletedAt == nil {
+		return false, err
+	}
+
+	_, err = execQuery(ctx, tx, "orders.restore", `
+        UPDATE orders SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL, updated_at = now()
+        WHERE order_uid = $1 AND date_created = $2
+    `, orderUID, stored.DateCreated)
+	if err != nil {
+		return false, err
+	}
+	if err := applyRollup(ctx, tx, stored.rollupDelta); err != nil {
+		return false, err
+	}
+	return true, classifyError(tx.Commit())
+}
+
+// Окончательно удаляет заказы, удаленные раньше before; связанные строки удаляются
+// каскадно, rid позиций освобождаются. Заказы, которые в этот момент восстанавливаются или изменяются, пропускаются
+func (r *PostgresOrderRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	uids := []string{}
+	err := selectQuery(ctx, r.db, "orders.purge", &uids, `
+        WITH purged AS (
+            DELETE FROM orders
+            WHERE (order_uid, date_created) IN (
+                SELECT order_uid, date_created FROM orders
+                WHERE deleted_at < $1
+                ORDER BY deleted_at
+                LIMIT $2
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING order_uid
+        ), released AS (
+            DELETE FROM item_rids WHERE order_uid IN (SELECT order_uid FROM purged)
+        )
+        SELECT order_uid FROM purged
+    `, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return uids, nil
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/encryption"
+)
+
+// Интерфейс для поиска заказов по контактам получателя
+type LookupRepository interface {
+	// kind — encryption.KindEmail или encryption.KindPhone
+	FindOrderUIDs(ctx context.Context, kind, value string) ([]string, error)
+}
+
+// Максимальное число заказов в ответе поиска
+const maxLookupResults = 1000
+
+// Связывает шифротекст с колонкой и заказом
+func aad(column, orderUID string) string {
+	return column + "|" + orderUID
+}
+
+// Шифрует значение колонки, если шифрование включено
+func (r *PostgresOrderRepository) seal(column, orderUID, value string) (string, error) {
+	if r.keyring == nil {
+		return value, nil
+	}
+	enc, err := r.keyring.Encrypt(value, aad(column, orderUID))
+	if err != nil {
+		return "", fmt.Errorf("encrypt %s: %w", column, err)
+	}
+	return enc, nil
+}
+
+// Расшифровывает значение колонки; открытые значения возвращаются как есть
+func (r *PostgresOrderRepository) open(column, orderUID, value string) (string, error) {
+	if r.keyring == nil || !encryption.IsEncrypted(value) {
+		return value, nil
+	}
+	plain, err := r.keyring.Decrypt(value, aad(column, orderUID))
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s of order %s: %w", column, orderUID, err)
+	}
+	return plain, nil
+}
+
+// Колонка для AAD заказа в payload событий; общая для outbox и webhook_deliveries,
+// куда payload копируется без изменений
+const eventOrderColumn = "event.order"
+
+// Шифрует заказ в payload события: поле order заменяется строкой с шифротекстом.
+// Без связки ключей payload возвращается как есть
+func sealEvent(keyring *encryption.Keyring, orderUID string, payload []byte) ([]byte, error) {
+	if keyring == nil {
+		return payload, nil
+	}
+	return mapEventOrder(payload, func(order json.RawMessage) (json.RawMessage, error) {
+		if isJSONString(order) {
+			return order, nil
+		}
+		enc, err := keyring.Encrypt(string(order), aad(eventOrderColumn, orderUID))
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %s: %w", eventOrderColumn, err)
+		}
+		return json.Marshal(enc)
+	})
+}
+
+// Расшифровывает заказ в payload события; открытый payload возвращается как есть
+func openEvent(keyring *encryption.Keyring, orderUID string, payload []byte) ([]byte, error) {
+	if keyring == nil {
+		return payload, nil
+	}
+	return mapEventOrder(payload, func(order json.RawMessage) (json.RawMessage, error) {
+		var value string
+		if !isJSONString(order) || json.Unmarshal(order, &value) != nil || !encryption.IsEncrypted(value) {
+			return order, nil
+		}
+		plain, err := keyring.Decrypt(value, aad(eventOrderColumn, orderUID))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s of order %s: %w", eventOrderColumn, orderUID, err)
+		}
+		return json.RawMessage(plain), nil
+	})
+}
+
+// Шифрует открытый заказ в payload события или переводит шифротекст на активный ключ
+func rewrapEvent(keyring *encryption.Keyring, orderUID string, payload []byte) ([]byte, error) {
+	return mapEventOrder(payload, func(order json.RawMessage) (json.RawMessage, error) {
+		var value string
+		if !isJSONString(order) || json.Unmarshal(order, &value) != nil {
+			value = string(order)
+		}
+		enc, err := keyring.Rewrap(value, aad(eventOrderColumn, orderUID))
+		if err != nil {
+			return nil, fmt.Errorf("rewrap %s of order %s: %w", eventOrderColumn, orderUID, err)
+		}
+		return json.Marshal(enc)
+	})
+}
+
+// Заменяет поле order в payload события результатом fn. Payload без заказа
+// (в том числе после удаления данных клиента) не меняется
+func mapEventOrder(payload []byte, fn func(order json.RawMessage) (json.RawMessage, error)) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, fmt.Errorf("event payload: %w", err)
+	}
+	order, ok := fields["order"]
+	if !ok || string(order) == "null" {
+		return payload, nil
+	}
+	order, err := fn(order)
+	if err != nil {
+		return nil, err
+	}
+	fields["order"] = order
+	return json.Marshal(fields)
+}
+
+func isJSONString(value json.RawMessage) bool {
+	return len(value) > 0 && value[0] == '"'
+}
+
+// Копия delivery с зашифрованными персональными данными
+func (r *PostgresOrderRepository) sealDelivery(orderUID string, d domain.Delivery) (domain.Delivery, error) {
+	var err error
+	for _, f := range piiColumns(&d) {
+		if *f.value, err = r.seal(f.column, orderUID, *f.value); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// Расшифровывает персональные данные delivery на месте
+func (r *PostgresOrderRepository) openDelivery(orderUID string, d *domain.Delivery) error {
+	var err error
+	for _, f := range piiColumns(d) {
+		if *f.value, err = r.open(f.column, orderUID, *f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Шифруемые колонки delivery с персональными данными
+func piiColumns(d *domain.Delivery) []struct {
+	column string
+	value  *string
+} {
+	return []struct {
+		column string
+		value  *string
+	}{
+		{"delivery.name", &d.Name},
+		{"delivery.phone", &d.Phone},
+		{"delivery.address", &d.Address},
+		{"delivery.email", &d.Email},
+	}
+}
+
+// Слепые индексы email и телефона; NULL, если шифрование выключено или значение пустое
+func (r *PostgresOrderRepository) blindIndexes(d domain.Delivery) (email, phone sql.NullString) {
+	if r.keyring == nil {
+		return
+	}
+	return nullIfEmpty(r.keyring.BlindIndex(encryption.KindEmail, d.Email)),
+		nullIfEmpty(r.keyring.BlindIndex(encryption.KindPhone, d.Phone))
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Поиск заказов по email или телефону. Зашифрованные строки ищутся по слепому
+// индексу, еще не перешифрованные — по открытому значению
+func (r *PostgresOrderRepository) FindOrderUIDs(ctx context.Context, kind, value string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	normalized := encryption.Normalize(kind, value)
+	if normalized == "" {
+		return []string{}, nil
+	}
+
+	var plainCond, column string
+	switch kind {
+	case encryption.KindEmail:
+		column, plainCond = "email_bidx", "lower(btrim(email)) = $2"
+	case encryption.KindPhone:
+		column, plainCond = "phone_bidx", "regexp_replace(phone, '\\D', '', 'g') = $2"
+	default:
+		return nil, fmt.Errorf("unknown lookup kind %q", kind)
+	}
+	var index sql.NullString
+	if r.keyring != nil {
+		index = nullIfEmpty(r.keyring.BlindIndex(kind, value))
+	}
+
+	uids := []string{}
+	err := selectQuery(ctx, r.db, "delivery.lookup_"+kind, &uids, `
+        SELECT order_uid FROM delivery
+        WHERE (`+column+` = $1 OR (`+column+` IS NULL AND `+plainCond+`))
+          AND NOT EXISTS (
+              SELECT 1 FROM orders o WHERE o.order_uid = delivery.order_uid AND o.deleted_at IS NOT NULL
+          )
+        ORDER BY order_uid
+        LIMIT $3
+    `, index, normalized, maxLookupResults)
+	if err != nil {
+		return nil, err
+	}
+	return uids, nil
+}
+
+// Шифрует строки, записанные открытым текстом, и переводит значения со старых ключей
+// на активный. Работает пачками по batchSize, пока такие строки есть; возвращает число
+// обновленных строк. Заказы остаются доступными на чтение во время миграции
+func (r *PostgresOrderRepository) EncryptExisting(ctx context.Context, batchSize int) (int, error) {
+	if r.keyring == nil {
+		return 0, nil
+	}
+	total := 0
+	for {
+		n, err := r.rewrapDeliveryBatch(ctx, batchSize)
+		if err != nil {
+			return total, err
+		}
+		m, err := r.rewrapPaymentBatch(ctx, batchSize)
+		if err != nil {
+			return total, err
+		}
+		o, err := r.rewrapEventBatch(ctx, "outbox", "aggregate_id", batchSize)
+		if err != nil {
+			return total, err
+		}
+		w, err := r.rewrapEventBatch(ctx, "webhook_deliveries", "order_uid", batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += n + m + o + w
+		if n == 0 && m == 0 && o == 0 && w == 0 {
+			return total, nil
+		}
+	}
+}
+
+// Перешифровывает одну пачку строк delivery
+func (r *PostgresOrderRepository) rewrapDeliveryBatch(ctx context.Context, batchSize int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		OrderUID string `db:"order_uid"`
+		domain.Delivery
+	}
+	// Строка требует обработки, если хотя бы одно непустое значение не начинается с префикса активного ключа
+	err = selectQuery(ctx, tx, "delivery.rewrap_select", &rows, `
+        SELECT order_uid, name, phone, zip, city, address, region, email
+        FROM delivery
+        WHERE (name <> '' AND left(name, length($1)) <> $1)
+           OR (phone <> '' AND left(phone, length($1)) <> $1)
+           OR (address <> '' AND left(address, length($1)) <> $1)
+           OR (email <> '' AND left(email, length($1)) <> $1)
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `, r.keyring.ActivePrefix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		// Индексы считаются только по открытым значениям; у зашифрованных они уже есть
+		var emailIdx, phoneIdx sql.NullString
+		if !encryption.IsEncrypted(row.Email) {
+			emailIdx = nullIfEmpty(r.keyring.BlindIndex(encryption.KindEmail, row.Email))
+		}
+		if !encryption.IsEncrypted(row.Phone) {
+			phoneIdx = nullIfEmpty(r.keyring.BlindIndex(encryption.KindPhone, row.Phone))
+		}
+
+		d := row.Delivery
+		for _, f := range piiColumns(&d) {
+			if *f.value, err = r.keyring.Rewrap(*f.value, aad(f.column, row.OrderUID)); err != nil {
+				return 0, fmt.Errorf("rewrap %s of order %s: %w", f.column, row.OrderUID, err)
+			}
+		}
+
+		_, err = execQuery(ctx, tx, "delivery.rewrap_update", `
+            UPDATE delivery SET name = $2, phone = $3, address = $4, email = $5,
+                email_bidx = COALESCE($6, email_bidx), phone_bidx = COALESCE($7, phone_bidx)
+            WHERE order_uid = $1
+        `, row.OrderUID, d.Name, d.Phone, d.Address, d.Email, emailIdx, phoneIdx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), classifyError(tx.Commit())
+}
+
+// Перешифровывает одну пачку строк payment
+func (r *PostgresOrderRepository) rewrapPaymentBatch(ctx context.Context, batchSize int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		OrderUID    string `db:"order_uid"`
+		Transaction string `db:"transaction"`
+	}
+	err = selectQuery(ctx, tx, "payment.rewrap_select", &rows, `
+        SELECT order_uid, transaction
+        FROM payment
+        WHERE transaction <> '' AND left(transaction, length($1)) <> $1
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `, r.keyring.ActivePrefix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		transaction, err := r.keyring.Rewrap(row.Transaction, aad("payment.transaction", row.OrderUID))
+		if err != nil {
+			return 0, fmt.Errorf("rewrap payment.transaction of order %s: %w", row.OrderUID, err)
+		}
+		_, err = execQuery(ctx, tx, "payment.rewrap_update", `
+            UPDATE payment SET transaction = $2 WHERE order_uid = $1
+        `, row.OrderUID, transaction)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), classifyError(tx.Commit())
+}
+
+// Перешифровывает заказ в payload одной пачки событий таблицы outbox или webhook_deliveries;
+// uidColumn — колонка с order_uid события
+func (r *PostgresOrderRepository) rewrapEventBatch(ctx context.Context, table, uidColumn string, batchSize int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		ID       int64  `db:"id"`
+		OrderUID string `db:"order_uid"`
+		Payload  []byte `db:"payload"`
+	}
+	// Заказ записан объектом (открытым текстом) или зашифрован не активным ключом
+	err = selectQuery(ctx, tx, table+".rewrap_select", &rows, `
+        SELECT id, `+uidColumn+` AS order_uid, payload
+        FROM `+table+`
+        WHERE jsonb_typeof(payload->'order') = 'object'
+           OR (jsonb_typeof(payload->'order') = 'string' AND left(payload->>'order', length($1)) <> $1)
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `, r.keyring.ActivePrefix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		payload, err := rewrapEvent(r.keyring, row.OrderUID, row.Payload)
+		if err != nil {
+			return 0, err
+		}
+		_, err = execQuery(ctx, tx, table+".rewrap_update", `
+            UPDATE `+table+` SET payload = $2 WHERE id = $1
+        `, row.ID, payload)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), classifyError(tx.Commit())
+}
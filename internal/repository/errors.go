@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// Категории ошибок хранилища, по которым сервис выбирает ответ клиенту
+var (
+	// Запись конфликтует с параллельной или уже существующей
+	ErrConflict = errors.New("storage conflict")
+	// БД недоступна или не ответила вовремя
+	ErrUnavailable = errors.New("storage unavailable")
+	// Позиция с таким rid уже принадлежит другому заказу
+	ErrItemRIDTaken = fmt.Errorf("%w: item rid belongs to another order", ErrConflict)
+)
+
+// Помечает ошибку драйвера категорией; остальные ошибки возвращаются без изменений
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		// unique_violation и ошибки сериализации / взаимоблокировки
+		case pqErr.Code == "23505", pqErr.Code.Class() == "40":
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		// Ошибки соединения, нехватка ресурсов, остановка сервера
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53", pqErr.Code.Class() == "57":
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/lib/pq"
+)
+
+// Число заказов, читаемых из курсора выгрузки за один FETCH
+const exportFetchSize = 500
+
+// Передает в fn заказы со всеми связанными сущностями, созданные в [from, to),
+// в порядке date_created. Заказы читаются серверным курсором пачками по
+// exportFetchSize, поэтому память не зависит от размера периода. Ошибка fn
+// прерывает выгрузку и возвращается как есть
+func (r *PostgresOrderRepository) StreamOrders(ctx context.Context, from, to time.Time, fn func(*domain.Order) error) error {
+	// Курсор живет внутри транзакции; снимок REPEATABLE READ дает согласованные пачки
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	_, err = execQuery(ctx, tx, "orders.export_declare", `
+        DECLARE orders_export NO SCROLL CURSOR FOR
+        SELECT order_uid, track_number, entry, locale, internal_signature,
+               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at
+        FROM orders
+        WHERE date_created >= $1 AND date_created < $2 AND deleted_at IS NULL
+        ORDER BY date_created, order_uid
+    `, from, to)
+	if err != nil {
+		return err
+	}
+
+	fetch := "FETCH " + strconv.Itoa(exportFetchSize) + " FROM orders_export"
+	for {
+		var orders []domain.Order
+		if err := selectQuery(ctx, tx, "orders.export_fetch", &orders, fetch); err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			break
+		}
+
+		orderMap := make(map[string]*domain.Order, len(orders))
+		uids := make([]string, 0, len(orders))
+		for i := range orders {
+			orderMap[orders[i].OrderUID] = &orders[i]
+			uids = append(uids, orders[i].OrderUID)
+		}
+		filter, arg := "WHERE order_uid = ANY($1)", pq.Array(uids)
+		if err := r.loadDeliveries(ctx, tx, "export", orderMap, filter, arg); err != nil {
+			return err
+		}
+		if err := r.loadPayments(ctx, tx, "export", orderMap, filter, arg); err != nil {
+			return err
+		}
+		if err := r.loadItems(ctx, tx, "export", orderMap, filter, arg); err != nil {
+			return err
+		}
+
+		for i := range orders {
+			if err := fn(&orders[i]); err != nil {
+				return err
+			}
+		}
+		if len(orders) < exportFetchSize {
+			break
+		}
+	}
+	return classifyError(tx.Commit())
+}
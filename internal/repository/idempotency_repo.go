@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Через сколько незавершенный резерв ключа считается брошенным (процесс упал
+// во время обработки) и ключ можно занять заново
+const idempotencyPendingTimeout = 5 * time.Minute
+
+// Сохраненный ответ на запрос с ключом идемпотентности
+type IdempotencyRecord struct {
+	// Вызывающий, которому принадлежит ключ
+	Owner       string `db:"owner"`
+	Key         string `db:"key"`
+	RequestHash string `db:"request_hash"`
+	// 0 — запрос с этим ключом еще выполняется
+	StatusCode int       `db:"status_code"`
+	Response   []byte    `db:"response"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Интерфейс для хранения ключей идемпотентности. Ключи разных вызывающих не пересекаются
+type IdempotencyRepository interface {
+	// Резервирует ключ за запросом. nil — ключ занят этим запросом; иначе возвращается
+	// существующая запись: сохраненный ответ или резерв выполняющегося запроса
+	ReserveIdempotencyKey(ctx context.Context, owner, key, requestHash string) (*IdempotencyRecord, error)
+	// Сохраняет ответ в зарезервированный ключ
+	CompleteIdempotencyKey(ctx context.Context, owner, key string, statusCode int, response []byte) error
+	// Снимает резерв, чтобы запрос можно было повторить
+	ReleaseIdempotencyKey(ctx context.Context, owner, key string) error
+	// Удаляет ключи, созданные раньше before; возвращает их число
+	DeleteIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Хранилище ключей идемпотентности в PostgreSQL
+type PostgresIdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+// Создание нового хранилища ключей идемпотентности
+func NewPostgresIdempotencyRepository(db *sqlx.DB) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{db: db}
+}
+
+// Вставляет резерв ключа одним запросом, поэтому из параллельных запросов с одним
+// ключом ключ занимает только один. Брошенный резерв занимается заново
+func (r *PostgresIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, owner, key, requestHash string) (*IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var reserved bool
+	err := getQuery(ctx, r.db, "idempotency.reserve", &reserved, `
+        INSERT INTO idempotency_keys AS k (owner, key, request_hash)
+        VALUES ($1,$2,$3)
+        ON CONFLICT (owner, key) DO UPDATE SET request_hash = EXCLUDED.request_hash, created_at = now()
+        WHERE k.status_code IS NULL AND k.created_at < now() - make_interval(secs => $4)
+        RETURNING true
+    `, owner, key, requestHash, idempotencyPendingTimeout.Seconds())
+	if err == nil && reserved {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var rec IdempotencyRecord
+	err = getQuery(ctx, r.db, "idempotency.get", &rec, `
+        SELECT owner, key, request_hash, COALESCE(status_code, 0) AS status_code, response, created_at
+        FROM idempotency_keys WHERE owner = $1 AND key = $2
+    `, owner, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Резерв сняли между запросами; клиент повторит запрос
+		return nil, fmt.Errorf("%w: idempotency key was released concurrently", ErrConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Сохраняет ответ; первый сохраненный ответ побеждает
+func (r *PostgresIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, owner, key string, statusCode int, response []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := execQuery(ctx, r.db, "idempotency.complete", `
+        UPDATE idempotency_keys SET status_code = $3, response = $4
+        WHERE owner = $1 AND key = $2 AND status_code IS NULL
+    `, owner, key, statusCode, response)
+	return err
+}
+
+// Удаляет незавершенный резерв ключа
+func (r *PostgresIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, owner, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := execQuery(ctx, r.db, "idempotency.release", `
+        DELETE FROM idempotency_keys WHERE owner = $1 AND key = $2 AND status_code IS NULL
+    `, owner, key)
+	return err
+}
+
+// Удаляет устаревшие ключи
+func (r *PostgresIdempotencyRepository) DeleteIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	res, err := execQuery(ctx, r.db, "idempotency.cleanup", `
+        DELETE FROM idempotency_keys WHERE created_at < $1
+    `, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
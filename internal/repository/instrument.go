@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/metrics"
+	"github.com/Tommych123/L0-WB/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Выполнитель запросов: *sqlx.DB или *sqlx.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// Открывает спан запроса к БД
+func startQuery(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "db "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", query),
+		))
+}
+
+// Закрывает спан и учитывает запрос в метриках и debug-логе; отсутствие строк ошибкой не считается
+func endQuery(ctx context.Context, span trace.Span, name string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	metrics.DBQuery(name, elapsed, err)
+	args := []any{"query", name, "duration_ms", elapsed.Milliseconds()}
+	if err != nil {
+		args = append(args, logger.KeyError, err)
+	}
+	slog.DebugContext(ctx, "db query", args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// Выполняет запрос без результата с учетом метрик и трассировки под именем name.
+// Ошибки драйвера помечаются категориями ErrConflict / ErrUnavailable
+func execQuery(ctx context.Context, q queryer, name, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, name, query)
+	start := time.Now()
+	res, err := q.ExecContext(ctx, query, args...)
+	endQuery(ctx, span, name, start, err)
+	return res, classifyError(err)
+}
+
+// Выбирает одну строку с учетом метрик и трассировки под именем name
+func getQuery(ctx context.Context, q queryer, name string, dest any, query string, args ...any) error {
+	ctx, span := startQuery(ctx, name, query)
+	start := time.Now()
+	err := q.GetContext(ctx, dest, query, args...)
+	endQuery(ctx, span, name, start, err)
+	return classifyError(err)
+}
+
+// Выбирает набор строк с учетом метрик и трассировки под именем name
+func selectQuery(ctx context.Context, q queryer, name string, dest any, query string, args ...any) error {
+	ctx, span := startQuery(ctx, name, query)
+	start := time.Now()
+	err := q.SelectContext(ctx, dest, query, args...)
+	endQuery(ctx, span, name, start, err)
+	return classifyError(err)
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/lib/pq"
+)
+
+// Таблица item_rids в памяти: закрепление rid за заказом и освобождение лишних rid
+type memItemRIDs struct {
+	owners map[string]string
+}
+
+func (m *memItemRIDs) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	if !strings.Contains(query, "DELETE FROM item_rids") {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	orderUID := args[0].(string)
+	keep := make(map[string]bool)
+	for _, rid := range *args[1].(*pq.StringArray) {
+		keep[rid] = true
+	}
+	var n int64
+	for rid, owner := range m.owners {
+		if owner == orderUID && !keep[rid] {
+			delete(m.owners, rid)
+			n++
+		}
+	}
+	return driverResult(n), nil
+}
+
+func (m *memItemRIDs) GetContext(_ context.Context, dest any, query string, args ...any) error {
+	if !strings.Contains(query, "INSERT INTO item_rids") {
+		return errors.New("unexpected query: " + query)
+	}
+	rid, orderUID := args[0].(string), args[1].(string)
+	if owner, ok := m.owners[rid]; ok && owner != orderUID {
+		return sql.ErrNoRows
+	}
+	m.owners[rid] = orderUID
+	*dest.(*bool) = true
+	return nil
+}
+
+func (m *memItemRIDs) SelectContext(context.Context, any, string, ...any) error {
+	return errors.New("not implemented")
+}
+
+// rid занят заказом другого месяца: ключ items (rid, date_created) этого не видит,
+// поэтому владелец проверяется по item_rids
+func TestClaimItemRIDs_AcrossPartitions(t *testing.T) {
+	ctx := context.Background()
+	db := &memItemRIDs{owners: make(map[string]string)}
+
+	january := &domain.Order{
+		OrderUID:    "o1",
+		DateCreated: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+		Items:       []domain.Item{{RID: "r1"}, {RID: "r2"}},
+	}
+	march := &domain.Order{
+		OrderUID:    "o2",
+		DateCreated: time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC),
+		Items:       []domain.Item{{RID: "r3"}, {RID: "r1"}},
+	}
+
+	if err := claimItemRIDs(ctx, db, january); err != nil {
+		t.Fatal(err)
+	}
+	err := claimItemRIDs(ctx, db, march)
+	if !errors.Is(err, ErrItemRIDTaken) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrItemRIDTaken, got %v", err)
+	}
+	if db.owners["r1"] != "o1" {
+		t.Errorf("rid must stay with the first order: %v", db.owners)
+	}
+
+	// Повторное сохранение своего заказа не конфликтует
+	if err := claimItemRIDs(ctx, db, january); err != nil {
+		t.Errorf("resaving own order: %v", err)
+	}
+
+	// rid, убранный из первого заказа, освобождается для другого
+	january.Items = january.Items[1:]
+	if err := claimItemRIDs(ctx, db, january); err != nil {
+		t.Fatal(err)
+	}
+	if err := claimItemRIDs(ctx, db, march); err != nil {
+		t.Fatalf("released rid must be claimable: %v", err)
+	}
+	want := map[string]string{"r1": "o2", "r2": "o1", "r3": "o2"}
+	for rid, owner := range want {
+		if db.owners[rid] != owner {
+			t.Errorf("rid %s: owner %q, want %q", rid, db.owners[rid], owner)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Интерфейс для чтения журнала обработанных сообщений
+type LedgerRepository interface {
+	ListProcessedMessages(ctx context.Context, filter domain.ProcessedMessageFilter) ([]domain.ProcessedMessage, error)
+}
+
+// Возвращает записи журнала обработанных сообщений по фильтру, новые первыми
+func (r *PostgresOrderRepository) ListProcessedMessages(ctx context.Context, filter domain.ProcessedMessageFilter) ([]domain.ProcessedMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		conds []string
+		args  []any
+	)
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Topic != "" {
+		addCond("topic = $%d", filter.Topic)
+	}
+	if filter.Partition != nil {
+		addCond("partition = $%d", *filter.Partition)
+	}
+	if filter.OrderUID != "" {
+		addCond("order_uid = $%d", filter.OrderUID)
+	}
+	if filter.MessageID != "" {
+		addCond("message_id = $%d", filter.MessageID)
+	}
+
+	query := `
+        SELECT topic, partition, "offset", COALESCE(message_id, '') AS message_id,
+               order_uid, processed_at
+        FROM processed_messages`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY processed_at DESC LIMIT $%d", len(args))
+
+	messages := []domain.ProcessedMessage{}
+	if err := selectQuery(ctx, r.db, "ledger.list", &messages, query, args...); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/lib/pq"
+)
+
+// Как часто проверяется соединение подписки, если уведомлений нет
+const notifyPingInterval = 90 * time.Second
+
+// Канал NOTIFY с order_uid заказов, сохраненных любым экземпляром сервиса
+const OrderSavedChannel = "order_saved"
+
+// Подписка на уведомления канала NOTIFY через LISTEN на отдельном соединении
+type NotifyListener struct {
+	dsn     string
+	channel string
+}
+
+// Создание подписки на channel; dsn — строка подключения к той же БД
+func NewNotifyListener(dsn, channel string) *NotifyListener {
+	return &NotifyListener{dsn: dsn, channel: channel}
+}
+
+// Подписка на уведомления об очистке кэша
+func NewEvictionListener(dsn string) *NotifyListener {
+	return NewNotifyListener(dsn, EvictChannel)
+}
+
+// Вызывает handle для payload каждого уведомления до отмены контекста. После
+// переподключения вызывается reset: уведомления, отправленные без соединения, потеряны
+func (l *NotifyListener) Run(ctx context.Context, handle func(payload string), reset func()) error {
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			slog.Warn("notification listener disconnected", "channel", l.channel, logger.KeyError, err)
+		case pq.ListenerEventConnectionAttemptFailed:
+			slog.Warn("notification listener reconnect failed", "channel", l.channel, logger.KeyError, err)
+		case pq.ListenerEventReconnected:
+			slog.Info("notification listener reconnected", "channel", l.channel)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(l.channel); err != nil {
+		return classifyError(err)
+	}
+
+	ping := time.NewTicker(notifyPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			// nil приходит после переподключения
+			if n == nil {
+				reset()
+				continue
+			}
+			handle(n.Extra)
+		case <-ping.C:
+			// Обрыв соединения без трафика иначе не обнаружится
+			go listener.Ping()
+		}
+	}
+}
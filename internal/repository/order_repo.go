@@ -1,10 +1,36 @@
 package repository
 
-import "github.com/Tommych123/L0-WB/internal/domain"
+import (
+	"context"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
 
 // Интерфейс для работы с БД
 type OrderRepository interface {
-	Save(order *domain.Order) error
-	Get(orderUID string) (*domain.Order, error)
-	GetAll() ([]*domain.Order, error)
+	Save(ctx context.Context, order *domain.Order) error
+	SaveFromMessage(ctx context.Context, order *domain.Order, msg domain.MessageRef) (bool, error)
+	Get(ctx context.Context, orderUID string) (*domain.Order, error)
+	// Заказ только с указанными связанными сущностями; остальные таблицы не читаются
+	GetParts(ctx context.Context, orderUID string, parts domain.OrderParts) (*domain.Order, error)
+	GetAll(ctx context.Context) ([]*domain.Order, error)
+	// Найденные заказы из списка с указанными связанными сущностями; отсутствующие пропускаются
+	GetMany(ctx context.Context, orderUIDs []string, parts domain.OrderParts) ([]*domain.Order, error)
+	// Потоковая выдача заказов, созданных в [from, to), в порядке date_created;
+	// ошибка fn прерывает выдачу
+	StreamOrders(ctx context.Context, from, to time.Time, fn func(*domain.Order) error) error
+	// Все заказы клиента, включая мягко удаленные (с Deleted); пустой срез, если заказов нет
+	GetByCustomer(ctx context.Context, customerID string) ([]*domain.Order, error)
+	// Обезличивает заказы клиента и пишет запись аудита, в которую входят и archived —
+	// order_uid заказов клиента, найденных только в архивах; nil, если заказов нет нигде
+	EraseCustomer(ctx context.Context, customerID string, archived []string, requestedBy, reason string) (*domain.ErasureRecord, error)
+	// Помечает заказ удаленным; nil, если заказа нет. Для уже удаленного заказа возвращает прежнюю пометку
+	SoftDelete(ctx context.Context, orderUID, deletedBy, reason string) (*domain.DeletedOrder, error)
+	// Удаленные заказы, последние удаленные первыми
+	ListDeleted(ctx context.Context, limit int) ([]domain.DeletedOrder, error)
+	// Снимает пометку удаления; false, если удаленного заказа нет
+	Restore(ctx context.Context, orderUID string) (bool, error)
+	// Окончательно удаляет до limit заказов, удаленных раньше before; возвращает их order_uid
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]string, error)
 }
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/lib/pq"
+)
+
+// Аренда публикации outbox: пока она действует, события публикует только одна реплика
+const outboxLeaseName = "outbox"
+
+// Сколько длится публикация одного пакета; аренда берется с запасом
+const outboxPublishTimeout = 30 * time.Second
+
+// Интерфейс для чтения и подтверждения событий outbox
+type OutboxRepository interface {
+	// Передает до limit неопубликованных событий в publish и отмечает опубликованными
+	// первые n из них, где n — результат publish. Возвращает число отмеченных событий
+	PublishOutbox(ctx context.Context, limit int, publish func(events []domain.OutboxEvent) int) (int, error)
+	// Удаляет события, опубликованные раньше before; возвращает их число
+	PruneOutbox(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Выбирает события outbox в порядке записи и отмечает опубликованные.
+// Публикация идет вне транзакции: порядок сохраняет аренда, которую держит одна реплика
+func (r *PostgresOrderRepository) PublishOutbox(ctx context.Context, limit int, publish func(events []domain.OutboxEvent) int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, outboxPublishTimeout)
+	defer cancel()
+
+	holder, err := leaseHolder()
+	if err != nil {
+		return 0, err
+	}
+
+	// Другая реплика уже публикует события: порядок внутри заказа важнее параллельности
+	var leased bool
+	err = getQuery(ctx, r.db, "outbox.lease", &leased, `
+        INSERT INTO outbox_leases (name, holder, expires_at)
+        VALUES ($1, $2, now() + make_interval(secs => $3))
+        ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+        WHERE outbox_leases.expires_at < now()
+        RETURNING true
+    `, outboxLeaseName, holder, (2 * outboxPublishTimeout).Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer r.releaseOutboxLease(context.WithoutCancel(ctx), holder)
+
+	var events []domain.OutboxEvent
+	err = selectQuery(ctx, r.db, "outbox.fetch", &events, `
+        SELECT id, aggregate_id, event_type, payload, created_at
+        FROM outbox
+        WHERE published_at IS NULL
+        ORDER BY id
+        LIMIT $1
+    `, limit)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	for i := range events {
+		if events[i].Payload, err = openEvent(r.keyring, events[i].AggregateID, events[i].Payload); err != nil {
+			return 0, err
+		}
+	}
+
+	published := publish(events)
+	if published == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, 0, published)
+	for _, e := range events[:published] {
+		ids = append(ids, e.ID)
+	}
+	_, err = execQuery(ctx, r.db, "outbox.mark", `
+        UPDATE outbox SET published_at = now() WHERE id = ANY($1) AND published_at IS NULL
+    `, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return published, nil
+}
+
+// Снимает аренду, если она еще принадлежит holder
+func (r *PostgresOrderRepository) releaseOutboxLease(ctx context.Context, holder string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	// Не снятая аренда истечет сама
+	execQuery(ctx, r.db, "outbox.release", `
+        DELETE FROM outbox_leases WHERE name = $1 AND holder = $2
+    `, outboxLeaseName, holder)
+}
+
+// Удаляет опубликованные события старше before
+func (r *PostgresOrderRepository) PruneOutbox(ctx context.Context, before time.Time) (int64, error) {
+	res, err := execQuery(ctx, r.db, "outbox.prune", `
+        DELETE FROM outbox WHERE published_at < $1
+    `, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Случайный идентификатор владельца аренды
+func leaseHolder() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Таблицы заказа, партиционированные по месяцам date_created; связанные идут после orders
+var partitionedTables = []string{"orders", "delivery", "payment", "items"}
+
+// Число строк партиции, читаемых за один FETCH при архивации
+const archiveFetchSize = 1000
+
+// Получатель строк архивируемой партиции
+type ArchiveWriter interface {
+	// Строка таблицы table в виде JSON объекта с колонками
+	WriteRow(table string, row []byte) error
+	// Фиксирует архив на диске; партиции удаляются только после успешного вызова
+	Commit() error
+}
+
+// Источник строк восстанавливаемого архива
+type ArchiveReader interface {
+	// Передает в fn строки таблицы table в порядке записи
+	Rows(table string, fn func(row []byte) error) error
+}
+
+// Интерфейс для управления месячными партициями заказов
+type PartitionRepository interface {
+	// Создает недостающие партиции для месяцев с from по to включительно
+	EnsurePartitions(ctx context.Context, from, to time.Time) error
+	// Первые дни месяцев, для которых есть партиции, по возрастанию
+	ListPartitions(ctx context.Context) ([]time.Time, error)
+	// Пишет строки партиций месяца в w и удаляет партиции; возвращает order_uid архивированных заказов.
+	// Другие экземпляры сервиса получают их в EvictChannel
+	ArchivePartition(ctx context.Context, month time.Time, w ArchiveWriter) ([]string, error)
+	// Восстанавливает строки архива месяца; заказы, которые уже есть в БД, пропускаются.
+	// Заказы из erasure_audit восстанавливаются обезличенными
+	RestoreArchive(ctx context.Context, month time.Time, r ArchiveReader) (*RestoreResult, error)
+}
+
+// Итоги восстановления архива
+type RestoreResult struct {
+	// Число вставленных строк по таблицам
+	Rows map[string]int64
+	// Восстановленные заказы, данные клиентов которых были удалены после архивации
+	Anonymized int
+}
+
+// Первый день месяца по дате и времени без учета часового пояса, как они хранятся в TIMESTAMP
+func monthOf(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Дата и время из TIMESTAMP: часовой пояс отбрасывается, точность — микросекунды
+func wallClock(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	return time.Date(y, mo, d, h, mi, s, t.Nanosecond()/1000*1000, time.UTC)
+}
+
+// Имя партиции таблицы за месяц
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_p%04d%02d", table, month.Year(), int(month.Month()))
+}
+
+// Создает партиции месяца, если их еще нет. Созданные месяцы запоминаются, чтобы
+// не обращаться к БД при каждом сохранении
+func (r *PostgresOrderRepository) ensurePartition(ctx context.Context, t time.Time) error {
+	month := monthOf(t)
+	if _, ok := r.partitions.Load(month); ok {
+		return nil
+	}
+	_, err := execQuery(ctx, r.db, "partitions.ensure", `SELECT ensure_order_partitions($1::date)`, month.Format(time.DateOnly))
+	if errors.Is(err, ErrConflict) {
+		// Партицию параллельно создал другой экземпляр сервиса
+		_, err = execQuery(ctx, r.db, "partitions.ensure", `SELECT ensure_order_partitions($1::date)`, month.Format(time.DateOnly))
+	}
+	if err != nil {
+		return err
+	}
+	r.partitions.Store(month, struct{}{})
+	return nil
+}
+
+// Выполняет запись заказа с датой t, создав партицию месяца. Если партицию успел удалить
+// архиватор другого экземпляра, она создается заново и запись повторяется
+func (r *PostgresOrderRepository) withPartition(ctx context.Context, t time.Time, fn func() error) error {
+	if err := r.ensurePartition(ctx, t); err != nil {
+		return err
+	}
+	err := fn()
+	if !isMissingPartition(err) {
+		return err
+	}
+	r.partitions.Delete(monthOf(t))
+	if err := r.ensurePartition(ctx, t); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// Ошибка вставки строки, для которой нет партиции
+func isMissingPartition(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23514" && strings.Contains(pqErr.Message, "no partition")
+}
+
+// Создает недостающие партиции для месяцев с from по to включительно
+func (r *PostgresOrderRepository) EnsurePartitions(ctx context.Context, from, to time.Time) error {
+	for month := monthOf(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		if err := r.ensurePartition(ctx, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Месяцы, для которых есть партиции orders
+func (r *PostgresOrderRepository) ListPartitions(ctx context.Context) ([]time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var names []string
+	err := selectQuery(ctx, r.db, "partitions.list", &names, `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'orders'::regclass
+        ORDER BY c.relname
+    `)
+	if err != nil {
+		return nil, err
+	}
+	months := make([]time.Time, 0, len(names))
+	for _, name := range names {
+		suffix, ok := strings.CutPrefix(name, "orders_p")
+		if !ok || len(suffix) != 6 {
+			continue
+		}
+		year, err1 := strconv.Atoi(suffix[:4])
+		month, err2 := strconv.Atoi(suffix[4:])
+		if err1 != nil || err2 != nil || month < 1 || month > 12 {
+			continue
+		}
+		months = append(months, time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC))
+	}
+	return months, nil
+}
+
+// Архивирует и удаляет партиции месяца. Партиции блокируются от записи на время
+// выгрузки, поэтому заказы, пришедшие во время архивации, не теряются: они ждут
+// удаления партиций и попадают в заново созданную партицию
+func (r *PostgresOrderRepository) ArchivePartition(ctx context.Context, month time.Time, w ArchiveWriter) ([]string, error) {
+	month = monthOf(month)
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	// Не ждем долго блокировки, которые держат запросы к таблицам заказов
+	if _, err := execQuery(ctx, tx, "partitions.lock_timeout", `SET LOCAL lock_timeout = '10s'`); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(partitionedTables))
+	for i, table := range partitionedTables {
+		names[i] = pq.QuoteIdentifier(partitionName(table, month))
+	}
+	_, err = execQuery(ctx, tx, "partitions.lock", `LOCK TABLE `+strings.Join(names, ", ")+` IN SHARE MODE`)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []string
+	err = selectQuery(ctx, tx, "partitions.archive_uids", &uids, `SELECT order_uid FROM `+names[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, table := range partitionedTables {
+		if err := dumpPartition(ctx, tx, table, names[i], w); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Сначала связанные таблицы: на строки партиции orders не должно остаться ссылок,
+	// иначе ее нельзя отсоединить
+	for i := len(names) - 1; i > 0; i-- {
+		if _, err := execQuery(ctx, tx, "partitions.drop", `DROP TABLE `+names[i]); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := execQuery(ctx, tx, "partitions.detach", `ALTER TABLE orders DETACH PARTITION `+names[0]); err != nil {
+		return nil, err
+	}
+	if _, err := execQuery(ctx, tx, "partitions.drop", `DROP TABLE `+names[0]); err != nil {
+		return nil, err
+	}
+	// Архивированные заказы уходят из кэшей всех экземпляров после коммита
+	if err := notifyEvict(ctx, tx, uids); err != nil {
+		return nil, err
+	}
+	if err := classifyError(tx.Commit()); err != nil {
+		return nil, err
+	}
+	r.partitions.Delete(month)
+	return uids, nil
+}
+
+// Передает строки партиции в w, читая их курсором
+func dumpPartition(ctx context.Context, tx *sqlx.Tx, table, partition string, w ArchiveWriter) error {
+	cursor := pq.QuoteIdentifier("archive_" + table)
+	_, err := execQuery(ctx, tx, "partitions.archive_declare", `
+        DECLARE `+cursor+` NO SCROLL CURSOR FOR
+        SELECT row_to_json(t)::text FROM `+partition+` t
+    `)
+	if err != nil {
+		return err
+	}
+	fetch := "FETCH " + strconv.Itoa(archiveFetchSize) + " FROM " + cursor
+	for {
+		var rows []string
+		if err := selectQuery(ctx, tx, "partitions.archive_fetch", &rows, fetch); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := w.WriteRow(table, []byte(row)); err != nil {
+				return err
+			}
+		}
+		if len(rows) < archiveFetchSize {
+			break
+		}
+	}
+	_, err = execQuery(ctx, tx, "partitions.archive_close", `CLOSE `+cursor)
+	return err
+}
+
+// Восстанавливает архив месяца в одной транзакции. Архив мог быть записан до удаления
+// данных клиента, поэтому заказы из erasure_audit обезличиваются повторно до коммита
+func (r *PostgresOrderRepository) RestoreArchive(ctx context.Context, month time.Time, src ArchiveReader) (*RestoreResult, error) {
+	if err := r.ensurePartition(ctx, month); err != nil {
+		return nil, err
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	restored := make(map[string]int64, len(partitionedTables))
+	for _, table := range partitionedTables {
+		batch := make([]json.RawMessage, 0, archiveFetchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			n, err := restoreRows(ctx, tx, table, batch)
+			restored[table] += n
+			batch = batch[:0]
+			return err
+		}
+		err := src.Rows(table, func(row []byte) error {
+			batch = append(batch, json.RawMessage(row))
+			if len(batch) == archiveFetchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	month = monthOf(month)
+	// rid восстановленных позиций снова закрепляются за их заказами
+	_, err = execQuery(ctx, tx, "partitions.restore_rids", `
+        INSERT INTO item_rids (rid, order_uid)
+        SELECT rid, order_uid FROM `+pq.QuoteIdentifier(partitionName("items", month))+`
+        ON CONFLICT (rid) DO NOTHING
+    `)
+	if err != nil {
+		return nil, err
+	}
+
+	var erased []string
+	err = selectQuery(ctx, tx, "partitions.restore_erased", &erased, `
+        SELECT o.order_uid FROM orders o
+        WHERE o.date_created >= $1 AND o.date_created < $2
+          AND o.customer_id <> $3
+          AND EXISTS (SELECT 1 FROM erasure_audit a WHERE o.order_uid = ANY(a.order_uids))
+    `, month, month.AddDate(0, 1, 0), erasedCustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(erased) > 0 {
+		if err := anonymizeOrders(ctx, tx, erased); err != nil {
+			return nil, err
+		}
+	}
+	return &RestoreResult{Rows: restored, Anonymized: len(erased)}, classifyError(tx.Commit())
+}
+
+// Вставляет пачку строк таблицы из JSON. Заказ пропускается, если order_uid уже есть
+// в любой партиции; связанные строки вставляются только к восстановленному заказу.
+// rid архивированных позиций остаются в item_rids, пока заказ не удален окончательно
+func restoreRows(ctx context.Context, tx *sqlx.Tx, table string, rows []json.RawMessage) (int64, error) {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return 0, err
+	}
+	cond := `EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = r.order_uid AND o.date_created = r.date_created)`
+	switch table {
+	case "orders":
+		cond = `NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = r.order_uid)`
+	case "items":
+		// Позиция, rid которой закреплен за другим заказом, не восстанавливается
+		cond += ` AND NOT EXISTS (SELECT 1 FROM item_rids x WHERE x.rid = r.rid AND x.order_uid <> r.order_uid)`
+	}
+	res, err := execQuery(ctx, tx, "partitions.restore", `
+        INSERT INTO `+table+`
+        SELECT r.* FROM json_populate_recordset(NULL::`+table+`, $1::json) r
+        WHERE `+cond+`
+        ON CONFLICT DO NOTHING
+    `, string(data))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
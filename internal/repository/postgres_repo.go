@@ -3,111 +3,359 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/encryption"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Описание структуры для подключения к БД
 type PostgresOrderRepository struct {
 	db *sqlx.DB
+	// Ключи шифрования персональных данных; nil — данные хранятся открыто
+	keyring *encryption.Keyring
+	// Месяцы, для которых партиции уже созданы
+	partitions sync.Map
 }
 
-// Создание нового репозитория
-func NewPostgresOrderRepository(db *sqlx.DB) *PostgresOrderRepository {
-	return &PostgresOrderRepository{db: db}
+// Создание нового репозитория; keyring может быть nil
+func NewPostgresOrderRepository(db *sqlx.DB, keyring *encryption.Keyring) *PostgresOrderRepository {
+	return &PostgresOrderRepository{db: db, keyring: keyring}
 }
 
 // Сохраняет заказ в БД
-func (rep *PostgresOrderRepository) Save(order *domain.Order) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (rep *PostgresOrderRepository) Save(ctx context.Context, order *domain.Order) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	return rep.withPartition(ctx, order.DateCreated, func() error {
+		tx, err := rep.db.BeginTxx(ctx, nil)
+		if err != nil {
+			return classifyError(err)
+		}
+		if err := rep.saveOrderTx(ctx, tx, order); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return classifyError(tx.Commit())
+	})
+}
+
+// Сохраняет заказ вместе с записью в журнале обработанных сообщений в одной транзакции.
+// Возвращает true, если сообщение уже обрабатывалось и заказ не сохранялся повторно
+func (rep *PostgresOrderRepository) SaveFromMessage(ctx context.Context, order *domain.Order, msg domain.MessageRef) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var duplicate bool
+	err := rep.withPartition(ctx, order.DateCreated, func() (err error) {
+		duplicate, err = rep.saveFromMessage(ctx, order, msg)
+		return err
+	})
+	return duplicate, err
+}
+
+func (rep *PostgresOrderRepository) saveFromMessage(ctx context.Context, order *domain.Order, msg domain.MessageRef) (bool, error) {
 	tx, err := rep.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, classifyError(err)
+	}
+	// Сначала фиксируем сообщение в журнале: конфликт означает повторную доставку
+	res, err := execQuery(ctx, tx, "ledger.insert", `
+        INSERT INTO processed_messages (topic, partition, "offset", message_id, order_uid)
+        VALUES ($1,$2,$3,NULLIF($4,''),$5)
+        ON CONFLICT DO NOTHING
+    `, msg.Topic, msg.Partition, msg.Offset, msg.MessageID, order.OrderUID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if inserted == 0 {
+		tx.Rollback()
+		return true, nil
+	}
+
+	if err := rep.saveOrderTx(ctx, tx, order); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return false, classifyError(tx.Commit())
+}
+
+// Закрепляет rid позиций заказа в item_rids. Ключ items включает date_created и гарантирует
+// уникальность rid только внутри одной даты, поэтому владелец rid проверяется по этой
+// непартиционированной таблице. rid, которых больше нет в заказе, освобождаются
+func claimItemRIDs(ctx context.Context, q queryer, order *domain.Order) error {
+	rids := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		var owned bool
+		err := getQuery(ctx, q, "item_rids.claim", &owned, `
+            INSERT INTO item_rids (rid, order_uid) VALUES ($1, $2)
+            ON CONFLICT (rid) DO UPDATE SET order_uid = EXCLUDED.order_uid
+            WHERE item_rids.order_uid = EXCLUDED.order_uid
+            RETURNING true
+        `, item.RID, order.OrderUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: rid %q", ErrItemRIDTaken, item.RID)
+		}
+		if err != nil {
+			return err
+		}
+		rids = append(rids, item.RID)
+	}
+	_, err := execQuery(ctx, q, "item_rids.release", `
+        DELETE FROM item_rids WHERE order_uid = $1 AND rid <> ALL($2)
+    `, order.OrderUID, pq.Array(rids))
+	return err
+}
+
+// Вставляет или обновляет заказ во всех таблицах и пишет событие в outbox
+// в рамках переданной транзакции; у удаленного заказа обновляются только данные
+func (rep *PostgresOrderRepository) saveOrderTx(ctx context.Context, tx *sqlx.Tx, order *domain.Order) error {
+	if err := lockOrderUID(ctx, tx, order.OrderUID); err != nil {
+		return err
+	}
+
+	// Сохраненное состояние заказа: дата создания, пометка удаления и вклад в дневные агрегаты
+	stored, err := lockStoredOrder(ctx, tx, order.OrderUID)
 	if err != nil {
 		return err
 	}
-	// Вставка в orders
-	_, err = tx.ExecContext(ctx, `
+	inserted := stored == nil
+	// Удаленный заказ обновляется, но остается удаленным до восстановления
+	var deletedAt *time.Time
+	var deletedBy, deleteReason *string
+	if stored != nil {
+		deletedAt, deletedBy, deleteReason = stored.DeletedAt, stored.DeletedBy, stored.DeleteReason
+	}
+	order.Deleted = deletedAt != nil
+
+	// Дата создания определяет партицию; при ее изменении заказ удаляется со связанными
+	// записями и вставляется заново
+	if stored != nil && !wallClock(stored.DateCreated).Equal(wallClock(order.DateCreated)) {
+		_, err = execQuery(ctx, tx, "orders.move", `
+            DELETE FROM orders WHERE order_uid = $1 AND date_created = $2
+        `, order.OrderUID, stored.DateCreated)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Вставка или обновление orders; пометка удаления переносится при смене партиции
+	err = getQuery(ctx, tx, "orders.upsert", &order.UpdatedAt, `
 	INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
-		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-		ON CONFLICT (order_uid) DO NOTHING`,
+		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+		deleted_at, deleted_by, delete_reason)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+		ON CONFLICT (order_uid, date_created) DO UPDATE SET
+			track_number = EXCLUDED.track_number, entry = EXCLUDED.entry,
+			locale = EXCLUDED.locale, internal_signature = EXCLUDED.internal_signature,
+			customer_id = EXCLUDED.customer_id, delivery_service = EXCLUDED.delivery_service,
+			shardkey = EXCLUDED.shardkey, sm_id = EXCLUDED.sm_id,
+			oof_shard = EXCLUDED.oof_shard,
+			updated_at = now()
+		RETURNING updated_at`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.ShardKey, order.SmID, order.DateCreated, order.OofShard)
+		order.ShardKey, order.SmID, order.DateCreated, order.OofShard,
+		deletedAt, deletedBy, deleteReason)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-	// Вставка в delivery
-	_, err = tx.ExecContext(ctx, `
-        INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-        ON CONFLICT (order_uid) DO NOTHING
+
+	// Вставка в delivery; персональные данные шифруются, для email и телефона пишутся слепые индексы
+	delivery, err := rep.sealDelivery(order.OrderUID, order.Delivery)
+	if err != nil {
+		return err
+	}
+	emailIdx, phoneIdx := rep.blindIndexes(order.Delivery)
+	_, err = execQuery(ctx, tx, "delivery.upsert", `
+        INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email, email_bidx, phone_bidx, date_created)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+        ON CONFLICT (order_uid, date_created) DO UPDATE SET
+            name = EXCLUDED.name, phone = EXCLUDED.phone, zip = EXCLUDED.zip,
+            city = EXCLUDED.city, address = EXCLUDED.address,
+            region = EXCLUDED.region, email = EXCLUDED.email,
+            email_bidx = EXCLUDED.email_bidx, phone_bidx = EXCLUDED.phone_bidx
     `,
-		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
-		order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+		order.OrderUID, delivery.Name, delivery.Phone, delivery.Zip,
+		delivery.City, delivery.Address, delivery.Region, delivery.Email,
+		emailIdx, phoneIdx, order.DateCreated,
 	)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
 	// Вставка в payment
-	_, err = tx.ExecContext(ctx, `
+	transaction, err := rep.seal("payment.transaction", order.OrderUID, order.Payment.Transaction)
+	if err != nil {
+		return err
+	}
+	_, err = execQuery(ctx, tx, "payment.upsert", `
         INSERT INTO payment (order_uid, transaction, request_id, currency, provider, amount,
-                             payment_dt, bank, delivery_cost, goods_total, custom_fee)
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-        ON CONFLICT (order_uid) DO NOTHING
+                             payment_dt, bank, delivery_cost, goods_total, custom_fee, date_created)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+        ON CONFLICT (order_uid, date_created) DO UPDATE SET
+            transaction = EXCLUDED.transaction, request_id = EXCLUDED.request_id,
+            currency = EXCLUDED.currency, provider = EXCLUDED.provider,
+            amount = EXCLUDED.amount, payment_dt = EXCLUDED.payment_dt,
+            bank = EXCLUDED.bank, delivery_cost = EXCLUDED.delivery_cost,
+            goods_total = EXCLUDED.goods_total, custom_fee = EXCLUDED.custom_fee
     `,
-		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID,
+		order.OrderUID, transaction, order.Payment.RequestID,
 		order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
 		order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost,
-		order.Payment.GoodsTotal, order.Payment.CustomFee,
+		order.Payment.GoodsTotal, order.Payment.CustomFee, order.DateCreated,
 	)
 	if err != nil {
-		tx.Rollback()
+		return err
+	}
+
+	// rid позиций закрепляются за заказом во всех партициях сразу
+	if err := claimItemRIDs(ctx, tx, order); err != nil {
 		return err
 	}
 
 	// Вставка в items (может быть несколько записей)
+	rids := make([]string, 0, len(order.Items))
 	for _, item := range order.Items {
-		_, err = tx.ExecContext(ctx, `
+		// Позиция с тем же rid в другом заказе не перезаписывается
+		var owned bool
+		err = getQuery(ctx, tx, "items.upsert", &owned, `
             INSERT INTO items (chrt_id, track_number, price, rid, name, sale, size,
-                               total_price, nm_id, brand, status, order_uid)
-            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
-            ON CONFLICT (rid) DO NOTHING
+                               total_price, nm_id, brand, status, order_uid, date_created)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+            ON CONFLICT (rid, date_created) DO UPDATE SET
+                chrt_id = EXCLUDED.chrt_id, track_number = EXCLUDED.track_number,
+                price = EXCLUDED.price, name = EXCLUDED.name, sale = EXCLUDED.sale,
+                size = EXCLUDED.size, total_price = EXCLUDED.total_price,
+                nm_id = EXCLUDED.nm_id, brand = EXCLUDED.brand,
+                status = EXCLUDED.status
+            WHERE items.order_uid = EXCLUDED.order_uid
+            RETURNING true
         `,
 			item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
 			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand,
-			item.Status, order.OrderUID,
+			item.Status, order.OrderUID, order.DateCreated,
 		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: rid %q", ErrItemRIDTaken, item.RID)
+		}
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
+		rids = append(rids, item.RID)
+	}
+
+	// Удаляем позиции, которых больше нет в обновленном заказе
+	if !inserted {
+		_, err = execQuery(ctx, tx, "items.prune", `
+            DELETE FROM items WHERE order_uid = $1 AND rid <> ALL($2)
+        `, order.OrderUID, pq.Array(rids))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Удаленный заказ не учитывается в агрегатах и не публикуется потребителям
+	if order.Deleted {
+		return nil
+	}
+
+	// Дневные агрегаты для аналитики
+	var oldRollup *rollupDelta
+	if stored != nil {
+		oldRollup = &stored.rollupDelta
+	}
+	if err := updateRollups(ctx, tx, oldRollup, order); err != nil {
+		return err
+	}
+
+	// Событие для внешних потребителей публикуется relay после коммита
+	eventType := domain.EventOrderUpdated
+	if inserted {
+		eventType = domain.EventOrderPersisted
+	}
+	payload, err := json.Marshal(domain.OrderEvent{
+		Type:       eventType,
+		OrderUID:   order.OrderUID,
+		OccurredAt: time.Now().UTC(),
+		Order:      order,
+	})
+	if err != nil {
+		return err
+	}
+	// Заказ в payload хранится зашифрованным, как и колонки заказа
+	if payload, err = sealEvent(rep.keyring, order.OrderUID, payload); err != nil {
+		return err
+	}
+	var eventID int64
+	err = getQuery(ctx, tx, "outbox.insert", &eventID, `
+        INSERT INTO outbox (aggregate_id, event_type, payload)
+        VALUES ($1,$2,$3)
+        RETURNING id
+    `, order.OrderUID, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	// Доставки webhook ставятся в очередь в той же транзакции и не зависят от публикации в Kafka.
+	// Подписка подходит, если ее фильтры пусты или совпадают с событием
+	_, err = execQuery(ctx, tx, "webhook_deliveries.enqueue", `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, order_uid, payload)
+        SELECT id, $1, $2, $3, $4 FROM webhook_subscriptions
+        WHERE active
+          AND (entry = '' OR entry = $5)
+          AND (delivery_service = '' OR delivery_service = $6)
+          AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+        ON CONFLICT (subscription_id, event_id) DO NOTHING
+    `, eventID, eventType, order.OrderUID, payload, order.Entry, order.DeliveryService)
+	if err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	// Ленты заказов всех экземпляров получают заказ после коммита
+	_, err = execQuery(ctx, tx, "orders.notify_saved", `SELECT pg_notify($1, $2)`, OrderSavedChannel, order.OrderUID)
+	return err
+}
+
+// Блокировка order_uid до конца транзакции. order_uid уникален среди всех партиций,
+// поэтому изменения одного заказа выполняются по очереди
+func lockOrderUID(ctx context.Context, tx *sqlx.Tx, orderUID string) error {
+	_, err := execQuery(ctx, tx, "orders.lock", `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, orderUID)
+	return err
 }
 
 // Получение записи по ID
-func (r *PostgresOrderRepository) Get(orderUID string) (*domain.Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *PostgresOrderRepository) Get(ctx context.Context, orderUID string) (*domain.Order, error) {
+	return r.GetParts(ctx, orderUID, domain.AllOrderParts)
+}
+
+// Получение записи по ID только с указанными связанными сущностями
+func (r *PostgresOrderRepository) GetParts(ctx context.Context, orderUID string, parts domain.OrderParts) (*domain.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	var order domain.Order
-	err := r.db.GetContext(ctx, &order, `
+	err := getQuery(ctx, r.db, "orders.get", &order, `
         SELECT order_uid, track_number, entry, locale, internal_signature,
-               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at
         FROM orders
-        WHERE order_uid = $1
+        WHERE order_uid = $1 AND deleted_at IS NULL
     `, orderUID)
 	if err != nil {
-		// Заказ отсутствует
+		// Заказ отсутствует или удален
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -115,56 +363,93 @@ func (r *PostgresOrderRepository) Get(orderUID string) (*domain.Order, error) {
 	}
 
 	// Получаем delivery
-	var delivery domain.Delivery
-	err = r.db.GetContext(ctx, &delivery, `
-        SELECT name, phone, zip, city, address, region, email
-        FROM delivery WHERE order_uid = $1
-    `, orderUID)
-	if err != nil {
-		return nil, err
+	if parts.Delivery {
+		var delivery domain.Delivery
+		err = getQuery(ctx, r.db, "delivery.get", &delivery, `
+            SELECT name, phone, zip, city, address, region, email
+            FROM delivery WHERE order_uid = $1
+        `, orderUID)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.openDelivery(orderUID, &delivery); err != nil {
+			return nil, err
+		}
+		order.Delivery = delivery
 	}
-	order.Delivery = delivery
 
 	// Получаем payment
-	var payment domain.Payment
-	err = r.db.GetContext(ctx, &payment, `
-        SELECT transaction, request_id, currency, provider, amount,
-               payment_dt, bank, delivery_cost, goods_total, custom_fee
-        FROM payment WHERE order_uid = $1
-    `, orderUID)
-	if err != nil {
-		return nil, err
+	if parts.Payment {
+		var payment domain.Payment
+		err = getQuery(ctx, r.db, "payment.get", &payment, `
+            SELECT transaction, request_id, currency, provider, amount,
+                   payment_dt, bank, delivery_cost, goods_total, custom_fee
+            FROM payment WHERE order_uid = $1
+        `, orderUID)
+		if err != nil {
+			return nil, err
+		}
+		if payment.Transaction, err = r.open("payment.transaction", orderUID, payment.Transaction); err != nil {
+			return nil, err
+		}
+		order.Payment = payment
 	}
-	order.Payment = payment
 
 	// Получаем items
-	var items []domain.Item
-	err = r.db.SelectContext(ctx, &items, `
-        SELECT chrt_id, track_number, price, rid, name, sale, size,
-               total_price, nm_id, brand, status
-        FROM items WHERE order_uid = $1
-    `, orderUID)
-	if err != nil {
-		return nil, err
+	if parts.Items {
+		var items []domain.Item
+		err = selectQuery(ctx, r.db, "items.get", &items, `
+            SELECT chrt_id, track_number, price, rid, name, sale, size,
+                   total_price, nm_id, brand, status
+            FROM items WHERE order_uid = $1
+        `, orderUID)
+		if err != nil {
+			return nil, err
+		}
+		order.Items = items
 	}
-	order.Items = items
 
 	// Возвращаем собранный заказ из 4 таблиц
 	return &order, nil
 }
 
 // Возвращает все заказы из базы со всеми связанными сущностями
-func (r *PostgresOrderRepository) GetAll() ([]*domain.Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *PostgresOrderRepository) GetAll(ctx context.Context) ([]*domain.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	return r.loadOrders(ctx, "all", domain.AllOrderParts, "")
+}
 
+// Возвращает найденные заказы из списка; по одному запросу на каждую нужную таблицу.
+// Отсутствующие order_uid пропускаются, порядок не гарантируется
+func (r *PostgresOrderRepository) GetMany(ctx context.Context, orderUIDs []string, parts domain.OrderParts) ([]*domain.Order, error) {
+	if len(orderUIDs) == 0 {
+		return []*domain.Order{}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return r.loadOrders(ctx, "many", parts, "WHERE order_uid = ANY($1)", pq.Array(orderUIDs))
+}
+
+// Собирает заказы из orders и нужных связанных таблиц; filter с аргументами применяется
+// к каждой таблице, name различает запросы в метриках. Удаленные заказы пропускаются
+func (r *PostgresOrderRepository) loadOrders(ctx context.Context, name string, parts domain.OrderParts, filter string, args ...any) ([]*domain.Order, error) {
+	ordersFilter := "WHERE deleted_at IS NULL"
+	if filter != "" {
+		ordersFilter = filter + " AND deleted_at IS NULL"
+	}
+	return r.loadOrdersWhere(ctx, name, parts, ordersFilter, filter, args...)
+}
+
+// Собирает заказы, выбранные из orders условием ordersFilter; связанные таблицы читаются с filter
+func (r *PostgresOrderRepository) loadOrdersWhere(ctx context.Context, name string, parts domain.OrderParts, ordersFilter, filter string, args ...any) ([]*domain.Order, error) {
 	// Получаем все заказы из таблицы orders
 	var orders []domain.Order
-	err := r.db.SelectContext(ctx, &orders, `
+	err := selectQuery(ctx, r.db, "orders."+name, &orders, `
         SELECT order_uid, track_number, entry, locale, internal_signature,
-               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at
         FROM orders
-    `)
+        `+ordersFilter, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -175,25 +460,58 @@ func (r *PostgresOrderRepository) GetAll() ([]*domain.Order, error) {
 		orderMap[orders[i].OrderUID] = &orders[i]
 	}
 
-	// Получаем все записи из delivery
+	// Получаем связанные записи только из нужных таблиц
+	if parts.Delivery {
+		if err := r.loadDeliveries(ctx, r.db, name, orderMap, filter, args...); err != nil {
+			return nil, err
+		}
+	}
+	if parts.Payment {
+		if err := r.loadPayments(ctx, r.db, name, orderMap, filter, args...); err != nil {
+			return nil, err
+		}
+	}
+	if parts.Items {
+		if err := r.loadItems(ctx, r.db, name, orderMap, filter, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Преобразуем срез orders в срез указателей для возвращения
+	result := make([]*domain.Order, 0, len(orders))
+	for i := range orders {
+		result = append(result, &orders[i])
+	}
+
+	return result, nil
+}
+
+// Заполняет delivery заказов из orderMap
+func (r *PostgresOrderRepository) loadDeliveries(ctx context.Context, q queryer, name string, orderMap map[string]*domain.Order, filter string, args ...any) error {
 	var deliveries []struct {
 		OrderUID string `db:"order_uid"`
 		domain.Delivery
 	}
-	err = r.db.SelectContext(ctx, &deliveries, `
+	err := selectQuery(ctx, q, "delivery."+name, &deliveries, `
         SELECT order_uid, name, phone, zip, city, address, region, email
         FROM delivery
-    `)
+        `+filter, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	for _, d := range deliveries {
 		if o, ok := orderMap[d.OrderUID]; ok {
+			if err := r.openDelivery(d.OrderUID, &d.Delivery); err != nil {
+				return err
+			}
 			o.Delivery = d.Delivery
 		}
 	}
+	return nil
+}
 
-	// Получаем все записи из payment
+// Заполняет payment заказов из orderMap
+func (r *PostgresOrderRepository) loadPayments(ctx context.Context, q queryer, name string, orderMap map[string]*domain.Order, filter string, args ...any) error {
 	var payments []struct {
 		OrderUID string `db:"order_uid"`
 
@@ -208,16 +526,19 @@ func (r *PostgresOrderRepository) GetAll() ([]*domain.Order, error) {
 		GoodsTotal   int    `db:"goods_total"`
 		CustomFee    int    `db:"custom_fee"`
 	}
-	err = r.db.SelectContext(ctx, &payments, `
+	err := selectQuery(ctx, q, "payment."+name, &payments, `
         SELECT order_uid, transaction, request_id, currency, provider, amount,
                payment_dt, bank, delivery_cost, goods_total, custom_fee
         FROM payment
-    `)
+        `+filter, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	for _, p := range payments {
 		if o, ok := orderMap[p.OrderUID]; ok {
+			if p.Transaction, err = r.open("payment.transaction", p.OrderUID, p.Transaction); err != nil {
+				return err
+			}
 			o.Payment = domain.Payment{
 				Transaction:  p.Transaction,
 				RequestID:    p.RequestID,
@@ -232,8 +553,11 @@ func (r *PostgresOrderRepository) GetAll() ([]*domain.Order, error) {
 			}
 		}
 	}
+	return nil
+}
 
-	// Получаем все записи из items
+// Заполняет items заказов из orderMap
+func (r *PostgresOrderRepository) loadItems(ctx context.Context, q queryer, name string, orderMap map[string]*domain.Order, filter string, args ...any) error {
 	var items []struct {
 		ChrtID      int    `db:"chrt_id"`
 		TrackNumber string `db:"track_number"`
@@ -248,13 +572,13 @@ func (r *PostgresOrderRepository) GetAll() ([]*domain.Order, error) {
 		Status      int    `db:"status"`
 		OrderUID    string `db:"order_uid"`
 	}
-	err = r.db.SelectContext(ctx, &items, `
+	err := selectQuery(ctx, q, "items."+name, &items, `
         SELECT chrt_id, track_number, price, rid, name, sale, size,
                total_price, nm_id, brand, status, order_uid
         FROM items
-    `)
+        `+filter, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	for _, item := range items {
 		if o, ok := orderMap[item.OrderUID]; ok {
@@ -273,12 +597,5 @@ func (r *PostgresOrderRepository) GetAll() ([]*domain.Order, error) {
 			})
 		}
 	}
-
-	// Преобразуем срез orders в срез указателей для возвращения
-	result := make([]*domain.Order, 0, len(orders))
-	for i := range orders {
-		result = append(result, &orders[i])
-	}
-
-	return result, nil
+	return nil
 }
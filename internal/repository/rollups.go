@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// Вклад одного заказа в строку order_rollups_daily; у отменяемого вклада значения отрицательные
+type rollupDelta struct {
+	Day             time.Time `db:"day"`
+	Currency        string    `db:"currency"`
+	DeliveryService string    `db:"delivery_service"`
+	Provider        string    `db:"provider"`
+	Orders          int64     `db:"orders"`
+	Revenue         int64     `db:"revenue"`
+	DeliveryCost    int64     `db:"delivery_cost"`
+	Items           int64     `db:"items"`
+	DiscountedItems int64     `db:"discounted_items"`
+	SaleSum         int64     `db:"sale_sum"`
+}
+
+func (d rollupDelta) sameKey(o rollupDelta) bool {
+	return d.Day.Equal(o.Day) && d.Currency == o.Currency &&
+		d.DeliveryService == o.DeliveryService && d.Provider == o.Provider
+}
+
+// Та же строка агрегатов с нулевым вкладом
+func (d rollupDelta) keyOnly() rollupDelta {
+	return rollupDelta{Day: d.Day, Currency: d.Currency, DeliveryService: d.DeliveryService, Provider: d.Provider}
+}
+
+func (d rollupDelta) negate() rollupDelta {
+	return d.keyOnly().sub(d)
+}
+
+func (d rollupDelta) sub(o rollupDelta) rollupDelta {
+	d.Orders -= o.Orders
+	d.Revenue -= o.Revenue
+	d.DeliveryCost -= o.DeliveryCost
+	d.Items -= o.Items
+	d.DiscountedItems -= o.DiscountedItems
+	d.SaleSum -= o.SaleSum
+	return d
+}
+
+// Вклад заказа в агрегаты; день берется из date_created так же, как date_created::date в БД
+func orderRollup(order *domain.Order) rollupDelta {
+	y, m, d := order.DateCreated.Date()
+	delta := rollupDelta{
+		Day:             time.Date(y, m, d, 0, 0, 0, 0, time.UTC),
+		Currency:        order.Payment.Currency,
+		DeliveryService: order.DeliveryService,
+		Provider:        order.Payment.Provider,
+		Orders:          1,
+		Revenue:         int64(order.Payment.Amount),
+		DeliveryCost:    int64(order.Payment.DeliveryCost),
+		Items:           int64(len(order.Items)),
+	}
+	for _, item := range order.Items {
+		if item.Sale > 0 {
+			delta.DiscountedItems++
+		}
+		delta.SaleSum += int64(item.Sale)
+	}
+	return delta
+}
+
+// Сохраненная дата создания заказа, пометка удаления и вклад заказа в агрегаты
+type storedOrder struct {
+	DateCreated  time.Time  `db:"date_created"`
+	DeletedAt    *time.Time `db:"deleted_at"`
+	DeletedBy    *string    `db:"deleted_by"`
+	DeleteReason *string    `db:"delete_reason"`
+	rollupDelta
+}
+
+// Сохраненное состояние заказа с блокировкой строки orders; nil, если заказа еще нет
+func lockStoredOrder(ctx context.Context, tx *sqlx.Tx, orderUID string) (*storedOrder, error) {
+	var stored storedOrder
+	err := getQuery(ctx, tx, "orders.stored", &stored, `
+        SELECT o.date_created, o.deleted_at, o.deleted_by, o.delete_reason,
+               o.date_created::date AS day, p.currency, o.delivery_service, p.provider,
+               1 AS orders, p.amount AS revenue, p.delivery_cost,
+               it.items, it.discounted_items, it.sale_sum
+        FROM orders o
+        JOIN payment p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
+        CROSS JOIN LATERAL (
+            SELECT count(*) AS items,
+                   count(*) FILTER (WHERE i.sale > 0) AS discounted_items,
+                   COALESCE(sum(i.sale), 0) AS sale_sum
+            FROM items i
+            WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created
+        ) it
+        WHERE o.order_uid = $1
+        FOR UPDATE OF o
+    `, orderUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// Переносит вклад заказа в агрегатах со старого состояния (nil — новый заказ) на новое
+func updateRollups(ctx context.Context, tx queryer, old *rollupDelta, order *domain.Order) error {
+	next := orderRollup(order)
+	if old == nil {
+		return applyRollup(ctx, tx, next)
+	}
+	if old.sameKey(next) {
+		return applyRollup(ctx, tx, next.sub(*old))
+	}
+	if err := applyRollup(ctx, tx, old.negate()); err != nil {
+		return err
+	}
+	return applyRollup(ctx, tx, next)
+}
+
+// Прибавляет вклад к строке агрегатов; строка без заказов удаляется
+func applyRollup(ctx context.Context, tx queryer, d rollupDelta) error {
+	if d == d.keyOnly() {
+		return nil
+	}
+	_, err := execQuery(ctx, tx, "rollups.apply", `
+        INSERT INTO order_rollups_daily AS r (day, currency, delivery_service, provider,
+            orders, revenue, delivery_cost, items, discounted_items, sale_sum)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+        ON CONFLICT (day, currency, delivery_service, provider) DO UPDATE SET
+            orders = r.orders + EXCLUDED.orders,
+            revenue = r.revenue + EXCLUDED.revenue,
+            delivery_cost = r.delivery_cost + EXCLUDED.delivery_cost,
+            items = r.items + EXCLUDED.items,
+            discounted_items = r.discounted_items + EXCLUDED.discounted_items,
+            sale_sum = r.sale_sum + EXCLUDED.sale_sum
+    `, d.Day, d.Currency, d.DeliveryService, d.Provider,
+		d.Orders, d.Revenue, d.DeliveryCost, d.Items, d.DiscountedItems, d.SaleSum)
+	if err != nil || d.Orders >= 0 {
+		return err
+	}
+	_, err = execQuery(ctx, tx, "rollups.prune", `
+        DELETE FROM order_rollups_daily
+        WHERE day = $1 AND currency = $2 AND delivery_service = $3 AND provider = $4 AND orders <= 0
+    `, d.Day, d.Currency, d.DeliveryService, d.Provider)
+	return err
+}
+
+// Пересчитывает агрегаты за дни [from, to) из таблиц заказов и возвращает число строк агрегатов.
+// Таблица агрегатов блокируется от параллельных сохранений до конца пересчета, поэтому
+// заказы, сохраненные во время пересчета, учитываются ровно один раз
+func (r *PostgresOrderRepository) RebuildRollups(ctx context.Context, from, to time.Time) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := execQuery(ctx, tx, "rollups.lock", `
+        LOCK TABLE order_rollups_daily IN SHARE ROW EXCLUSIVE MODE
+    `); err != nil {
+		return 0, err
+	}
+	if _, err := execQuery(ctx, tx, "rollups.clear", `
+        DELETE FROM order_rollups_daily WHERE day >= $1::date AND day < $2::date
+    `, from, to); err != nil {
+		return 0, err
+	}
+	res, err := execQuery(ctx, tx, "rollups.rebuild", `
+        INSERT INTO order_rollups_daily (day, currency, delivery_service, provider,
+            orders, revenue, delivery_cost, items, discounted_items, sale_sum)
+        SELECT o.date_created::date, p.currency, o.delivery_service, p.provider,
+               count(*), COALESCE(sum(p.amount), 0), COALESCE(sum(p.delivery_cost), 0),
+               COALESCE(sum(it.items), 0), COALESCE(sum(it.discounted_items), 0), COALESCE(sum(it.sale_sum), 0)
+        FROM orders o
+        JOIN payment p ON p.order_uid = o.order_uid
+        CROSS JOIN LATERAL (
+            SELECT count(*) AS items,
+                   count(*) FILTER (WHERE i.sale > 0) AS discounted_items,
+                   COALESCE(sum(i.sale), 0) AS sale_sum
+            FROM items i
+            WHERE i.order_uid = o.order_uid
+        ) it
+        WHERE o.date_created >= $1::date AND o.date_created < $2::date AND o.deleted_at IS NULL
+        GROUP BY 1, 2, 3, 4
+    `, from, to)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rows, classifyError(tx.Commit())
+}
+
+// Аналитика по дневным агрегатам: выручка, разбивка по службе доставки и провайдеру
+// и сводка читаются из order_rollups_daily, остальные отчеты — из таблиц заказов.
+// Границы периода округляются до целых дней: from вниз, to вверх
+type RollupAnalytics struct {
+	*PostgresOrderRepository
+}
+
+// Создание аналитики поверх дневных агрегатов
+func NewRollupAnalytics(repo *PostgresOrderRepository) *RollupAnalytics {
+	return &RollupAnalytics{PostgresOrderRepository: repo}
+}
+
+// Колонки измерений, которые есть в агрегатах
+var rollupBreakdownColumns = map[string]string{
+	domain.DimensionDeliveryService: "delivery_service",
+	domain.DimensionProvider:        "provider",
+}
+
+// Условие по дням и валюте для запроса к order_rollups_daily
+func rollupWhere(filter domain.AnalyticsFilter) (string, []any) {
+	from := filter.From.Truncate(24 * time.Hour)
+	to := filter.To.Truncate(24 * time.Hour)
+	if to.Before(filter.To) {
+		to = to.Add(24 * time.Hour)
+	}
+	where := "WHERE day >= $1::date AND day < $2::date"
+	args := []any{from, to}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		where += fmt.Sprintf(" AND currency = $%d", len(args))
+	}
+	return where, args
+}
+
+// Заказы и выручка по периодам и валютам из дневных агрегатов
+func (a *RollupAnalytics) Revenue(ctx context.Context, filter domain.AnalyticsFilter, interval string) ([]domain.RevenuePoint, error) {
+	switch interval {
+	case domain.IntervalDay, domain.IntervalWeek, domain.IntervalMonth:
+	default:
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := rollupWhere(filter)
+	points := []domain.RevenuePoint{}
+	err := selectQuery(ctx, a.db, "rollups.revenue", &points, `
+        SELECT date_trunc('`+interval+`', day)::timestamp AS period, currency,
+               sum(orders)::bigint AS orders, sum(revenue)::bigint AS revenue
+        FROM order_rollups_daily
+        `+where+`
+        GROUP BY 1, 2
+        ORDER BY 1, 2
+    `, args...)
+	return points, err
+}
+
+// Разбивка по службе доставки или провайдеру из агрегатов; банк и entry — из таблиц заказов
+func (a *RollupAnalytics) Breakdown(ctx context.Context, filter domain.AnalyticsFilter, dimension string) ([]domain.BreakdownRow, error) {
+	column, ok := rollupBreakdownColumns[dimension]
+	if !ok {
+		return a.PostgresOrderRepository.Breakdown(ctx, filter, dimension)
+	}
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := rollupWhere(filter)
+	rows := []domain.BreakdownRow{}
+	err := selectQuery(ctx, a.db, "rollups.breakdown", &rows, `
+        SELECT `+column+` AS key, currency,
+               sum(orders)::bigint AS orders, sum(revenue)::bigint AS revenue
+        FROM order_rollups_daily
+        `+where+`
+        GROUP BY 1, 2
+        ORDER BY revenue DESC, 1, 2
+    `, args...)
+	return rows, err
+}
+
+// Сводка по валютам из дневных агрегатов
+func (a *RollupAnalytics) Summary(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.AnalyticsSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, analyticsQueryTimeout)
+	defer cancel()
+
+	where, args := rollupWhere(filter)
+	summary := []domain.AnalyticsSummary{}
+	err := selectQuery(ctx, a.db, "rollups.summary", &summary, `
+        SELECT currency,
+               sum(orders)::bigint AS orders,
+               sum(revenue)::bigint AS revenue,
+               COALESCE(sum(delivery_cost)::float8 / NULLIF(sum(orders), 0), 0) AS avg_delivery_cost,
+               sum(items)::bigint AS items,
+               COALESCE(sum(discounted_items)::float8 / NULLIF(sum(items), 0), 0) AS sale_rate,
+               COALESCE(sum(sale_sum)::float8 / NULLIF(sum(items), 0), 0) AS avg_sale
+        FROM order_rollups_daily
+        `+where+`
+        GROUP BY currency
+        ORDER BY currency
+    `, args...)
+	return summary, err
+}
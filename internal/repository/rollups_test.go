@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Таблица order_rollups_daily в памяти: выполняет upsert и удаление пустых строк из rollups.go
+type memRollups struct {
+	rows  map[rollupDelta]rollupDelta
+	execs int
+}
+
+func newMemRollups() *memRollups {
+	return &memRollups{rows: make(map[rollupDelta]rollupDelta)}
+}
+
+func (m *memRollups) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	m.execs++
+	key := rollupDelta{
+		Day:             args[0].(time.Time),
+		Currency:        args[1].(string),
+		DeliveryService: args[2].(string),
+		Provider:        args[3].(string),
+	}
+	switch {
+	case strings.Contains(query, "INSERT INTO order_rollups_daily"):
+		row, ok := m.rows[key]
+		if !ok {
+			row = key
+		}
+		row.Orders += args[4].(int64)
+		row.Revenue += args[5].(int64)
+		row.DeliveryCost += args[6].(int64)
+		row.Items += args[7].(int64)
+		row.DiscountedItems += args[8].(int64)
+		row.SaleSum += args[9].(int64)
+		m.rows[key] = row
+	case strings.Contains(query, "DELETE FROM order_rollups_daily"):
+		if row, ok := m.rows[key]; ok && row.Orders <= 0 {
+			delete(m.rows, key)
+		}
+	default:
+		return nil, errors.New("unexpected query: " + query)
+	}
+	return driverResult(1), nil
+}
+
+func (m *memRollups) GetContext(context.Context, any, string, ...any) error {
+	return errors.New("not implemented")
+}
+
+func (m *memRollups) SelectContext(context.Context, any, string, ...any) error {
+	return errors.New("not implemented")
+}
+
+// Строка агрегатов с ключом delta; ok = false, если строки нет
+func (m *memRollups) row(d rollupDelta) (rollupDelta, bool) {
+	row, ok := m.rows[d.keyOnly()]
+	return row, ok
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func rollupTestOrder() *domain.Order {
+	return &domain.Order{
+		OrderUID:        "o1",
+		DeliveryService: "meest",
+		DateCreated:     time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC),
+		Payment:         domain.Payment{Currency: "USD", Provider: "wbpay", Amount: 1817, DeliveryCost: 1500},
+		Items:           []domain.Item{{RID: "r1", Sale: 30}, {RID: "r2"}, {RID: "r3", Sale: 10}},
+	}
+}
+
+// Вклад заказа: день без времени, одна строка заказа и счетчики по товарам со скидкой
+func TestOrderRollup(t *testing.T) {
+	got := orderRollup(rollupTestOrder())
+	want := rollupDelta{
+		Day:             time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Currency:        "USD",
+		DeliveryService: "meest",
+		Provider:        "wbpay",
+		Orders:          1,
+		Revenue:         1817,
+		DeliveryCost:    1500,
+		Items:           3,
+		DiscountedItems: 2,
+		SaleSum:         40,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// Разность и отрицание меняют только значения, ключ строки сохраняется
+func TestRollupDelta_Arithmetic(t *testing.T) {
+	d := orderRollup(rollupTestOrder())
+
+	neg := d.negate()
+	if !neg.sameKey(d) || neg.Orders != -1 || neg.Revenue != -1817 || neg.DeliveryCost != -1500 ||
+		neg.Items != -3 || neg.DiscountedItems != -2 || neg.SaleSum != -40 {
+		t.Errorf("unexpected negation: %+v", neg)
+	}
+	if zero := d.sub(d); zero != d.keyOnly() {
+		t.Errorf("d - d must be zero, got %+v", zero)
+	}
+
+	order := rollupTestOrder()
+	order.Payment.Amount = 2000
+	order.Items = order.Items[:1]
+	diff := orderRollup(order).sub(d)
+	want := d.keyOnly()
+	want.Revenue, want.Items, want.DiscountedItems, want.SaleSum = 183, -2, -1, -10
+	if diff != want {
+		t.Errorf("got %+v, want %+v", diff, want)
+	}
+}
+
+// Ключ строки различается по дню, валюте, службе доставки и провайдеру
+func TestRollupDelta_SameKey(t *testing.T) {
+	d := orderRollup(rollupTestOrder())
+	other := d
+	other.Revenue = 1
+	if !d.sameKey(other) {
+		t.Error("values must not affect the key")
+	}
+	day := d
+	day.Day = d.Day.AddDate(0, 0, 1)
+	currency := d
+	currency.Currency = "RUB"
+	service := d
+	service.DeliveryService = "cdek"
+	provider := d
+	provider.Provider = "sbp"
+	for _, changed := range []rollupDelta{day, currency, service, provider} {
+		if d.sameKey(changed) {
+			t.Errorf("%+v must have a different key", changed)
+		}
+	}
+}
+
+// Повторное сохранение с тем же ключом пишет только разность; неизменный заказ не пишет ничего
+func TestUpdateRollups_SameKey(t *testing.T) {
+	ctx := context.Background()
+	db := newMemRollups()
+	order := rollupTestOrder()
+
+	if err := updateRollups(ctx, db, nil, order); err != nil {
+		t.Fatal(err)
+	}
+	stored := orderRollup(order)
+
+	db.execs = 0
+	if err := updateRollups(ctx, db, &stored, order); err != nil {
+		t.Fatal(err)
+	}
+	if db.execs != 0 {
+		t.Errorf("unchanged order must not touch rollups, got %d queries", db.execs)
+	}
+
+	order.Payment.Amount = 2000
+	order.Items = append(order.Items, domain.Item{RID: "r4", Sale: 5})
+	if err := updateRollups(ctx, db, &stored, order); err != nil {
+		t.Fatal(err)
+	}
+	if db.execs != 1 {
+		t.Errorf("expected one upsert, got %d queries", db.execs)
+	}
+	row, ok := db.row(stored)
+	if !ok || row != orderRollup(order) || len(db.rows) != 1 {
+		t.Errorf("unexpected rows: %+v", db.rows)
+	}
+}
+
+// При смене дня, валюты или провайдера вклад переносится в другую строку,
+// а опустевшая старая строка удаляется
+func TestUpdateRollups_KeyChange(t *testing.T) {
+	cases := map[string]func(o *domain.Order){
+		"day":      func(o *domain.Order) { o.DateCreated = o.DateCreated.Add(time.Hour) },
+		"currency": func(o *domain.Order) { o.Payment.Currency = "RUB" },
+		"provider": func(o *domain.Order) { o.Payment.Provider = "sbp" },
+	}
+	for name, change := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newMemRollups()
+			order := rollupTestOrder()
+			if err := updateRollups(ctx, db, nil, order); err != nil {
+				t.Fatal(err)
+			}
+			stored := orderRollup(order)
+
+			change(order)
+			next := orderRollup(order)
+			if next.sameKey(stored) {
+				t.Fatal("change must move the order to another row")
+			}
+			if err := updateRollups(ctx, db, &stored, order); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := db.row(stored); ok {
+				t.Errorf("old row must be pruned: %+v", db.rows)
+			}
+			if row, ok := db.row(next); !ok || row != next || len(db.rows) != 1 {
+				t.Errorf("unexpected rows: %+v", db.rows)
+			}
+		})
+	}
+}
+
+// Перенос из строки, где остаются другие заказы, только уменьшает ее
+func TestUpdateRollups_KeyChangeKeepsOtherOrders(t *testing.T) {
+	ctx := context.Background()
+	db := newMemRollups()
+	first, second := rollupTestOrder(), rollupTestOrder()
+	second.OrderUID = "o2"
+	for _, o := range []*domain.Order{first, second} {
+		if err := updateRollups(ctx, db, nil, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := orderRollup(second)
+
+	second.Payment.Currency = "RUB"
+	if err := updateRollups(ctx, db, &stored, second); err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := db.row(stored); !ok || row != orderRollup(first) {
+		t.Errorf("old row must keep the first order: %+v", row)
+	}
+	if row, ok := db.row(orderRollup(second)); !ok || row.Orders != 1 {
+		t.Errorf("new row must hold the second order: %+v", row)
+	}
+}
+
+// Строка, в которой число заказов стало отрицательным (например, вклад уже убран
+// пересчетом агрегатов), удаляется, а не остается с отрицательными значениями
+func TestApplyRollup_PrunesNegativeRows(t *testing.T) {
+	ctx := context.Background()
+	db := newMemRollups()
+
+	if err := applyRollup(ctx, db, orderRollup(rollupTestOrder()).negate()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.rows) != 0 {
+		t.Errorf("negative row must be pruned: %+v", db.rows)
+	}
+	if db.execs != 2 {
+		t.Errorf("expected upsert and prune, got %d queries", db.execs)
+	}
+
+	db.execs = 0
+	if err := applyRollup(ctx, db, orderRollup(rollupTestOrder()).keyOnly()); err != nil {
+		t.Fatal(err)
+	}
+	if db.execs != 0 {
+		t.Errorf("zero delta must not touch rollups, got %d queries", db.execs)
+	}
+}
+
+// Мягкое удаление убирает вклад заказа, восстановление возвращает его
+func TestApplyRollup_SoftDeleteReversal(t *testing.T) {
+	ctx := context.Background()
+	db := newMemRollups()
+	first, second := rollupTestOrder(), rollupTestOrder()
+	second.OrderUID = "o2"
+	second.Payment.Amount = 100
+	for _, o := range []*domain.Order{first, second} {
+		if err := updateRollups(ctx, db, nil, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, _ := db.row(orderRollup(first))
+	stored := orderRollup(second)
+
+	// Как SoftDelete и Restore в deletion.go
+	if err := applyRollup(ctx, db, stored.negate()); err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := db.row(stored); !ok || row != orderRollup(first) {
+		t.Errorf("deleted order must be subtracted: %+v", row)
+	}
+	if err := applyRollup(ctx, db, stored); err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := db.row(stored); row != before {
+		t.Errorf("restored rollup %+v, want %+v", row, before)
+	}
+
+	// Удаление единственного заказа дня убирает строку целиком
+	if err := applyRollup(ctx, db, stored.negate()); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyRollup(ctx, db, orderRollup(first).negate()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.rows) != 0 {
+		t.Errorf("empty row must be pruned: %+v", db.rows)
+	}
+}
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/encryption"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Интерфейс для хранения подписок на webhook и журнала доставок
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, sub *domain.WebhookSubscription) (bool, error)
+	DeleteSubscription(ctx context.Context, id int64) (bool, error)
+
+	// Захватывает готовые к отправке доставки на время lease; в очередь они ставятся
+	// при сохранении заказа вместе с событием outbox
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, d *domain.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id int64) (*domain.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]domain.WebhookDelivery, error)
+	// Возвращает доставку в очередь для повторной отправки
+	RequeueDelivery(ctx context.Context, id int64) (bool, error)
+}
+
+// Хранилище webhook в PostgreSQL
+type PostgresWebhookRepository struct {
+	db *sqlx.DB
+	// Расшифровка заказа в payload доставок; nil — payload отдается как есть
+	keyring *encryption.Keyring
+}
+
+// Создание нового хранилища webhook; keyring может быть nil
+func NewPostgresWebhookRepository(db *sqlx.DB, keyring *encryption.Keyring) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{db: db, keyring: keyring}
+}
+
+// Строка таблицы webhook_subscriptions
+type subscriptionRow struct {
+	ID              int64          `db:"id"`
+	URL             string         `db:"url"`
+	Secret          string         `db:"secret"`
+	Entry           string         `db:"entry"`
+	DeliveryService string         `db:"delivery_service"`
+	EventTypes      pq.StringArray `db:"event_types"`
+	Active          bool           `db:"active"`
+	CreatedAt       time.Time      `db:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at"`
+}
+
+func (row subscriptionRow) toDomain() *domain.WebhookSubscription {
+	return &domain.WebhookSubscription{
+		ID:              row.ID,
+		URL:             row.URL,
+		Secret:          row.Secret,
+		Entry:           row.Entry,
+		DeliveryService: row.DeliveryService,
+		EventTypes:      []string(row.EventTypes),
+		Active:          row.Active,
+		CreatedAt:       row.CreatedAt,
+		UpdatedAt:       row.UpdatedAt,
+	}
+}
+
+const subscriptionColumns = `id, url, secret, entry, delivery_service, event_types, active, created_at, updated_at`
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, order_uid, payload, status,
+        attempts, last_error, response_code, next_attempt_at, created_at, delivered_at`
+
+// Создает подписку и заполняет ее ID и даты
+func (r *PostgresWebhookRepository) CreateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var row subscriptionRow
+	err := getQuery(ctx, r.db, "webhook_subscriptions.create", &row, `
+        INSERT INTO webhook_subscriptions (url, secret, entry, delivery_service, event_types, active)
+        VALUES ($1,$2,$3,$4,$5,$6)
+        RETURNING `+subscriptionColumns,
+		sub.URL, sub.Secret, sub.Entry, sub.DeliveryService, pq.StringArray(sub.EventTypes), sub.Active)
+	if err != nil {
+		return err
+	}
+	*sub = *row.toDomain()
+	return nil
+}
+
+// Получение подписки по ID; nil, если подписки нет
+func (r *PostgresWebhookRepository) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var row subscriptionRow
+	err := getQuery(ctx, r.db, "webhook_subscriptions.get", &row, `
+        SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id = $1
+    `, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return row.toDomain(), nil
+}
+
+// Все подписки
+func (r *PostgresWebhookRepository) ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var rows []subscriptionRow
+	err := selectQuery(ctx, r.db, "webhook_subscriptions.list", &rows, `
+        SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptionsToDomain(rows), nil
+}
+
+// Обновляет подписку; false, если подписки нет
+func (r *PostgresWebhookRepository) UpdateSubscription(ctx context.Context, sub *domain.WebhookSubscription) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var row subscriptionRow
+	err := getQuery(ctx, r.db, "webhook_subscriptions.update", &row, `
+        UPDATE webhook_subscriptions
+        SET url = $2, secret = $3, entry = $4, delivery_service = $5,
+            event_types = $6, active = $7, updated_at = now()
+        WHERE id = $1
+        RETURNING `+subscriptionColumns,
+		sub.ID, sub.URL, sub.Secret, sub.Entry, sub.DeliveryService, pq.StringArray(sub.EventTypes), sub.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	*sub = *row.toDomain()
+	return true, nil
+}
+
+// Удаляет подписку вместе с журналом доставок; false, если подписки нет
+func (r *PostgresWebhookRepository) DeleteSubscription(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := execQuery(ctx, r.db, "webhook_subscriptions.delete", `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func subscriptionsToDomain(rows []subscriptionRow) []*domain.WebhookSubscription {
+	subs := make([]*domain.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, row.toDomain())
+	}
+	return subs
+}
+
+// Захватывает доставки, время которых пришло, сдвигая их следующую попытку на lease,
+// чтобы другие реплики не отправили их одновременно
+func (r *PostgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var deliveries []domain.WebhookDelivery
+	err := selectQuery(ctx, r.db, "webhook_deliveries.claim", &deliveries, `
+        UPDATE webhook_deliveries
+        SET next_attempt_at = now() + make_interval(secs => $2)
+        WHERE id IN (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= now()
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING `+deliveryColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.openDeliveries(deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Расшифровывает заказ в payload доставок
+func (r *PostgresWebhookRepository) openDeliveries(deliveries []domain.WebhookDelivery) error {
+	for i := range deliveries {
+		payload, err := openEvent(r.keyring, deliveries[i].OrderUID, deliveries[i].Payload)
+		if err != nil {
+			return err
+		}
+		deliveries[i].Payload = payload
+	}
+	return nil
+}
+
+// Сохраняет результат попытки доставки
+func (r *PostgresWebhookRepository) RecordAttempt(ctx context.Context, d *domain.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := execQuery(ctx, r.db, "webhook_deliveries.record", `
+        UPDATE webhook_deliveries
+        SET status = $2, attempts = $3, last_error = $4, response_code = $5,
+            next_attempt_at = $6, delivered_at = $7
+        WHERE id = $1
+    `, d.ID, d.Status, d.Attempts, d.LastError, d.ResponseCode, d.NextAttemptAt, d.DeliveredAt)
+	return err
+}
+
+// Получение доставки по ID; nil, если доставки нет
+func (r *PostgresWebhookRepository) GetDelivery(ctx context.Context, id int64) (*domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var d domain.WebhookDelivery
+	err := getQuery(ctx, r.db, "webhook_deliveries.get", &d, `
+        SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1
+    `, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if d.Payload, err = openEvent(r.keyring, d.OrderUID, d.Payload); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Журнал доставок подписки, новые первыми
+func (r *PostgresWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	deliveries := []domain.WebhookDelivery{}
+	err := selectQuery(ctx, r.db, "webhook_deliveries.list", &deliveries, `
+        SELECT `+deliveryColumns+` FROM webhook_deliveries
+        WHERE subscription_id = $1
+        ORDER BY id DESC
+        LIMIT $2
+    `, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.openDeliveries(deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Возвращает доставку в очередь с обнуленным счетчиком попыток; false, если доставки нет
+func (r *PostgresWebhookRepository) RequeueDelivery(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := execQuery(ctx, r.db, "webhook_deliveries.requeue", `
+        UPDATE webhook_deliveries
+        SET status = 'pending', attempts = 0, next_attempt_at = now()
+        WHERE id = $1
+    `, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
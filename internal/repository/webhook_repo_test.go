@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/encryption"
+)
+
+// Журнал доставок отдает заказ в payload открытым, как и очередь доставок
+func TestOpenDeliveries(t *testing.T) {
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &PostgresWebhookRepository{keyring: keyring}
+
+	plain := []byte(`{"type":"order.created","order":{"order_uid":"o1"}}`)
+	sealed, err := sealEvent(keyring, "o1", plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries := []domain.WebhookDelivery{
+		{ID: 1, OrderUID: "o1", Payload: sealed},
+		// Заказ удаленного клиента в payload заменен на null
+		{ID: 2, OrderUID: "o2", Payload: []byte(`{"type":"order.created","order":null}`)},
+	}
+	if err := r.openDeliveries(deliveries); err != nil {
+		t.Fatal(err)
+	}
+
+	var event struct {
+		Order struct {
+			OrderUID string `json:"order_uid"`
+		} `json:"order"`
+	}
+	if err := json.Unmarshal(deliveries[0].Payload, &event); err != nil || event.Order.OrderUID != "o1" {
+		t.Errorf("payload not decrypted: %s", deliveries[0].Payload)
+	}
+	if string(deliveries[1].Payload) != `{"type":"order.created","order":null}` {
+		t.Errorf("erased payload must be kept: %s", deliveries[1].Payload)
+	}
+
+	// Шифротекст привязан к заказу: чужой order_uid не расшифровывается
+	wrong := []domain.WebhookDelivery{{ID: 3, OrderUID: "o2", Payload: sealed}}
+	if err := r.openDeliveries(wrong); err == nil {
+		t.Error("payload of another order must not be decrypted")
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Tommych123/L0-WB/internal/archive"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Удаление персональных данных из архивов партиций в каталоге dir
+type ArchiveEraser struct {
+	dir string
+}
+
+// Создание обработчика архивов каталога dir
+func NewArchiveEraser(dir string) *ArchiveEraser {
+	return &ArchiveEraser{dir: dir}
+}
+
+// Ключи строки архива, по которым ищутся заказы клиента
+type archiveRowKey struct {
+	OrderUID   string `json:"order_uid"`
+	CustomerID string `json:"customer_id"`
+}
+
+// order_uid заказов клиента во всех архивах
+func (e *ArchiveEraser) FindCustomer(ctx context.Context, customerID string) ([]string, error) {
+	dirs, err := archive.List(e.dir)
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		src, err := archive.Open(dir)
+		if err != nil {
+			return nil, err
+		}
+		err = src.Rows("orders", func(row []byte) error {
+			var key archiveRowKey
+			if err := json.Unmarshal(row, &key); err != nil {
+				return err
+			}
+			if key.CustomerID == customerID {
+				uids = append(uids, key.OrderUID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return uids, nil
+}
+
+// Обезличивает строки заказов uids во всех таблицах архивов; возвращает число переписанных архивов
+func (e *ArchiveEraser) Erase(ctx context.Context, uids []string) (int, error) {
+	dirs, err := archive.List(e.dir)
+	if err != nil || len(uids) == 0 {
+		return 0, err
+	}
+	erase := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		erase[uid] = true
+	}
+
+	rewritten := 0
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return rewritten, err
+		}
+		// Строки связанных таблиц есть только у заказов архива, поэтому сначала проверяются заказы
+		found, err := containsOrders(dir, erase)
+		if err != nil {
+			return rewritten, err
+		}
+		if !found {
+			continue
+		}
+		changed, err := archive.Rewrite(dir, func(table string, row []byte) ([]byte, error) {
+			var key archiveRowKey
+			if err := json.Unmarshal(row, &key); err != nil {
+				return nil, err
+			}
+			if !erase[key.OrderUID] {
+				return row, nil
+			}
+			return repository.AnonymizeArchiveRow(table, row)
+		})
+		if err != nil {
+			return rewritten, err
+		}
+		if changed {
+			rewritten++
+		}
+	}
+	return rewritten, nil
+}
+
+// Есть ли в архиве dir заказ из uids
+func containsOrders(dir string, uids map[string]bool) (bool, error) {
+	src, err := archive.Open(dir)
+	if err != nil {
+		return false, err
+	}
+	found := false
+	err = src.Rows("orders", func(row []byte) error {
+		var key archiveRowKey
+		if err := json.Unmarshal(row, &key); err != nil {
+			return err
+		}
+		found = found || uids[key.OrderUID]
+		return nil
+	})
+	return found, err
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/archive"
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Архив месяца со строками заказов клиентов c1 (o1) и c2 (o2)
+func writeCustomerArchive(t *testing.T, root string) string {
+	t.Helper()
+	w, err := archive.Create(root, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct{ table, row string }{
+		{"orders", `{"order_uid":"o1","customer_id":"c1","track_number":"T1","internal_signature":"s"}`},
+		{"orders", `{"order_uid":"o2","customer_id":"c2","track_number":"T2","internal_signature":""}`},
+		{"delivery", `{"order_uid":"o1","name":"Ivan","phone":"+7900","zip":"1","city":"Moscow","address":"Lenina 1","region":"MO","email":"i@x.ru","email_bidx":"e","phone_bidx":"p"}`},
+		{"delivery", `{"order_uid":"o2","name":"Petr","phone":"+7901","zip":"2","city":"Tver","address":"Mira 2","region":"TV","email":"p@x.ru","email_bidx":null,"phone_bidx":null}`},
+		{"payment", `{"order_uid":"o1","transaction":"tx1","request_id":"r","amount":100}`},
+		{"items", `{"order_uid":"o1","rid":"i1","track_number":"T1","price":100}`},
+	}
+	for _, r := range rows {
+		if err := w.WriteRow(r.table, []byte(r.row)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return w.Dir()
+}
+
+func archiveRows(t *testing.T, dir, table string) map[string]map[string]any {
+	t.Helper()
+	src, err := archive.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]map[string]any)
+	if err := src.Rows(table, func(row []byte) error {
+		var fields map[string]any
+		if err := json.Unmarshal(row, &fields); err != nil {
+			return err
+		}
+		rows[fields["order_uid"].(string)] = fields
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+// Заказы клиента в архиве обезличиваются так же, как в БД; чужие заказы не меняются
+func TestArchiveEraser_Erase(t *testing.T) {
+	root := t.TempDir()
+	dir := writeCustomerArchive(t, root)
+	e := NewArchiveEraser(root)
+	ctx := context.Background()
+
+	uids, err := e.FindCustomer(ctx, "c1")
+	if err != nil || len(uids) != 1 || uids[0] != "o1" {
+		t.Fatalf("FindCustomer: %v %v", uids, err)
+	}
+	n, err := e.Erase(ctx, uids)
+	if err != nil || n != 1 {
+		t.Fatalf("Erase: %d %v", n, err)
+	}
+
+	orders := archiveRows(t, dir, "orders")
+	if o := orders["o1"]; o["customer_id"] != "erased" || o["track_number"] != "" || o["internal_signature"] != "" {
+		t.Errorf("order not anonymized: %v", o)
+	}
+	if o := orders["o2"]; o["customer_id"] != "c2" || o["track_number"] != "T2" {
+		t.Errorf("other customer must be kept: %v", o)
+	}
+	delivery := archiveRows(t, dir, "delivery")
+	d := delivery["o1"]
+	for _, column := range []string{"name", "phone", "zip", "address", "email"} {
+		if d[column] != "" {
+			t.Errorf("delivery %s not erased: %v", column, d[column])
+		}
+	}
+	if d["email_bidx"] != nil || d["phone_bidx"] != nil || d["city"] != "Moscow" {
+		t.Errorf("unexpected delivery: %v", d)
+	}
+	if delivery["o2"]["name"] != "Petr" {
+		t.Errorf("other delivery must be kept: %v", delivery["o2"])
+	}
+	if p := archiveRows(t, dir, "payment")["o1"]; p["transaction"] != "" || p["request_id"] != "" || p["amount"] != float64(100) {
+		t.Errorf("unexpected payment: %v", p)
+	}
+	if i := archiveRows(t, dir, "items")["o1"]; i["track_number"] != "" || i["rid"] != "i1" {
+		t.Errorf("unexpected item: %v", i)
+	}
+
+	// Повторное удаление ничего не находит и архивы не переписывает
+	if uids, err := e.FindCustomer(ctx, "c1"); err != nil || len(uids) != 0 {
+		t.Errorf("erased customer must not be found: %v %v", uids, err)
+	}
+	if n, err := e.Erase(ctx, []string{"o1"}); err != nil || n != 0 {
+		t.Errorf("second erase: %d %v", n, err)
+	}
+}
+
+// Клиент, чьи заказы есть только в архивах, тоже удаляется; их order_uid передаются в аудит
+func TestEraseCustomer_ArchivedOnly(t *testing.T) {
+	root := t.TempDir()
+	dir := writeCustomerArchive(t, root)
+	var audited []string
+	s := NewOrderService(&mockRepo{
+		eraseFn: func(customerID string, archived []string) (*domain.ErasureRecord, error) {
+			audited = archived
+			return &domain.ErasureRecord{ID: 1, OrderUIDs: archived}, nil
+		},
+	})
+	s.UseArchives(NewArchiveEraser(root))
+
+	record, err := s.EraseCustomer(context.Background(), "c1", "ops", "request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audited) != 1 || audited[0] != "o1" || len(record.OrderUIDs) != 1 {
+		t.Errorf("archived orders must be audited: %v", audited)
+	}
+	if o := archiveRows(t, dir, "orders")["o1"]; o["customer_id"] != "erased" {
+		t.Errorf("archived order not anonymized: %v", o)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Выгружает все заказы клиента без скрытия персональных данных, включая мягко удаленные
+// (они перечислены в DeletedOrderUIDs); клиент без заказов — ErrNotFound
+func (s *OrderService) ExportCustomer(ctx context.Context, customerID string) (_ *domain.CustomerExport, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.ExportCustomer")
+	defer func() { tracing.End(span, err) }()
+
+	orders, err := s.repo.GetByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, StorageError(err)
+	}
+	if len(orders) == 0 {
+		return nil, NotFound(CodeCustomerNotFound, "customer not found")
+	}
+	span.SetAttributes(attribute.Int("customer.orders", len(orders)))
+
+	export := &domain.CustomerExport{
+		CustomerID: customerID,
+		ExportedAt: time.Now().UTC(),
+		Orders:     orders,
+	}
+	for _, order := range orders {
+		if order.Deleted {
+			export.DeletedOrderUIDs = append(export.DeletedOrderUIDs, order.OrderUID)
+		}
+	}
+	return export, nil
+}
+
+// Обезличивает заказы клиента в БД и архивах партиций и убирает их из кэша; другие
+// экземпляры очищают кэш по уведомлению из БД. Следующее чтение загрузит обезличенный
+// заказ из БД. Архивы переписываются после коммита в БД: архив партиции, выгружаемой
+// параллельно, к этому моменту уже записан. Клиент без заказов — ErrNotFound
+func (s *OrderService) EraseCustomer(ctx context.Context, customerID, requestedBy, reason string) (_ *domain.ErasureRecord, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.EraseCustomer")
+	defer func() { tracing.End(span, err) }()
+
+	var archived []string
+	if s.archives != nil {
+		if archived, err = s.archives.FindCustomer(ctx, customerID); err != nil {
+			return nil, err
+		}
+	}
+	record, err := s.repo.EraseCustomer(ctx, customerID, archived, requestedBy, reason)
+	if err != nil {
+		return nil, StorageError(err)
+	}
+	if record == nil {
+		return nil, NotFound(CodeCustomerNotFound, "customer not found")
+	}
+	if s.archives != nil {
+		// При ошибке запрос можно повторить: необработанные архивы найдутся снова
+		rewritten, err := s.archives.Erase(ctx, record.OrderUIDs)
+		if err != nil {
+			return nil, err
+		}
+		span.SetAttributes(attribute.Int("erasure.archives", rewritten))
+	}
+	span.SetAttributes(attribute.Int("customer.orders", len(record.OrderUIDs)),
+		attribute.Int64("erasure.id", record.ID))
+
+	s.evict(record.OrderUIDs)
+
+	// customer_id в лог не пишется, только необратимая ссылка из аудита
+	slog.InfoContext(ctx, "customer data erased",
+		"erasure_id", record.ID, "customer_ref", record.CustomerRef,
+		"orders", len(record.OrderUIDs), logger.KeySubject, requestedBy)
+	return record, nil
+}
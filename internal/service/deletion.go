@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Помечает заказ удаленным и убирает его из кэша; другие экземпляры получают
+// уведомление из БД. Отсутствующий заказ — ErrNotFound
+func (s *OrderService) DeleteOrder(ctx context.Context, id, deletedBy, reason string) (_ *domain.DeletedOrder, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.DeleteOrder",
+		trace.WithAttributes(attribute.String("order.uid", id)))
+	defer func() { tracing.End(span, err) }()
+
+	deleted, err := s.repo.SoftDelete(ctx, id, deletedBy, reason)
+	if err != nil {
+		return nil, StorageError(err)
+	}
+	if deleted == nil {
+		return nil, NotFound(CodeOrderNotFound, "order not found")
+	}
+	s.evict([]string{id})
+
+	slog.InfoContext(ctx, "order deleted", logger.KeyOrderUID, id, logger.KeySubject, deletedBy)
+	return deleted, nil
+}
+
+// Удаленные заказы, последние удаленные первыми
+func (s *OrderService) ListDeletedOrders(ctx context.Context, limit int) ([]domain.DeletedOrder, error) {
+	deleted, err := s.repo.ListDeleted(ctx, limit)
+	if err != nil {
+		return nil, StorageError(err)
+	}
+	return deleted, nil
+}
+
+// Восстанавливает удаленный заказ и возвращает его. Заказ, который не удален
+// или уже удален окончательно, — ErrNotFound
+func (s *OrderService) RestoreOrder(ctx context.Context, id, restoredBy string) (_ *domain.Order, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.RestoreOrder",
+		trace.WithAttributes(attribute.String("order.uid", id)))
+	defer func() { tracing.End(span, err) }()
+
+	restored, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return nil, StorageError(err)
+	}
+	if !restored {
+		return nil, NotFound(CodeDeletedOrderNotFound, "deleted order not found")
+	}
+	slog.InfoContext(ctx, "order restored", logger.KeyOrderUID, id, logger.KeySubject, restoredBy)
+
+	return s.GetOrder(ctx, id)
+}
+
+// Убирает заказ из кэша по уведомлению другого экземпляра
+func (s *OrderService) EvictCached(id string) {
+	s.evict([]string{id})
+}
+
+// Очищает кэш, когда уведомления могли быть потеряны; заказы загружаются из БД при чтении
+func (s *OrderService) ResetCache() {
+	s.mu.Lock()
+	clear(s.cache)
+	s.gen++
+	s.resetAt = s.gen
+	s.mu.Unlock()
+	slog.Info("order cache reset")
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Категории ошибок сервиса; проверяются через errors.Is
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrUnavailable  = errors.New("unavailable")
+	ErrConflict     = errors.New("conflict")
+)
+
+// Стабильные коды ошибок сервиса, которые видит клиент
+const (
+	CodeOrderNotFound        = "order_not_found"
+	CodeCustomerNotFound     = "customer_not_found"
+	CodeDeletedOrderNotFound = "deleted_order_not_found"
+	CodeInvalidOrder         = "invalid_order"
+	CodeWriteConflict        = "write_conflict"
+	CodeItemRIDConflict      = "item_rid_conflict"
+	CodeStorageUnavailable   = "storage_unavailable"
+)
+
+// Ошибка сервиса: категория, стабильный код и безопасное для клиента сообщение.
+// Клиенту сообщение переводится по коду, Args — параметры перевода.
+// Причина Err попадает только в логи
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Args    []any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// Объект не найден
+func NotFound(code, message string) error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+// Некорректные входные данные; сообщение должно быть понятно клиенту
+func InvalidInput(code, message string, err error) error {
+	return &Error{Kind: ErrInvalidInput, Code: code, Message: message, Err: err}
+}
+
+// Зависимость недоступна, запрос можно повторить позже
+func Unavailable(code string, err error) error {
+	return &Error{Kind: ErrUnavailable, Code: code, Message: "service is temporarily unavailable", Err: err}
+}
+
+// Конфликт с текущим состоянием данных
+func Conflict(code, message string, err error) error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message, Err: err}
+}
+
+// Приводит ошибку репозитория к ошибке сервиса; неклассифицированные
+// ошибки возвращаются как есть и считаются внутренними
+func StorageError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrItemRIDTaken):
+		return Conflict(CodeItemRIDConflict, "order item rid belongs to another order", err)
+	case errors.Is(err, repository.ErrConflict):
+		return Conflict(CodeWriteConflict, "data was modified concurrently, retry the request", err)
+	case errors.Is(err, repository.ErrUnavailable):
+		return Unavailable(CodeStorageUnavailable, err)
+	}
+	return err
+}
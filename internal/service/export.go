@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Передает в fn заказы, созданные в [from, to), прямо из БД без кэша.
+// Ошибка fn возвращается без изменений, ошибки хранилища — через StorageError
+func (s *OrderService) ExportOrders(ctx context.Context, from, to time.Time, fn func(*domain.Order) error) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.ExportOrders")
+	defer func() { tracing.End(span, err) }()
+
+	exported := 0
+	var fnErr error
+	err = s.repo.StreamOrders(ctx, from, to, func(order *domain.Order) error {
+		if fnErr = fn(order); fnErr != nil {
+			return fnErr
+		}
+		exported++
+		return nil
+	})
+	span.SetAttributes(attribute.Int("orders.exported", exported))
+	if err != nil && fnErr == nil {
+		return StorageError(err)
+	}
+	return err
+}
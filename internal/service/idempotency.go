@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Удаляет ключи идемпотентности старше ttl
+type IdempotencyCleaner struct {
+	repo     repository.IdempotencyRepository
+	ttl      time.Duration
+	interval time.Duration
+	now      func() time.Time
+}
+
+// Создание задачи удаления устаревших ключей идемпотентности
+func NewIdempotencyCleaner(repo repository.IdempotencyRepository, ttl, interval time.Duration) *IdempotencyCleaner {
+	return &IdempotencyCleaner{repo: repo, ttl: ttl, interval: interval, now: time.Now}
+}
+
+// Удаляет устаревшие ключи каждые interval до отмены контекста
+func (c *IdempotencyCleaner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := c.RunOnce(ctx); err != nil {
+				slog.ErrorContext(ctx, "idempotency keys cleanup error", logger.KeyError, err)
+			}
+		}
+	}
+}
+
+// Удаляет ключи, созданные раньше now - ttl; возвращает их число
+func (c *IdempotencyCleaner) RunOnce(ctx context.Context) (int64, error) {
+	n, err := c.repo.DeleteIdempotencyKeys(ctx, c.now().Add(-c.ttl))
+	if err != nil {
+		return 0, StorageError(err)
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "idempotency keys deleted", "keys", n)
+	}
+	return n, nil
+}
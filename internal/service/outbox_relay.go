@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Публикатор событий во внешнюю шину
+type EventPublisher interface {
+	PublishEvents(ctx context.Context, events []domain.OutboxEvent) error
+}
+
+// Как часто удаляются опубликованные события
+const outboxPruneInterval = time.Hour
+
+// Переносит события из таблицы outbox во внешнюю шину.
+// Доставка at-least-once: событие отмечается опубликованным только после успешной отправки
+type OutboxRelay struct {
+	repo      repository.OutboxRepository
+	publisher EventPublisher
+	interval  time.Duration
+	batchSize int
+	// Сколько хранятся опубликованные события; 0 — хранятся всегда
+	retention time.Duration
+	now       func() time.Time
+}
+
+// Создание нового relay
+func NewOutboxRelay(repo repository.OutboxRepository, publisher EventPublisher, interval time.Duration, batchSize int, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Периодически публикует накопленные события до отмены контекста
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Выбираем пакеты, пока очередь не опустеет
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "outbox relay error", logger.KeyError, err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		if r.retention > 0 && r.now().Sub(lastPrune) >= outboxPruneInterval {
+			lastPrune = r.now()
+			if _, err := r.Prune(ctx); err != nil {
+				slog.ErrorContext(ctx, "outbox prune error", logger.KeyError, err)
+			}
+		}
+	}
+}
+
+// Публикует один пакет событий и возвращает число опубликованных
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	return r.repo.PublishOutbox(ctx, r.batchSize, func(events []domain.OutboxEvent) int {
+		// Пакет отправляется целиком: при ошибке он будет повторен в том же порядке
+		if err := r.publisher.PublishEvents(ctx, events); err != nil {
+			slog.ErrorContext(ctx, "error publishing outbox events", "count", len(events), logger.KeyError, err)
+			return 0
+		}
+		return len(events)
+	})
+}
+
+// Удаляет события, опубликованные раньше now - retention; возвращает их число
+func (r *OutboxRelay) Prune(ctx context.Context) (int64, error) {
+	n, err := r.repo.PruneOutbox(ctx, r.now().Add(-r.retention))
+	if err != nil {
+		return 0, StorageError(err)
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "published outbox events pruned", "events", n)
+	}
+	return n, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// --- mocks ---
+type mockOutbox struct {
+	pending     []domain.OutboxEvent
+	published   []domain.OutboxEvent
+	prunedUntil time.Time
+}
+
+func (m *mockOutbox) PublishOutbox(ctx context.Context, limit int, publish func(events []domain.OutboxEvent) int) (int, error) {
+	batch := m.pending
+	if len(batch) > limit {
+		batch = batch[:limit]
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	n := publish(batch)
+	m.published = append(m.published, batch[:n]...)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+func (m *mockOutbox) PruneOutbox(ctx context.Context, before time.Time) (int64, error) {
+	m.prunedUntil = before
+	return int64(len(m.published)), nil
+}
+
+type mockPublisher struct {
+	err  error
+	sent []domain.OutboxEvent
+}
+
+func (m *mockPublisher) PublishEvents(ctx context.Context, events []domain.OutboxEvent) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, events...)
+	return nil
+}
+
+// --- Тесты ---
+
+// События публикуются в порядке записи
+func TestOutboxRelay_PublishesInOrder(t *testing.T) {
+	repo := &mockOutbox{pending: []domain.OutboxEvent{{ID: 1}, {ID: 2}, {ID: 3}}}
+	pub := &mockPublisher{}
+	relay := NewOutboxRelay(repo, pub, 0, 2, 0)
+
+	if n, err := relay.RelayOnce(context.Background()); err != nil || n != 2 {
+		t.Fatalf("unexpected result: %d, %v", n, err)
+	}
+	if n, _ := relay.RelayOnce(context.Background()); n != 1 {
+		t.Fatalf("expected 1 remaining event, got %d", n)
+	}
+	for i, e := range pub.sent {
+		if e.ID != int64(i+1) {
+			t.Errorf("event %d published out of order: %d", i, e.ID)
+		}
+	}
+}
+
+// При ошибке публикации события остаются в outbox
+func TestOutboxRelay_KeepsEventsOnError(t *testing.T) {
+	repo := &mockOutbox{pending: []domain.OutboxEvent{{ID: 1}}}
+	relay := NewOutboxRelay(repo, &mockPublisher{err: errors.New("kafka down")}, 0, 10, 0)
+
+	if n, _ := relay.RelayOnce(context.Background()); n != 0 {
+		t.Fatalf("expected nothing published, got %d", n)
+	}
+	if len(repo.pending) != 1 {
+		t.Errorf("event must stay pending after failed publish")
+	}
+}
+
+// Удаляются события, опубликованные раньше срока хранения
+func TestOutboxRelay_Prune(t *testing.T) {
+	repo := &mockOutbox{}
+	relay := NewOutboxRelay(repo, &mockPublisher{}, 0, 10, 24*time.Hour)
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	relay.now = func() time.Time { return now }
+
+	if _, err := relay.Prune(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := now.Add(-24 * time.Hour); !repo.prunedUntil.Equal(want) {
+		t.Errorf("pruned until %v, want %v", repo.prunedUntil, want)
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Сколько заказов окончательно удаляется одним запросом
+const purgeBatchSize = 500
+
+// Окончательно удаляет заказы через grace после мягкого удаления
+type Purger struct {
+	repo     repository.OrderRepository
+	grace    time.Duration
+	interval time.Duration
+	now      func() time.Time
+}
+
+// Создание задачи окончательного удаления
+func NewPurger(repo repository.OrderRepository, grace, interval time.Duration) *Purger {
+	return &Purger{repo: repo, grace: grace, interval: interval, now: time.Now}
+}
+
+// Удаляет заказы с истекшим периодом ожидания каждые interval до отмены контекста
+func (p *Purger) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := p.RunOnce(ctx); err != nil {
+				slog.ErrorContext(ctx, "deleted orders purge error", logger.KeyError, err)
+			}
+		}
+	}
+}
+
+// Удаляет пачками все заказы, удаленные раньше now - grace; возвращает их число
+func (p *Purger) RunOnce(ctx context.Context) (int, error) {
+	before := p.now().Add(-p.grace)
+	purged := 0
+	for {
+		uids, err := p.repo.PurgeDeleted(ctx, before, purgeBatchSize)
+		purged += len(uids)
+		if err != nil {
+			return purged, StorageError(err)
+		}
+		if len(uids) > 0 {
+			slog.InfoContext(ctx, "deleted orders purged", "orders", len(uids))
+		}
+		if len(uids) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
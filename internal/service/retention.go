@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/archive"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// На сколько месяцев вперед заранее создаются партиции
+const partitionsAhead = 2
+
+// Поддерживает месячные партиции заказов: создает партиции наперед и переносит
+// партиции старше maxMonths месяцев в архивы NDJSON в каталоге dir
+type Retention struct {
+	repo     repository.PartitionRepository
+	orders   *OrderService
+	dir      string
+	interval time.Duration
+	// Полных месяцев, которые хранятся в БД до текущего; 0 выключает архивацию
+	maxMonths int
+	now       func() time.Time
+}
+
+// Создание задачи обслуживания партиций
+func NewRetention(repo repository.PartitionRepository, orders *OrderService, dir string, maxMonths int, interval time.Duration) *Retention {
+	return &Retention{
+		repo:      repo,
+		orders:    orders,
+		dir:       dir,
+		interval:  interval,
+		maxMonths: maxMonths,
+		now:       time.Now,
+	}
+}
+
+// Обслуживает партиции сразу и затем с периодом interval до отмены контекста
+func (r *Retention) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil {
+			slog.ErrorContext(ctx, "partition retention error", logger.KeyError, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Создает партиции наперед и архивирует устаревшие; возвращает число архивированных месяцев
+func (r *Retention) RunOnce(ctx context.Context) (int, error) {
+	current := monthStart(r.now().UTC())
+	if err := r.repo.EnsurePartitions(ctx, current, current.AddDate(0, partitionsAhead, 0)); err != nil {
+		return 0, err
+	}
+	if r.maxMonths <= 0 {
+		return 0, nil
+	}
+
+	cutoff := current.AddDate(0, -r.maxMonths, 0)
+	months, err := r.repo.ListPartitions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	archived := 0
+	for _, month := range months {
+		if !month.Before(cutoff) {
+			break
+		}
+		if err := r.archiveMonth(ctx, month); err != nil {
+			return archived, err
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// Архивирует партиции месяца и убирает его заказы из кэша; кэши других экземпляров
+// очищаются по уведомлению из БД
+func (r *Retention) archiveMonth(ctx context.Context, month time.Time) error {
+	start := time.Now()
+	w, err := archive.Create(r.dir, month)
+	if err != nil {
+		return err
+	}
+	uids, err := r.repo.ArchivePartition(ctx, month, w)
+	if err != nil {
+		if abortErr := w.Abort(); abortErr != nil {
+			slog.ErrorContext(ctx, "error removing incomplete archive", "path", w.Dir(), logger.KeyError, abortErr)
+		}
+		return err
+	}
+	r.orders.evict(uids)
+
+	slog.InfoContext(ctx, "partition archived",
+		"month", month.Format("2006-01"), "orders", len(uids), "path", w.Dir(),
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
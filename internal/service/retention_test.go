@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+type mockPartitions struct {
+	months      []time.Time
+	ensuredFrom time.Time
+	ensuredTo   time.Time
+	archived    []time.Time
+}
+
+func (m *mockPartitions) EnsurePartitions(ctx context.Context, from, to time.Time) error {
+	m.ensuredFrom, m.ensuredTo = from, to
+	return nil
+}
+
+func (m *mockPartitions) ListPartitions(ctx context.Context) ([]time.Time, error) {
+	return m.months, nil
+}
+
+func (m *mockPartitions) ArchivePartition(ctx context.Context, month time.Time, w repository.ArchiveWriter) ([]string, error) {
+	m.archived = append(m.archived, month)
+	if err := w.WriteRow("orders", []byte(`{"order_uid":"old"}`)); err != nil {
+		return nil, err
+	}
+	return []string{"old"}, w.Commit()
+}
+
+func (m *mockPartitions) RestoreArchive(ctx context.Context, month time.Time, r repository.ArchiveReader) (*repository.RestoreResult, error) {
+	return nil, nil
+}
+
+// Архивируются только месяцы старше maxMonths; их заказы уходят из кэша
+func TestRetention_RunOnce(t *testing.T) {
+	month := func(y int, m time.Month) time.Time { return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC) }
+	parts := &mockPartitions{months: []time.Time{month(2024, 12), month(2025, 1), month(2025, 2), month(2025, 3)}}
+	orders := NewOrderService(&mockRepo{})
+	orders.cache["old"] = &domain.Order{OrderUID: "old"}
+	orders.cache["new"] = &domain.Order{OrderUID: "new"}
+
+	r := NewRetention(parts, orders, t.TempDir(), 2, time.Hour)
+	r.now = func() time.Time { return time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC) }
+
+	n, err := r.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(parts.archived) != 1 || !parts.archived[0].Equal(month(2024, 12)) {
+		t.Fatalf("expected only 2024-12 archived, got %v", parts.archived)
+	}
+	if !parts.ensuredFrom.Equal(month(2025, 3)) || !parts.ensuredTo.Equal(month(2025, 5)) {
+		t.Errorf("unexpected ensured range %v..%v", parts.ensuredFrom, parts.ensuredTo)
+	}
+	if _, ok := orders.cache["old"]; ok {
+		t.Error("archived order must be evicted")
+	}
+	if _, ok := orders.cache["new"]; !ok {
+		t.Error("other orders must stay cached")
+	}
+}
@@ -1,11 +1,19 @@
 package service
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/metrics"
 	"github.com/Tommych123/L0-WB/internal/repository"
+	"github.com/Tommych123/L0-WB/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Структура для сервиса
@@ -13,65 +21,321 @@ type OrderService struct {
 	repo  repository.OrderRepository
 	cache map[string]*domain.Order
 	mu    sync.RWMutex
+	// Поколение кэша: растет при каждом удалении из кэша. Чтение из БД запоминает поколение
+	// до запроса и не кладет заказ в кэш, если его удалили позже (evictedAt) или кэш сбросили (resetAt)
+	gen       uint64
+	resetAt   uint64
+	evictedAt map[string]uint64
+	// Число чтений из БД, которые еще могут записать в кэш; без них evictedAt не нужен
+	loads int
+	// Вызываются после успешного сохранения заказа; не должны блокироваться
+	listeners []func(order *domain.Order)
+	// Статистика обращений к кэшу
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	// Кэш прогрет из БД
+	cacheReady atomic.Bool
+	// Архивы партиций, из которых удаляются данные клиентов; nil — архивы не обрабатываются
+	archives *ArchiveEraser
 }
 
 // Создание нового сервиса
 func NewOrderService(repo repository.OrderRepository) *OrderService {
 	return &OrderService{
-		repo:  repo,
-		cache: make(map[string]*domain.Order),
+		repo:      repo,
+		cache:     make(map[string]*domain.Order),
+		evictedAt: make(map[string]uint64),
 	}
 }
 
+// Подключает архивы партиций: удаление данных клиента обезличивает и их
+func (s *OrderService) UseArchives(archives *ArchiveEraser) {
+	s.archives = archives
+}
+
 // Сохраняет заказ в БД и кэш
-func (s *OrderService) SaveOrder(order *domain.Order) error {
-	if err := s.repo.Save(order); err != nil {
-		return err
+func (s *OrderService) SaveOrder(ctx context.Context, order *domain.Order) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.SaveOrder",
+		trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.repo.Save(ctx, order); err != nil {
+		return StorageError(err)
+	}
+	s.saved(order)
+	return nil
+}
+
+// Сохраняет заказ из сообщения Kafka вместе с записью в журнале обработанных сообщений.
+// Возвращает true, если сообщение уже было обработано ранее
+func (s *OrderService) SaveOrderFromMessage(ctx context.Context, order *domain.Order, msg domain.MessageRef) (duplicate bool, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.SaveOrderFromMessage",
+		trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() {
+		span.SetAttributes(attribute.Bool("message.duplicate", duplicate))
+		tracing.End(span, err)
+	}()
+
+	duplicate, err = s.repo.SaveFromMessage(ctx, order, msg)
+	if err != nil {
+		return false, StorageError(err)
+	}
+	if duplicate {
+		return true, nil
+	}
+	s.saved(order)
+	return false, nil
+}
+
+// Кладет сохраненный заказ в кэш; удаленный заказ остается скрытым до восстановления.
+// Обработчики оповещаются по уведомлению из БД, см. ReloadSaved
+func (s *OrderService) saved(order *domain.Order) {
+	if order.Deleted {
+		s.evict([]string{order.OrderUID})
+		return
 	}
 
 	s.mu.Lock()
 	s.cache[order.OrderUID] = order
 	s.mu.Unlock()
+}
+
+// Перечитывает заказ, сохраненный любым экземпляром сервиса, обновляет кэш и
+// оповещает обработчики. Заказ, удаленный после сохранения, убирается из кэша
+func (s *OrderService) ReloadSaved(ctx context.Context, id string) error {
+	since := s.beginLoad()
+	defer s.endLoad()
+
+	order, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return StorageError(err)
+	}
+	if order == nil {
+		s.evict([]string{id})
+		return nil
+	}
+	s.cacheLoaded(since, order)
 
+	s.notify(order)
 	return nil
 }
 
-// Возвращает заказ из кэша или из БД
-func (s *OrderService) GetOrder(id string) (*domain.Order, error) {
+// Регистрирует обработчик, вызываемый после сохранения каждого заказа любым экземпляром.
+// Регистрировать обработчики нужно до начала обработки заказов
+func (s *OrderService) OnOrderSaved(listener func(order *domain.Order)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *OrderService) notify(order *domain.Order) {
+	for _, l := range s.listeners {
+		l(order)
+	}
+}
+
+// Возвращает заказ из кэша или из БД; отсутствующий заказ — ErrNotFound
+func (s *OrderService) GetOrder(ctx context.Context, id string) (_ *domain.Order, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.GetOrder",
+		trace.WithAttributes(attribute.String("order.uid", id)))
+	defer func() { tracing.End(span, err) }()
+
 	s.mu.RLock()
 	if order, exists := s.cache[id]; exists {
 		s.mu.RUnlock()
+		s.hits.Add(1)
+		span.SetAttributes(attribute.Bool("cache.hit", true))
 		return order, nil
 	}
 	s.mu.RUnlock()
+	s.misses.Add(1)
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	slog.DebugContext(ctx, "order cache miss", logger.KeyOrderUID, id)
+
+	since := s.beginLoad()
+	defer s.endLoad()
 
-	order, err := s.repo.Get(id)
+	order, err := s.repo.Get(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, StorageError(err)
 	}
-
-	if order != nil {
-		s.mu.Lock()
-		s.cache[id] = order
-		s.mu.Unlock()
+	if order == nil {
+		return nil, NotFound(CodeOrderNotFound, "order not found")
 	}
+	s.cacheLoaded(since, order)
 
 	return order, nil
 }
 
-// Заполняет кэш из БД при старте сервиса
-func (s *OrderService) LoadCache() error {
-	orders, err := s.repo.GetAll()
+// Возвращает заказ с указанными связанными сущностями. Заказ из кэша отдается целиком;
+// при промахе из БД читаются только нужные таблицы, неполный заказ в кэш не попадает
+func (s *OrderService) GetOrderParts(ctx context.Context, id string, parts domain.OrderParts) (_ *domain.Order, err error) {
+	if parts == domain.AllOrderParts {
+		return s.GetOrder(ctx, id)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.GetOrderParts",
+		trace.WithAttributes(attribute.String("order.uid", id)))
+	defer func() { tracing.End(span, err) }()
+
+	s.mu.RLock()
+	order, exists := s.cache[id]
+	s.mu.RUnlock()
+	span.SetAttributes(attribute.Bool("cache.hit", exists))
+	if exists {
+		s.hits.Add(1)
+		return order, nil
+	}
+	s.misses.Add(1)
+
+	order, err = s.repo.GetParts(ctx, id, parts)
 	if err != nil {
-		return err
+		return nil, StorageError(err)
+	}
+	if order == nil {
+		return nil, NotFound(CodeOrderNotFound, "order not found")
 	}
+	return order, nil
+}
+
+// Возвращает заказы по списку id: сначала из кэша, остальные одним запросом к БД
+// с нужными связанными сущностями; в кэш попадают только полные заказы.
+// Повторяющиеся id учитываются один раз; missing — id, которых нет ни в кэше, ни в БД.
+// Заказы возвращаются в порядке запроса
+func (s *OrderService) GetOrders(ctx context.Context, ids []string, parts domain.OrderParts) (orders []*domain.Order, missing []string, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "OrderService.GetOrders",
+		trace.WithAttributes(attribute.Int("orders.requested", len(ids))))
+	defer func() { tracing.End(span, err) }()
 
+	unique := make([]string, 0, len(ids))
+	found := make(map[string]*domain.Order, len(ids))
+	var toFetch []string
+
+	s.mu.RLock()
+	for _, id := range ids {
+		if _, seen := found[id]; seen {
+			continue
+		}
+		order := s.cache[id]
+		found[id] = order
+		unique = append(unique, id)
+		if order == nil {
+			toFetch = append(toFetch, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	s.hits.Add(uint64(len(unique) - len(toFetch)))
+	s.misses.Add(uint64(len(toFetch)))
+	span.SetAttributes(attribute.Int("cache.misses", len(toFetch)))
+
+	if len(toFetch) > 0 {
+		since := s.beginLoad()
+		defer s.endLoad()
+
+		fetched, err := s.repo.GetMany(ctx, toFetch, parts)
+		if err != nil {
+			return nil, nil, StorageError(err)
+		}
+		for _, order := range fetched {
+			found[order.OrderUID] = order
+		}
+		if parts == domain.AllOrderParts {
+			s.cacheLoaded(since, fetched...)
+		}
+	}
+
+	orders = make([]*domain.Order, 0, len(unique))
+	missing = []string{}
+	for _, id := range unique {
+		if order := found[id]; order != nil {
+			orders = append(orders, order)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return orders, missing, nil
+}
+
+// Убирает заказы из кэша; следующее чтение пойдет в БД. Чтения, начатые раньше,
+// уже не положат эти заказы в кэш
+func (s *OrderService) evict(uids []string) {
 	s.mu.Lock()
+	s.gen++
+	for _, uid := range uids {
+		delete(s.cache, uid)
+		if s.loads > 0 {
+			s.evictedAt[uid] = s.gen
+		}
+	}
+	s.mu.Unlock()
+}
+
+// Отмечает начало чтения из БД, результат которого попадет в кэш; возвращает текущее поколение.
+// Каждому вызову соответствует endLoad
+func (s *OrderService) beginLoad() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	return s.gen
+}
+
+func (s *OrderService) endLoad() {
+	s.mu.Lock()
+	s.loads--
+	if s.loads == 0 {
+		clear(s.evictedAt)
+	}
+	s.mu.Unlock()
+}
+
+// Кладет в кэш заказы, прочитанные из БД с поколения since; заказы, удаленные из кэша
+// после начала чтения, пропускаются: прочитанная версия могла устареть
+func (s *OrderService) cacheLoaded(since uint64, orders ...*domain.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resetAt > since {
+		return
+	}
 	for _, order := range orders {
+		if s.evictedAt[order.OrderUID] > since {
+			continue
+		}
 		s.cache[order.OrderUID] = order
 	}
-	s.mu.Unlock()
+}
+
+// Статистика кэша для метрик
+func (s *OrderService) CacheStats() metrics.CacheStats {
+	s.mu.RLock()
+	size := len(s.cache)
+	s.mu.RUnlock()
+
+	return metrics.CacheStats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+		Size:   size,
+	}
+}
+
+// Заполняет кэш из БД при старте сервиса
+func (s *OrderService) LoadCache(ctx context.Context) error {
+	since := s.beginLoad()
+	defer s.endLoad()
+
+	orders, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return StorageError(err)
+	}
+	s.cacheLoaded(since, orders...)
 
-	log.Printf("Cache loaded with %d orders", len(orders))
+	s.cacheReady.Store(true)
+	slog.InfoContext(ctx, "cache loaded", "orders", len(orders))
+	return nil
+}
+
+// Проверка готовности: кэш прогрет из БД
+func (s *OrderService) CheckCache(ctx context.Context) error {
+	if !s.cacheReady.Load() {
+		return errors.New("cache warm-up is not finished")
+	}
 	return nil
 }
@@ -1,38 +1,106 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/repository"
 )
 
 // --- mockRepo ---
 type mockRepo struct {
 	saveFunc   func(order *domain.Order) error
+	saveMsgFn  func(order *domain.Order, msg domain.MessageRef) (bool, error)
 	getFunc    func(id string) (*domain.Order, error)
 	getAllFunc func() ([]*domain.Order, error)
+	getManyFn  func(ids []string) ([]*domain.Order, error)
+	byCustFn   func(customerID string) ([]*domain.Order, error)
+	eraseFn    func(customerID string, archived []string) (*domain.ErasureRecord, error)
+	streamFn   func(fn func(*domain.Order) error) error
+	deleteFn   func(id string) (*domain.DeletedOrder, error)
+	restoreFn  func(id string) (bool, error)
+	purgeFn    func(before time.Time, limit int) ([]string, error)
 }
 
-func (m *mockRepo) Save(order *domain.Order) error {
+func (m *mockRepo) Save(ctx context.Context, order *domain.Order) error {
 	if m.saveFunc != nil {
 		return m.saveFunc(order)
 	}
 	return nil
 }
-func (m *mockRepo) Get(id string) (*domain.Order, error) {
+func (m *mockRepo) SaveFromMessage(ctx context.Context, order *domain.Order, msg domain.MessageRef) (bool, error) {
+	if m.saveMsgFn != nil {
+		return m.saveMsgFn(order, msg)
+	}
+	return false, nil
+}
+func (m *mockRepo) Get(ctx context.Context, id string) (*domain.Order, error) {
 	if m.getFunc != nil {
 		return m.getFunc(id)
 	}
 	return nil, nil
 }
-func (m *mockRepo) GetAll() ([]*domain.Order, error) {
+func (m *mockRepo) GetAll(ctx context.Context) ([]*domain.Order, error) {
 	if m.getAllFunc != nil {
 		return m.getAllFunc()
 	}
 	return nil, nil
 }
 
+func (m *mockRepo) GetParts(ctx context.Context, id string, parts domain.OrderParts) (*domain.Order, error) {
+	return m.Get(ctx, id)
+}
+func (m *mockRepo) GetMany(ctx context.Context, ids []string, parts domain.OrderParts) ([]*domain.Order, error) {
+	if m.getManyFn != nil {
+		return m.getManyFn(ids)
+	}
+	return nil, nil
+}
+func (m *mockRepo) StreamOrders(ctx context.Context, from, to time.Time, fn func(*domain.Order) error) error {
+	if m.streamFn != nil {
+		return m.streamFn(fn)
+	}
+	return nil
+}
+func (m *mockRepo) GetByCustomer(ctx context.Context, customerID string) ([]*domain.Order, error) {
+	if m.byCustFn != nil {
+		return m.byCustFn(customerID)
+	}
+	return nil, nil
+}
+func (m *mockRepo) EraseCustomer(ctx context.Context, customerID string, archived []string, requestedBy, reason string) (*domain.ErasureRecord, error) {
+	if m.eraseFn != nil {
+		return m.eraseFn(customerID, archived)
+	}
+	return nil, nil
+}
+func (m *mockRepo) SoftDelete(ctx context.Context, id, deletedBy, reason string) (*domain.DeletedOrder, error) {
+	if m.deleteFn != nil {
+		return m.deleteFn(id)
+	}
+	return nil, nil
+}
+func (m *mockRepo) ListDeleted(ctx context.Context, limit int) ([]domain.DeletedOrder, error) {
+	return nil, nil
+}
+func (m *mockRepo) Restore(ctx context.Context, id string) (bool, error) {
+	if m.restoreFn != nil {
+		return m.restoreFn(id)
+	}
+	return false, nil
+}
+func (m *mockRepo) PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	if m.purgeFn != nil {
+		return m.purgeFn(before, limit)
+	}
+	return nil, nil
+}
+
 // --- Тесты ---
 
 // SaveOrder успешный
@@ -41,7 +109,7 @@ func TestSaveOrder_Success(t *testing.T) {
 	s := NewOrderService(mock)
 
 	order := &domain.Order{OrderUID: "test123"}
-	if err := s.SaveOrder(order); err != nil {
+	if err := s.SaveOrder(context.Background(), order); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
@@ -56,7 +124,7 @@ func TestSaveOrder_RepoError(t *testing.T) {
 	s := NewOrderService(mock)
 
 	order := &domain.Order{OrderUID: "test123"}
-	if err := s.SaveOrder(order); err == nil {
+	if err := s.SaveOrder(context.Background(), order); err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
@@ -70,7 +138,7 @@ func TestGetOrder_Success(t *testing.T) {
 	}
 	s := NewOrderService(mock)
 
-	got, err := s.GetOrder("order1")
+	got, err := s.GetOrder(context.Background(), "order1")
 	if err != nil || got.OrderUID != "order1" {
 		t.Errorf("unexpected result: %v, %v", got, err)
 	}
@@ -85,7 +153,7 @@ func TestGetOrder_NotFound(t *testing.T) {
 	}
 	s := NewOrderService(mock)
 
-	got, err := s.GetOrder("order1")
+	got, err := s.GetOrder(context.Background(), "order1")
 	if got != nil || err == nil {
 		t.Errorf("expected nil and error, got: %v, %v", got, err)
 	}
@@ -97,11 +165,11 @@ func TestSaveOrder_Cache(t *testing.T) {
 	s := NewOrderService(mock)
 
 	order := &domain.Order{OrderUID: "cache1"}
-	if err := s.SaveOrder(order); err != nil {
+	if err := s.SaveOrder(context.Background(), order); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	got, _ := s.GetOrder("cache1")
+	got, _ := s.GetOrder(context.Background(), "cache1")
 	if got == nil || got.OrderUID != "cache1" {
 		t.Errorf("order not found in cache after SaveOrder")
 	}
@@ -120,12 +188,12 @@ func TestLoadCache(t *testing.T) {
 	}
 	s := NewOrderService(mock)
 
-	if err := s.LoadCache(); err != nil {
+	if err := s.LoadCache(context.Background()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	for _, o := range mockOrders {
-		if got, _ := s.GetOrder(o.OrderUID); got == nil {
+		if got, _ := s.GetOrder(context.Background(), o.OrderUID); got == nil {
 			t.Errorf("order %s not loaded into cache", o.OrderUID)
 		}
 	}
@@ -139,8 +207,411 @@ func TestGetOrder_FromCache(t *testing.T) {
 	order := &domain.Order{OrderUID: "cached"}
 	s.cache["cached"] = order // вручную положили в кеш
 
-	got, err := s.GetOrder("cached")
+	got, err := s.GetOrder(context.Background(), "cached")
 	if err != nil || got.OrderUID != "cached" {
 		t.Errorf("expected to get order from cache, got: %v, %v", got, err)
 	}
 }
+
+// SaveOrderFromMessage кладет новый заказ в кеш
+func TestSaveOrderFromMessage_Cache(t *testing.T) {
+	mock := &mockRepo{}
+	s := NewOrderService(mock)
+
+	order := &domain.Order{OrderUID: "msg1"}
+	duplicate, err := s.SaveOrderFromMessage(context.Background(), order, domain.MessageRef{Topic: "orders-topic", Offset: 1})
+	if err != nil || duplicate {
+		t.Fatalf("unexpected result: %v, %v", duplicate, err)
+	}
+	if _, ok := s.cache["msg1"]; !ok {
+		t.Errorf("order not cached after SaveOrderFromMessage")
+	}
+}
+
+// Повторное сообщение не перезаписывает кеш
+func TestSaveOrderFromMessage_Duplicate(t *testing.T) {
+	mock := &mockRepo{
+		saveMsgFn: func(order *domain.Order, msg domain.MessageRef) (bool, error) {
+			return true, nil
+		},
+	}
+	s := NewOrderService(mock)
+
+	duplicate, err := s.SaveOrderFromMessage(context.Background(), &domain.Order{OrderUID: "dup"}, domain.MessageRef{})
+	if err != nil || !duplicate {
+		t.Fatalf("expected duplicate, got: %v, %v", duplicate, err)
+	}
+	if _, ok := s.cache["dup"]; ok {
+		t.Errorf("duplicate message must not touch the cache")
+	}
+}
+
+// Обработчики вызываются по уведомлению о сохранении с заказом из БД
+func TestReloadSaved(t *testing.T) {
+	stored := &domain.Order{OrderUID: "o1", Entry: "WBIL"}
+	mock := &mockRepo{getFunc: func(id string) (*domain.Order, error) {
+		if id == "o1" {
+			return stored, nil
+		}
+		return nil, nil
+	}}
+	s := NewOrderService(mock)
+	s.cache["gone"] = &domain.Order{OrderUID: "gone"}
+
+	var saved []*domain.Order
+	s.OnOrderSaved(func(order *domain.Order) { saved = append(saved, order) })
+
+	if err := s.SaveOrder(context.Background(), &domain.Order{OrderUID: "o1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 0 {
+		t.Fatalf("listeners must wait for the save notification")
+	}
+
+	if err := s.ReloadSaved(context.Background(), "o1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0] != stored || s.cache["o1"] != stored {
+		t.Errorf("unexpected notifications: %v", saved)
+	}
+
+	// Заказ удален после сохранения
+	if err := s.ReloadSaved(context.Background(), "gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.cache["gone"]; ok || len(saved) != 1 {
+		t.Errorf("missing order must be evicted without notification")
+	}
+}
+
+// CacheStats считает попадания и промахи кеша
+func TestCacheStats(t *testing.T) {
+	mock := &mockRepo{
+		getFunc: func(id string) (*domain.Order, error) {
+			return &domain.Order{OrderUID: id}, nil
+		},
+	}
+	s := NewOrderService(mock)
+
+	s.GetOrder(context.Background(), "o1") // промах, заказ попадает в кеш
+	s.GetOrder(context.Background(), "o1") // попадание
+
+	st := s.CacheStats()
+	if st.Hits != 1 || st.Misses != 1 || st.Size != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+// Сервис не готов до загрузки кеша
+func TestCheckCache(t *testing.T) {
+	s := NewOrderService(&mockRepo{})
+
+	if err := s.CheckCache(context.Background()); err == nil {
+		t.Fatal("expected cache to be not ready before LoadCache")
+	}
+	if err := s.LoadCache(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.CheckCache(context.Background()); err != nil {
+		t.Errorf("expected cache to be ready: %v", err)
+	}
+}
+
+// Отсутствующий заказ возвращает ErrNotFound, ошибка БД — ErrUnavailable
+func TestGetOrder_ErrorKinds(t *testing.T) {
+	s := NewOrderService(&mockRepo{})
+	if _, err := s.GetOrder(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	s = NewOrderService(&mockRepo{
+		getFunc: func(id string) (*domain.Order, error) {
+			return nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable)
+		},
+	})
+	if _, err := s.GetOrder(context.Background(), "o1"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+// Удаление данных клиента убирает его заказы из кеша
+func TestEraseCustomer_EvictsCache(t *testing.T) {
+	erased := false
+	s := NewOrderService(&mockRepo{
+		getFunc: func(id string) (*domain.Order, error) {
+			if erased {
+				return &domain.Order{OrderUID: id, CustomerID: "erased"}, nil
+			}
+			return &domain.Order{OrderUID: id, CustomerID: "c1"}, nil
+		},
+		eraseFn: func(customerID string, archived []string) (*domain.ErasureRecord, error) {
+			erased = true
+			return &domain.ErasureRecord{ID: 1, OrderUIDs: []string{"o1"}}, nil
+		},
+	})
+	s.GetOrder(context.Background(), "o1")
+
+	if _, err := s.EraseCustomer(context.Background(), "c1", "ops", "request"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	order, err := s.GetOrder(context.Background(), "o1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.CustomerID != "erased" {
+		t.Errorf("expected erased order to be reloaded from DB, got customer %q", order.CustomerID)
+	}
+}
+
+// Чтение из БД, начатое до удаления заказа из кэша, не возвращает устаревший заказ в кэш
+func TestGetOrder_EvictDuringLoad(t *testing.T) {
+	evictions := map[string]func(s *OrderService){
+		"evict": func(s *OrderService) { s.EvictCached("o1") },
+		"reset": func(s *OrderService) { s.ResetCache() },
+	}
+	for name, evict := range evictions {
+		t.Run(name, func(t *testing.T) {
+			started, release := make(chan struct{}), make(chan struct{})
+			var loads atomic.Int32
+			s := NewOrderService(&mockRepo{
+				getFunc: func(id string) (*domain.Order, error) {
+					if loads.Add(1) == 1 {
+						close(started)
+						<-release
+						return &domain.Order{OrderUID: id, CustomerID: "stale"}, nil
+					}
+					return &domain.Order{OrderUID: id, CustomerID: "fresh"}, nil
+				},
+			})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.GetOrder(context.Background(), "o1")
+			}()
+			<-started
+			evict(s)
+			close(release)
+			<-done
+
+			order, err := s.GetOrder(context.Background(), "o1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if order.CustomerID != "fresh" || loads.Load() != 2 {
+				t.Errorf("stale order must not be cached: %q after %d loads", order.CustomerID, loads.Load())
+			}
+			if len(s.evictedAt) != 0 {
+				t.Errorf("eviction marks must be dropped after loads finish: %v", s.evictedAt)
+			}
+		})
+	}
+}
+
+// То же для пакетного чтения: удаленный заказ не кэшируется, остальные кэшируются
+func TestGetOrders_EvictDuringLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s := NewOrderService(&mockRepo{
+		getManyFn: func(ids []string) ([]*domain.Order, error) {
+			close(started)
+			<-release
+			return []*domain.Order{{OrderUID: "o1"}, {OrderUID: "o2"}}, nil
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.GetOrders(context.Background(), []string{"o1", "o2"}, domain.AllOrderParts)
+	}()
+	<-started
+	s.EvictCached("o1")
+	close(release)
+	<-done
+
+	s.mu.RLock()
+	_, stale := s.cache["o1"]
+	_, kept := s.cache["o2"]
+	s.mu.RUnlock()
+	if stale || !kept {
+		t.Errorf("unexpected cache: o1 %v, o2 %v", stale, kept)
+	}
+}
+
+// Клиент без заказов — ErrNotFound для выгрузки и удаления
+func TestCustomer_NotFound(t *testing.T) {
+	s := NewOrderService(&mockRepo{})
+	if _, err := s.ExportCustomer(context.Background(), "c1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on export, got %v", err)
+	}
+	if _, err := s.EraseCustomer(context.Background(), "c1", "ops", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on erase, got %v", err)
+	}
+}
+
+// Мягко удаленные заказы клиента попадают в выгрузку и перечислены в DeletedOrderUIDs
+func TestExportCustomer_IncludesDeleted(t *testing.T) {
+	s := NewOrderService(&mockRepo{
+		byCustFn: func(customerID string) ([]*domain.Order, error) {
+			return []*domain.Order{
+				{OrderUID: "o1", CustomerID: customerID},
+				{OrderUID: "o2", CustomerID: customerID, Deleted: true},
+			}, nil
+		},
+	})
+	export, err := s.ExportCustomer(context.Background(), "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Orders) != 2 || len(export.DeletedOrderUIDs) != 1 || export.DeletedOrderUIDs[0] != "o2" {
+		t.Errorf("unexpected export: %d orders, deleted %v", len(export.Orders), export.DeletedOrderUIDs)
+	}
+}
+
+// Пакетное чтение: кэшированные заказы не запрашиваются из БД, отсутствующие возвращаются отдельно
+func TestGetOrders_CacheAndMissing(t *testing.T) {
+	var fetched []string
+	s := NewOrderService(&mockRepo{
+		getManyFn: func(ids []string) ([]*domain.Order, error) {
+			fetched = append(fetched, ids...)
+			return []*domain.Order{{OrderUID: "db1"}}, nil
+		},
+	})
+	s.SaveOrder(context.Background(), &domain.Order{OrderUID: "cached"})
+
+	orders, missing, err := s.GetOrders(context.Background(), []string{"db1", "cached", "none", "db1"}, domain.AllOrderParts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(fetched) != "[db1 none]" {
+		t.Errorf("unexpected DB request: %v", fetched)
+	}
+	if len(orders) != 2 || orders[0].OrderUID != "db1" || orders[1].OrderUID != "cached" {
+		t.Errorf("unexpected orders: %v", orders)
+	}
+	if fmt.Sprint(missing) != "[none]" {
+		t.Errorf("unexpected missing: %v", missing)
+	}
+}
+
+// Неполный заказ из БД не кэшируется
+func TestGetOrderParts_NotCached(t *testing.T) {
+	s := NewOrderService(&mockRepo{
+		getFunc: func(id string) (*domain.Order, error) { return &domain.Order{OrderUID: id}, nil },
+	})
+	if _, err := s.GetOrderParts(context.Background(), "o1", domain.OrderParts{Payment: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if st := s.CacheStats(); st.Size != 0 {
+		t.Errorf("partial order must not be cached, cache size %d", st.Size)
+	}
+}
+
+// Ошибка обработчика возвращается как есть, ошибка хранилища — через StorageError
+func TestExportOrders_Errors(t *testing.T) {
+	stopErr := errors.New("client gone")
+	repo := &mockRepo{streamFn: func(fn func(*domain.Order) error) error {
+		if err := fn(&domain.Order{OrderUID: "o1"}); err != nil {
+			return err
+		}
+		return fmt.Errorf("fetch: %w", repository.ErrUnavailable)
+	}}
+	svc := NewOrderService(repo)
+
+	err := svc.ExportOrders(context.Background(), time.Time{}, time.Now(), func(*domain.Order) error { return stopErr })
+	if err != stopErr {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+
+	var got []string
+	err = svc.ExportOrders(context.Background(), time.Time{}, time.Now(), func(o *domain.Order) error {
+		got = append(got, o.OrderUID)
+		return nil
+	})
+	if !errors.Is(err, ErrUnavailable) || len(got) != 1 {
+		t.Fatalf("expected ErrUnavailable after one order, got %v, %v", err, got)
+	}
+}
+
+// Удаленный заказ пропадает из кэша; отсутствующий — ErrNotFound
+func TestDeleteOrder(t *testing.T) {
+	repo := &mockRepo{deleteFn: func(id string) (*domain.DeletedOrder, error) {
+		if id != "o1" {
+			return nil, nil
+		}
+		return &domain.DeletedOrder{OrderUID: id, DeletedBy: "support"}, nil
+	}}
+	svc := NewOrderService(repo)
+	svc.cache["o1"] = &domain.Order{OrderUID: "o1"}
+
+	deleted, err := svc.DeleteOrder(context.Background(), "o1", "support", "duplicate")
+	if err != nil || deleted.OrderUID != "o1" {
+		t.Fatalf("unexpected result: %v, %v", deleted, err)
+	}
+	if _, ok := svc.cache["o1"]; ok {
+		t.Error("deleted order must be evicted")
+	}
+	if _, err := svc.DeleteOrder(context.Background(), "missing", "support", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// Сохранение удаленного заказа не возвращает его в кэш и не оповещает обработчики
+func TestSaveOrder_DeletedNotCached(t *testing.T) {
+	repo := &mockRepo{saveFunc: func(order *domain.Order) error {
+		order.Deleted = true
+		return nil
+	}}
+	svc := NewOrderService(repo)
+	notified := false
+	svc.OnOrderSaved(func(*domain.Order) { notified = true })
+
+	if err := svc.SaveOrder(context.Background(), &domain.Order{OrderUID: "o1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := svc.cache["o1"]; ok || notified {
+		t.Error("deleted order must stay hidden")
+	}
+}
+
+func TestRestoreOrder(t *testing.T) {
+	order := &domain.Order{OrderUID: "o1"}
+	repo := &mockRepo{
+		restoreFn: func(id string) (bool, error) { return id == "o1", nil },
+		getFunc:   func(id string) (*domain.Order, error) { return order, nil },
+	}
+	svc := NewOrderService(repo)
+
+	got, err := svc.RestoreOrder(context.Background(), "o1", "support")
+	if err != nil || got != order {
+		t.Fatalf("unexpected result: %v, %v", got, err)
+	}
+	_, err = svc.RestoreOrder(context.Background(), "o2", "support")
+	var svcErr *Error
+	if !errors.As(err, &svcErr) || svcErr.Code != CodeDeletedOrderNotFound {
+		t.Errorf("expected %s, got %v", CodeDeletedOrderNotFound, err)
+	}
+}
+
+// Окончательное удаление идет пачками, пока пачка полная
+func TestPurger_RunOnce(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	repo := &mockRepo{purgeFn: func(before time.Time, limit int) ([]string, error) {
+		if want := now.Add(-24 * time.Hour); !before.Equal(want) {
+			t.Errorf("before = %v, want %v", before, want)
+		}
+		calls++
+		if calls == 1 {
+			return make([]string, limit), nil
+		}
+		return []string{"o1"}, nil
+	}}
+	p := NewPurger(repo, 24*time.Hour, time.Hour)
+	p.now = func() time.Time { return now }
+
+	n, err := p.RunOnce(context.Background())
+	if err != nil || n != purgeBatchSize+1 || calls != 2 {
+		t.Fatalf("purged %d in %d calls, err %v", n, calls, err)
+	}
+}
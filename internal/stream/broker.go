@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Событие ленты заказов. ID имеет вид "<эпоха брокера>-<номер>" и известен только
+// выдавшему его экземпляру брокера
+type Event struct {
+	ID    string
+	Order *domain.Order
+	seq   uint64
+}
+
+// Фильтр подписчика; пустые поля означают «любые»
+type Filter struct {
+	Entry           string
+	DeliveryService string
+	CustomerID      string
+}
+
+// Подходит ли заказ под фильтр
+func (f Filter) Match(order *domain.Order) bool {
+	return (f.Entry == "" || f.Entry == order.Entry) &&
+		(f.DeliveryService == "" || f.DeliveryService == order.DeliveryService) &&
+		(f.CustomerID == "" || f.CustomerID == order.CustomerID)
+}
+
+// Подписка на ленту заказов.
+// Канал Events закрывается при отписке или если подписчик не успевает читать
+type Subscription struct {
+	events chan Event
+	filter Filter
+	reset  string
+}
+
+// Канал событий подписки
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Непустое значение — продолжить ленту с переданного id нельзя: он выдан другим экземпляром
+// или до перезапуска, либо пропущенные события уже вытеснены из истории. Клиенту нужно
+// перечитать заказы; лента продолжается с возвращенного id
+func (s *Subscription) Reset() string {
+	return s.reset
+}
+
+// Раздает сохраненные заказы подписчикам и хранит последние события
+// для возобновления ленты после переподключения. История есть только в памяти экземпляра,
+// поэтому продолжение работает при переподключении к тому же экземпляру без его перезапуска
+type Broker struct {
+	mu         sync.Mutex
+	epoch      string
+	seq        uint64
+	history    []Event
+	historyCap int
+	bufferSize int
+	subs       map[*Subscription]struct{}
+}
+
+// Создание нового брокера. Эпоха из времени запуска отличает id этого экземпляра
+// от id других экземпляров и от выданных до перезапуска
+func NewBroker(historySize, bufferSize int) *Broker {
+	return &Broker{
+		epoch:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		history:    make([]Event, 0, historySize),
+		historyCap: historySize,
+		bufferSize: bufferSize,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Публикует заказ всем подходящим подписчикам, никогда не блокируясь.
+// Подписчик с заполненным буфером отключается
+func (b *Broker) Publish(order *domain.Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{ID: b.eventID(b.seq), Order: order, seq: b.seq}
+	if len(b.history) == b.historyCap && b.historyCap > 0 {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:len(b.history)-1]
+	}
+	if b.historyCap > 0 {
+		b.history = append(b.history, event)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(order) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Подписывается на ленту; события после lastEventID из истории отдаются первыми.
+// Если продолжить с lastEventID нельзя, история не отдается, а подписка получает Reset
+func (b *Broker) Subscribe(filter Filter, lastEventID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	var reset string
+	if lastEventID != "" {
+		if seq, ok := b.resumable(lastEventID); ok {
+			for _, e := range b.history {
+				if e.seq > seq && filter.Match(e.Order) {
+					replay = append(replay, e)
+				}
+			}
+		} else {
+			reset = b.eventID(b.seq)
+		}
+	}
+
+	sub := &Subscription{
+		events: make(chan Event, b.bufferSize+len(replay)),
+		filter: filter,
+		reset:  reset,
+	}
+	for _, e := range replay {
+		sub.events <- e
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) eventID(seq uint64) string {
+	return fmt.Sprintf("%s-%d", b.epoch, seq)
+}
+
+// Номер события id, если все события после него есть в истории
+func (b *Broker) resumable(id string) (uint64, bool) {
+	epoch, rest, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil || seq > b.seq {
+		return 0, false
+	}
+	oldest := b.seq + 1
+	if len(b.history) > 0 {
+		oldest = b.history[0].seq
+	}
+	return seq, seq+1 >= oldest
+}
+
+// Отписывается от ленты
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeLocked(sub)
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
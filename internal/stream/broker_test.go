@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+)
+
+// Подписчик получает только подходящие под фильтр заказы
+func TestBroker_Filter(t *testing.T) {
+	b := NewBroker(10, 10)
+	sub := b.Subscribe(Filter{Entry: "WBIL"}, "")
+
+	b.Publish(&domain.Order{OrderUID: "a", Entry: "OTHER"})
+	b.Publish(&domain.Order{OrderUID: "b", Entry: "WBIL"})
+
+	e := <-sub.Events()
+	if e.Order.OrderUID != "b" {
+		t.Fatalf("unexpected order: %s", e.Order.OrderUID)
+	}
+	if len(sub.Events()) != 0 {
+		t.Errorf("filtered order leaked into subscription")
+	}
+}
+
+// После переподключения пропущенные события отдаются из истории
+func TestBroker_Resume(t *testing.T) {
+	b := NewBroker(10, 10)
+	first := b.Subscribe(Filter{}, "")
+	b.Publish(&domain.Order{OrderUID: "a"})
+	last := (<-first.Events()).ID
+	b.Unsubscribe(first)
+
+	b.Publish(&domain.Order{OrderUID: "b"})
+	b.Publish(&domain.Order{OrderUID: "c"})
+
+	sub := b.Subscribe(Filter{}, last)
+	for _, want := range []string{"b", "c"} {
+		if e := <-sub.Events(); e.Order.OrderUID != want {
+			t.Fatalf("expected %s, got %s", want, e.Order.OrderUID)
+		}
+	}
+	if sub.Reset() != "" {
+		t.Errorf("known id must not reset the stream")
+	}
+}
+
+// Продолжить с id другого экземпляра, id до перезапуска или вытесненного из истории нельзя:
+// подписка получает Reset с текущим id и не получает историю
+func TestBroker_ResumeUnknownID(t *testing.T) {
+	other := NewBroker(10, 10)
+	sub := other.Subscribe(Filter{}, "")
+	other.Publish(&domain.Order{OrderUID: "x"})
+	foreign := (<-sub.Events()).ID
+
+	// История из одного события: после "a" событие "b" уже вытеснено
+	b := NewBroker(1, 10)
+	sub = b.Subscribe(Filter{}, "")
+	b.Publish(&domain.Order{OrderUID: "a"})
+	evicted := (<-sub.Events()).ID
+	b.Publish(&domain.Order{OrderUID: "b"})
+	b.Publish(&domain.Order{OrderUID: "c"})
+	<-sub.Events()
+	current := (<-sub.Events()).ID
+	b.Unsubscribe(sub)
+
+	for name, id := range map[string]string{"foreign": foreign, "evicted": evicted, "garbage": "12345"} {
+		sub := b.Subscribe(Filter{}, id)
+		if sub.Reset() != current {
+			t.Errorf("%s: reset %q, want %q", name, sub.Reset(), current)
+		}
+		if len(sub.Events()) != 0 {
+			t.Errorf("%s: history must not be replayed after reset", name)
+		}
+		b.Unsubscribe(sub)
+	}
+
+	// Последний id без новых событий — продолжение без пропусков
+	if sub := b.Subscribe(Filter{}, current); sub.Reset() != "" || len(sub.Events()) != 0 {
+		t.Errorf("up-to-date id must resume silently")
+	}
+}
+
+// Медленный подписчик отключается, не блокируя публикацию
+func TestBroker_SlowSubscriberDropped(t *testing.T) {
+	b := NewBroker(0, 1)
+	sub := b.Subscribe(Filter{}, "")
+
+	b.Publish(&domain.Order{OrderUID: "a"})
+	b.Publish(&domain.Order{OrderUID: "b"})
+
+	<-sub.Events()
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("slow subscriber must be disconnected")
+	}
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// Состояние circuit breaker одного endpoint
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	// В полуоткрытом состоянии пропускается только одна пробная доставка
+	probing bool
+}
+
+// Circuit breaker по адресам подписчиков: после threshold ошибок подряд
+// endpoint перестает получать запросы на время cooldown
+type Breakers struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	endpoints map[string]*breakerState
+	now       func() time.Time
+}
+
+// Создание набора circuit breaker
+func NewBreakers(threshold int, cooldown time.Duration) *Breakers {
+	return &Breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		endpoints: make(map[string]*breakerState),
+		now:       time.Now,
+	}
+}
+
+// Можно ли отправить запрос на endpoint; если нет — когда попробовать снова
+func (b *Breakers) Allow(endpoint string) (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.endpoints[endpoint]
+	if !ok || st.failures < b.threshold {
+		return true, time.Time{}
+	}
+	now := b.now()
+	if now.Before(st.openUntil) {
+		return false, st.openUntil
+	}
+	if st.probing {
+		return false, now.Add(b.cooldown)
+	}
+	st.probing = true
+	return true, time.Time{}
+}
+
+// Учет успешной доставки закрывает breaker
+func (b *Breakers) Success(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.endpoints, endpoint)
+}
+
+// Учет неудачной доставки
+func (b *Breakers) Failure(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.endpoints[endpoint]
+	if !ok {
+		st = &breakerState{}
+		b.endpoints[endpoint] = st
+	}
+	st.failures++
+	st.probing = false
+	if st.failures >= b.threshold {
+		st.openUntil = b.now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Tommych123/L0-WB/internal/domain"
+	"github.com/Tommych123/L0-WB/internal/logger"
+	"github.com/Tommych123/L0-WB/internal/repository"
+)
+
+// Заголовки исходящих webhook
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Параметры доставки webhook
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	Timeout      time.Duration
+	MaxAttempts  int
+	// Начальная задержка между попытками, удваивается с каждой попыткой
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Ошибок подряд до размыкания circuit breaker и время размыкания
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Рассылает события о заказах подписчикам по HTTP
+type Dispatcher struct {
+	repo     repository.WebhookRepository
+	cfg      Config
+	client   *http.Client
+	breakers *Breakers
+}
+
+// Создание нового диспетчера
+func NewDispatcher(repo repository.WebhookRepository, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		repo:     repo,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		breakers: NewBreakers(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// Периодически отправляет доставки из очереди до отмены контекста
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Lease с запасом покрывает все попытки пакета
+		deliveries, err := d.repo.ClaimDueDeliveries(ctx, d.cfg.Workers*4, 2*d.cfg.Timeout+time.Minute)
+		if err != nil {
+			slog.ErrorContext(ctx, "error claiming webhook deliveries", logger.KeyError, err)
+			continue
+		}
+		d.deliverAll(ctx, deliveries)
+	}
+}
+
+// Отправляет пакет доставок с ограничением параллельности
+func (d *Dispatcher) deliverAll(ctx context.Context, deliveries []domain.WebhookDelivery) {
+	sem := make(chan struct{}, d.cfg.Workers)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *domain.WebhookDelivery) {
+			defer func() { <-sem; wg.Done() }()
+			d.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// Одна попытка доставки с учетом circuit breaker и планированием повтора
+func (d *Dispatcher) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	ctx = logger.With(ctx, "delivery_id", delivery.ID, logger.KeyOrderUID, delivery.OrderUID)
+
+	sub, err := d.repo.GetSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error loading webhook subscription", "subscription_id", delivery.SubscriptionID, logger.KeyError, err)
+		return
+	}
+	if sub == nil || !sub.Active {
+		delivery.Status = domain.DeliveryFailed
+		delivery.LastError = "subscription is inactive"
+		d.record(ctx, delivery)
+		return
+	}
+
+	// Endpoint недоступен: откладываем доставку, не тратя попытку
+	if ok, retryAt := d.breakers.Allow(sub.URL); !ok {
+		delivery.NextAttemptAt = retryAt
+		delivery.LastError = "circuit breaker open"
+		d.record(ctx, delivery)
+		return
+	}
+
+	delivery.Attempts++
+	code, err := d.send(ctx, sub, delivery)
+	delivery.ResponseCode = code
+	if err == nil {
+		d.breakers.Success(sub.URL)
+		now := time.Now()
+		delivery.Status = domain.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		d.record(ctx, delivery)
+		return
+	}
+
+	d.breakers.Failure(sub.URL)
+	delivery.LastError = err.Error()
+	slog.WarnContext(ctx, "webhook delivery failed", "attempt", delivery.Attempts, logger.KeyError, err)
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		delivery.Status = domain.DeliveryFailed
+	} else {
+		delivery.NextAttemptAt = time.Now().Add(Backoff(delivery.Attempts, d.cfg.BaseBackoff, d.cfg.MaxBackoff))
+	}
+	d.record(ctx, delivery)
+}
+
+// Отправляет подписанный запрос подписчику
+func (d *Dispatcher) send(ctx context.Context, sub *domain.WebhookSubscription, delivery *domain.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Сохраняет результат попытки; при остановке сервиса результат уже отправленного запроса
+// все равно записывается, иначе доставка уйдет подписчику повторно
+func (d *Dispatcher) record(ctx context.Context, delivery *domain.WebhookDelivery) {
+	if err := d.repo.RecordAttempt(context.WithoutCancel(ctx), delivery); err != nil {
+		slog.ErrorContext(ctx, "error recording webhook delivery", logger.KeyError, err)
+	}
+}
+
+// Подпись тела запроса: HMAC-SHA256 от "<timestamp>.<body>" в формате "sha256=<hex>".
+// Временная метка в подписи защищает получателя от повторного воспроизведения
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Экспоненциальная задержка перед попыткой attempt+1 со случайным разбросом до 20%
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
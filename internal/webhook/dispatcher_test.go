@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+// Подпись совпадает с эталонным HMAC-SHA256
+func TestSign(t *testing.T) {
+	got := Sign("secret", "1700000000", []byte(`{"a":1}`))
+	want := "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	if got != want {
+		t.Errorf("unexpected signature: %s", got)
+	}
+}
+
+// Задержка растет экспоненциально и ограничена сверху
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: max} {
+		got := Backoff(attempt, base, max)
+		if got < want || got > want+want/5 {
+			t.Errorf("attempt %d: backoff %v, want %v..%v", attempt, got, want, want+want/5)
+		}
+	}
+}
+
+// Breaker размыкается после серии ошибок и пропускает одну пробу после паузы
+func TestBreakers(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreakers(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure("u")
+	if ok, _ := b.Allow("u"); !ok {
+		t.Fatal("breaker opened before threshold")
+	}
+	b.Failure("u")
+	if ok, _ := b.Allow("u"); ok {
+		t.Fatal("breaker must be open after threshold")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := b.Allow("u"); !ok {
+		t.Fatal("breaker must allow a probe after cooldown")
+	}
+	if ok, _ := b.Allow("u"); ok {
+		t.Fatal("only one probe is allowed in half-open state")
+	}
+
+	b.Success("u")
+	if ok, _ := b.Allow("u"); !ok {
+		t.Fatal("breaker must close after success")
+	}
+}
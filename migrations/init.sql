@@ -1,5 +1,5 @@
 CREATE TABLE IF NOT EXISTS orders (
-    order_uid TEXT PRIMARY KEY,
+    order_uid TEXT NOT NULL,
     track_number TEXT NOT NULL,
     entry TEXT NOT NULL,
     locale TEXT NOT NULL,
@@ -9,22 +9,29 @@ CREATE TABLE IF NOT EXISTS orders (
     shardkey TEXT NOT NULL,
     sm_id INT NOT NULL,
     date_created TIMESTAMP NOT NULL,
-    oof_shard TEXT NOT NULL
-);
+    oof_shard TEXT NOT NULL,
+    -- Ключ партиции входит в первичный ключ; уникальность order_uid обеспечивает сервис
+    PRIMARY KEY (order_uid, date_created)
+) PARTITION BY RANGE (date_created);
 
+-- Связанные таблицы партиционируются по той же дате заказа
 CREATE TABLE IF NOT EXISTS delivery (
-    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
     name TEXT NOT NULL,
     phone TEXT NOT NULL,
     zip TEXT NOT NULL,
     city TEXT NOT NULL,
     address TEXT NOT NULL,
     region TEXT NOT NULL,
-    email TEXT NOT NULL
-);
+    email TEXT NOT NULL,
+    PRIMARY KEY (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
 
 CREATE TABLE IF NOT EXISTS payment (
-    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
     transaction TEXT NOT NULL,
     request_id TEXT,
     currency TEXT NOT NULL,
@@ -34,14 +41,16 @@ CREATE TABLE IF NOT EXISTS payment (
     bank TEXT NOT NULL,
     delivery_cost INT NOT NULL,
     goods_total INT NOT NULL,
-    custom_fee INT NOT NULL
-);
+    custom_fee INT NOT NULL,
+    PRIMARY KEY (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
 
 CREATE TABLE IF NOT EXISTS items (
     chrt_id INT NOT NULL,
     track_number TEXT NOT NULL,
     price INT NOT NULL,
-    rid TEXT PRIMARY KEY,
+    rid TEXT NOT NULL,
     name TEXT NOT NULL,
     sale INT NOT NULL,
     size TEXT NOT NULL,
@@ -49,9 +58,179 @@ CREATE TABLE IF NOT EXISTS items (
     nm_id INT NOT NULL,
     brand TEXT NOT NULL,
     status INT NOT NULL,
-    order_uid TEXT REFERENCES orders(order_uid) ON DELETE CASCADE
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    PRIMARY KEY (rid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+-- Создает месячные партиции orders, delivery, payment и items для месяца, в который попадает day.
+-- Имена партиций: <таблица>_pYYYYMM
+CREATE OR REPLACE FUNCTION ensure_order_partitions(day DATE) RETURNS void AS $$
+DECLARE
+    month_start DATE := date_trunc('month', day)::date;
+    month_end DATE := (date_trunc('month', day) + interval '1 month')::date;
+    tbl TEXT;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            tbl || '_p' || to_char(month_start, 'YYYYMM'), tbl, month_start, month_end);
+    END LOOP;
+END
+$$ LANGUAGE plpgsql;
+
+-- Партиции прошлого, текущего и двух следующих месяцев; остальные создает сервис
+SELECT ensure_order_partitions((date_trunc('month', now()) + make_interval(months => m))::date)
+FROM generate_series(-1, 2) AS m;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    status_code INT NOT NULL,
+    response BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS processed_messages (
+    topic TEXT NOT NULL,
+    partition INT NOT NULL,
+    "offset" BIGINT NOT NULL,
+    message_id TEXT,
+    order_uid TEXT NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (topic, partition, "offset")
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    published_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    entry TEXT NOT NULL DEFAULT '',
+    delivery_service TEXT NOT NULL DEFAULT '',
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    order_uid TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    response_code INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMP,
+    UNIQUE (subscription_id, event_id)
 );
 
 CREATE INDEX idx_delivery_order_uid ON delivery(order_uid);
 CREATE INDEX idx_payment_order_uid  ON payment(order_uid);
-CREATE INDEX idx_items_order_uid    ON items(order_uid);
\ No newline at end of file
+CREATE INDEX idx_items_order_uid    ON items(order_uid);
+CREATE UNIQUE INDEX idx_processed_messages_message_id ON processed_messages(message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX idx_processed_messages_order_uid ON processed_messages(order_uid);
+CREATE INDEX idx_processed_messages_processed_at ON processed_messages(processed_at);
+CREATE INDEX idx_outbox_unpublished ON outbox(id) WHERE published_at IS NULL;
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- Слепые индексы (HMAC) для поиска по зашифрованным email и телефону
+ALTER TABLE delivery ADD COLUMN IF NOT EXISTS email_bidx TEXT;
+ALTER TABLE delivery ADD COLUMN IF NOT EXISTS phone_bidx TEXT;
+
+CREATE INDEX IF NOT EXISTS delivery_email_bidx_idx ON delivery (email_bidx);
+CREATE INDEX IF NOT EXISTS delivery_phone_bidx_idx ON delivery (phone_bidx);
+
+-- Аудит удаления персональных данных клиентов; финансовые итоги сохраняются
+CREATE TABLE IF NOT EXISTS erasure_audit (
+    id BIGSERIAL PRIMARY KEY,
+    customer_ref TEXT NOT NULL,
+    order_uids TEXT[] NOT NULL,
+    totals JSONB NOT NULL,
+    requested_by TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    erased_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX IF NOT EXISTS idx_erasure_audit_customer_ref ON erasure_audit(customer_ref);
+
+-- Время последнего изменения заказа для Last-Modified и условных запросов
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+
+-- Выгрузка заказов за период читает orders по date_created
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created, order_uid);
+
+-- Аналитика: соединение с payment и items без чтения строк таблиц (index-only scan)
+CREATE INDEX IF NOT EXISTS idx_payment_analytics ON payment(order_uid) INCLUDE (currency, amount, delivery_cost, provider, bank);
+CREATE INDEX IF NOT EXISTS idx_items_analytics ON items(order_uid) INCLUDE (brand, nm_id, total_price, sale);
+
+-- Дневные агрегаты заказов для аналитики; обновляются в транзакции сохранения заказа
+CREATE TABLE IF NOT EXISTS order_rollups_daily (
+    day DATE NOT NULL,
+    currency TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    orders BIGINT NOT NULL,
+    revenue BIGINT NOT NULL,
+    delivery_cost BIGINT NOT NULL,
+    items BIGINT NOT NULL,
+    discounted_items BIGINT NOT NULL,
+    sale_sum BIGINT NOT NULL,
+    PRIMARY KEY (day, currency, delivery_service, provider)
+);
+
+-- Мягкое удаление заказов: удаленный заказ скрыт из чтения и аналитики до восстановления
+-- или окончательного удаления после периода ожидания
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Ключи идемпотентности резервируются до обработки запроса (status_code IS NULL — запрос
+-- еще выполняется) и принадлежат вызывающему; устаревшие ключи удаляются по created_at
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ALTER COLUMN status_code DROP NOT NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN response DROP NOT NULL;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD CONSTRAINT idempotency_keys_pkey PRIMARY KEY (owner, key);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+-- Аренда публикации outbox: события публикует одна реплика, транзакция на время отправки не держится
+CREATE TABLE IF NOT EXISTS outbox_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Опубликованные события удаляются через OUTBOX_RETENTION
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
+
+-- Владелец rid позиции во всех партициях: первичный ключ items (rid, date_created)
+-- не мешает повторить rid в заказе с другой датой
+CREATE TABLE IF NOT EXISTS item_rids (
+    rid TEXT PRIMARY KEY,
+    order_uid TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_rids_order_uid ON item_rids(order_uid);
+
+INSERT INTO item_rids (rid, order_uid)
+SELECT DISTINCT ON (rid) rid, order_uid FROM items ORDER BY rid, date_created
+ON CONFLICT (rid) DO NOTHING;
@@ -0,0 +1,219 @@
+-- Перевод существующей БД с обычных таблиц orders, delivery, payment и items на месячные партиции.
+-- Выполняется один раз при остановленном сервисе:
+--   psql -v ON_ERROR_STOP=1 -f migrations/partition_orders.sql
+-- Все шаги идут в одной транзакции: при ошибке БД остается в исходном состоянии
+BEGIN;
+
+-- Повторный запуск на уже партиционированной БД ничего не должен менять
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = 'orders'::regclass) THEN
+        RAISE EXCEPTION 'orders is already partitioned';
+    END IF;
+END
+$$;
+
+-- Старые таблицы переименовываются вместе с индексами, чтобы имена освободились для новых
+ALTER TABLE items RENAME TO items_old;
+ALTER TABLE payment RENAME TO payment_old;
+ALTER TABLE delivery RENAME TO delivery_old;
+ALTER TABLE orders RENAME TO orders_old;
+
+DO $$
+DECLARE
+    idx RECORD;
+BEGIN
+    FOR idx IN
+        SELECT indexname FROM pg_indexes
+        WHERE schemaname = current_schema()
+          AND tablename IN ('orders_old', 'delivery_old', 'payment_old', 'items_old')
+    LOOP
+        EXECUTE format('ALTER INDEX %I RENAME TO %I', idx.indexname, idx.indexname || '_old');
+    END LOOP;
+END
+$$;
+
+-- Колонки, которые init.sql добавлял к таблицам позже, могут отсутствовать в старой БД
+ALTER TABLE orders_old ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE orders_old ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE orders_old ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE orders_old ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+ALTER TABLE delivery_old ADD COLUMN IF NOT EXISTS email_bidx TEXT;
+ALTER TABLE delivery_old ADD COLUMN IF NOT EXISTS phone_bidx TEXT;
+
+-- Новые таблицы совпадают с init.sql вместе с колонками, добавленными через ALTER
+CREATE TABLE orders (
+    order_uid TEXT NOT NULL,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT NOT NULL,
+    internal_signature TEXT,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    oof_shard TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMP,
+    deleted_by TEXT,
+    delete_reason TEXT,
+    PRIMARY KEY (order_uid, date_created)
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE delivery (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL,
+    email_bidx TEXT,
+    phone_bidx TEXT,
+    PRIMARY KEY (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE payment (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    transaction TEXT NOT NULL,
+    request_id TEXT,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    amount INT NOT NULL,
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost INT NOT NULL,
+    goods_total INT NOT NULL,
+    custom_fee INT NOT NULL,
+    PRIMARY KEY (order_uid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE items (
+    chrt_id INT NOT NULL,
+    track_number TEXT NOT NULL,
+    price INT NOT NULL,
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INT NOT NULL,
+    size TEXT NOT NULL,
+    total_price INT NOT NULL,
+    nm_id INT NOT NULL,
+    brand TEXT NOT NULL,
+    status INT NOT NULL,
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    PRIMARY KEY (rid, date_created),
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE OR REPLACE FUNCTION ensure_order_partitions(day DATE) RETURNS void AS $$
+DECLARE
+    month_start DATE := date_trunc('month', day)::date;
+    month_end DATE := (date_trunc('month', day) + interval '1 month')::date;
+    tbl TEXT;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            tbl || '_p' || to_char(month_start, 'YYYYMM'), tbl, month_start, month_end);
+    END LOOP;
+END
+$$ LANGUAGE plpgsql;
+
+-- Партиции всех месяцев с заказами и двух следующих за текущим
+SELECT ensure_order_partitions(m::date)
+FROM generate_series(
+    date_trunc('month', LEAST(COALESCE((SELECT min(date_created) FROM orders_old), localtimestamp), localtimestamp)),
+    date_trunc('month', GREATEST(COALESCE((SELECT max(date_created) FROM orders_old), localtimestamp), localtimestamp) + interval '2 months'),
+    interval '1 month'
+) AS m;
+
+-- Перенос данных; связанные таблицы получают date_created из заказа
+INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+                    shardkey, sm_id, date_created, oof_shard, updated_at, deleted_at, deleted_by, delete_reason)
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+       shardkey, sm_id, date_created, oof_shard, updated_at, deleted_at, deleted_by, delete_reason
+FROM orders_old;
+
+INSERT INTO delivery (order_uid, date_created, name, phone, zip, city, address, region, email, email_bidx, phone_bidx)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.email_bidx, d.phone_bidx
+FROM delivery_old d
+JOIN orders_old o USING (order_uid);
+
+INSERT INTO payment (order_uid, date_created, transaction, request_id, currency, provider, amount, payment_dt,
+                     bank, delivery_cost, goods_total, custom_fee)
+SELECT p.order_uid, o.date_created, p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+       p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+FROM payment_old p
+JOIN orders_old o USING (order_uid);
+
+INSERT INTO items (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status,
+                   order_uid, date_created)
+SELECT i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status,
+       i.order_uid, o.date_created
+FROM items_old i
+JOIN orders_old o USING (order_uid);
+
+-- Владелец rid позиции во всех партициях, как в init.sql
+CREATE TABLE IF NOT EXISTS item_rids (
+    rid TEXT PRIMARY KEY,
+    order_uid TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_item_rids_order_uid ON item_rids(order_uid);
+INSERT INTO item_rids (rid, order_uid)
+SELECT rid, order_uid FROM items
+ON CONFLICT (rid) DO NOTHING;
+
+-- Строки без заказа (в старой схеме у items order_uid мог быть NULL) в партиции не попадают:
+-- они сохраняются в таблицах *_orphans, их число выводится в NOTICE
+CREATE TABLE delivery_orphans AS
+SELECT d.* FROM delivery_old d
+WHERE NOT EXISTS (SELECT 1 FROM orders_old o WHERE o.order_uid = d.order_uid);
+
+CREATE TABLE payment_orphans AS
+SELECT p.* FROM payment_old p
+WHERE NOT EXISTS (SELECT 1 FROM orders_old o WHERE o.order_uid = p.order_uid);
+
+CREATE TABLE items_orphans AS
+SELECT i.* FROM items_old i
+WHERE NOT EXISTS (SELECT 1 FROM orders_old o WHERE o.order_uid = i.order_uid);
+
+DO $$
+DECLARE
+    tbl TEXT;
+    n BIGINT;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY['delivery', 'payment', 'items'] LOOP
+        EXECUTE format('SELECT count(*) FROM %I', tbl || '_orphans') INTO n;
+        IF n > 0 THEN
+            RAISE NOTICE '% rows of % have no order and were moved to %', n, tbl, tbl || '_orphans';
+        ELSE
+            EXECUTE format('DROP TABLE %I', tbl || '_orphans');
+        END IF;
+    END LOOP;
+END
+$$;
+
+DROP TABLE items_old;
+DROP TABLE payment_old;
+DROP TABLE delivery_old;
+DROP TABLE orders_old;
+
+-- Индексы init.sql для таблиц заказов
+CREATE INDEX idx_delivery_order_uid ON delivery(order_uid);
+CREATE INDEX idx_payment_order_uid  ON payment(order_uid);
+CREATE INDEX idx_items_order_uid    ON items(order_uid);
+CREATE INDEX delivery_email_bidx_idx ON delivery (email_bidx);
+CREATE INDEX delivery_phone_bidx_idx ON delivery (phone_bidx);
+CREATE INDEX idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX idx_orders_date_created ON orders(date_created, order_uid);
+CREATE INDEX idx_payment_analytics ON payment(order_uid) INCLUDE (currency, amount, delivery_cost, provider, bank);
+CREATE INDEX idx_items_analytics ON items(order_uid) INCLUDE (brand, nm_id, total_price, sale);
+CREATE INDEX idx_orders_deleted_at ON orders(deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,12 @@
+package web
+
+import (
+	"embed"
+	"html/template"
+)
+
+//go:embed index.html
+var files embed.FS
+
+// Шаблон страницы поиска заказа; данные шаблона — *i18n.Localizer
+var Index = template.Must(template.ParseFS(files, "index.html"))